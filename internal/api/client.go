@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNotRunning is returned by Dial when no go-work process is serving the
+// socket.
+var ErrNotRunning = errors.New("no go-work process is running sessions in this repo")
+
+// Client calls the API of the go-work process running sessions, through its
+// unix socket.
+type Client struct {
+	http *http.Client
+}
+
+// Dial connects to the socket at path.
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	conn.Close()
+	return &Client{http: &http.Client{
+		Timeout: time.Minute, // starting sessions fetches issues
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}}, nil
+}
+
+// Start starts sessions for the referenced issues. It returns the keys of
+// their sessions.
+func (c *Client) Start(refs []string) ([]string, error) {
+	var list []sessionJSON
+	if err := c.post("/api/sessions", startRequest{Refs: refs}, &list); err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(list))
+	for i, s := range list {
+		keys[i] = s.Key
+	}
+	return keys, nil
+}
+
+// Decide approves or rejects the plan of the session with the given key.
+func (c *Client) Decide(key string, approve bool) error {
+	action := "reject"
+	if approve {
+		action = "approve"
+	}
+	return c.post("/api/sessions/"+key+"/"+action, nil, nil)
+}
+
+func (c *Client) post(path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	resp, err := c.http.Post("http://go-work"+path, "application/json", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s", strings.TrimSpace(string(msg)))
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
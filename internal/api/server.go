@@ -0,0 +1,511 @@
+// Package api serves go-work's sessions over HTTP: a JSON API with
+// approve, reject, cancel and permission endpoints, log streaming via
+// server-sent events, and a small web dashboard built on both. The API is also served
+// on a unix socket, through which the go-work command line (see Client)
+// reaches the process running sessions.
+//
+// session.Manager is not safe for concurrent use, so handlers never touch it
+// directly. They send Calls, which the goroutine owning the Manager (the TUI
+// update loop or the daemon loop) runs between events.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/tomfevang/go-work/internal/config"
+	"github.com/tomfevang/go-work/internal/permission"
+	"github.com/tomfevang/go-work/internal/session"
+	"github.com/tomfevang/go-work/internal/telemetry"
+)
+
+//go:embed web
+var webFS embed.FS
+
+// Call is a request from an HTTP handler to run against the Manager.
+type Call struct {
+	fn   func(*session.Manager)
+	done chan struct{}
+}
+
+// Run executes the call. It must be called from the goroutine that owns m.
+func (c Call) Run(m *session.Manager) {
+	c.fn(m)
+	close(c.done)
+}
+
+// Resolver looks up issues by reference, for starting sessions through the
+// API. See tasks.Resolve.
+type Resolver func(refs []string) ([]session.Issue, error)
+
+// ErrRunning is returned by StartSocket when another go-work process is
+// already running sessions in the repo.
+var ErrRunning = errors.New("another go-work process is running sessions in this repo")
+
+// Server is the HTTP API and web dashboard for one Manager.
+type Server struct {
+	cfg     config.Server
+	resolve Resolver
+	calls   chan Call
+
+	mu      sync.Mutex
+	subs    map[string]map[chan string]bool // log subscribers per session key
+	servers []*http.Server
+}
+
+// New returns a server for a Manager's sessions. It does not listen until
+// Start or StartSocket is called.
+func New(cfg config.Server, resolve Resolver) *Server {
+	return &Server{
+		cfg:     cfg,
+		resolve: resolve,
+		calls:   make(chan Call),
+		subs:    make(map[string]map[chan string]bool),
+	}
+}
+
+// Calls is the channel of requests to run against the Manager. Every Call
+// read from it must be run.
+func (s *Server) Calls() <-chan Call { return s.calls }
+
+// Start listens on the configured address and serves in the background
+// until ctx is cancelled. It returns the address listened on.
+func (s *Server) Start(ctx context.Context) (string, error) {
+	if s.cfg.Token == "" {
+		return "", fmt.Errorf("api server: a token is required to listen on %s", s.cfg.Addr)
+	}
+	ln, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return "", fmt.Errorf("api server: %w", err)
+	}
+	s.serve(ctx, ln, s.auth(s.routes()))
+	return ln.Addr().String(), nil
+}
+
+// StartSocket serves the API on a unix socket at path, for the go-work
+// command line, until ctx is cancelled. The socket is protected by the
+// permissions of its directory rather than the token. It returns ErrRunning
+// if another process is serving there.
+func (s *Server) StartSocket(ctx context.Context, path string) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return ErrRunning
+	}
+	_ = os.Remove(path) // left behind by a process that didn't exit cleanly
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("api socket: %w", err)
+	}
+	s.serve(ctx, ln, s.routes())
+	return nil
+}
+
+// Shutdown stops serving, waiting until ctx is done for requests in flight,
+// such as an approval that finished the last session, to be answered.
+func (s *Server) Shutdown(ctx context.Context) {
+	s.mu.Lock()
+	servers := s.servers
+	s.mu.Unlock()
+	for _, srv := range servers {
+		_ = srv.Shutdown(ctx)
+	}
+}
+
+func (s *Server) serve(ctx context.Context, ln net.Listener, h http.Handler) {
+	srv := &http.Server{Handler: h}
+	s.mu.Lock()
+	s.servers = append(s.servers, srv)
+	s.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	go func() { _ = srv.Serve(ln) }() // only fails once closed
+}
+
+// Publish forwards a session's output to clients streaming its log. It must
+// be called for every event after the Manager has handled it.
+func (s *Server) Publish(ev session.Event) {
+	if ev.Type != session.EventOutput {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs[ev.Key] {
+		select {
+		case ch <- ev.Text:
+		default: // slow client; it can reload the full log
+		}
+	}
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	web, _ := fs.Sub(webFS, "web")
+	mux.Handle("GET /", http.FileServerFS(web))
+	mux.HandleFunc("GET /metrics", serveMetrics)
+	mux.HandleFunc("GET /api/sessions", s.listSessions)
+	mux.HandleFunc("POST /api/sessions", s.startSessions)
+	mux.HandleFunc("GET /api/sessions/{key}", s.getSession)
+	mux.HandleFunc("GET /api/sessions/{key}/diff", s.getDiff)
+	mux.HandleFunc("GET /api/sessions/{key}/events", s.streamLog)
+	mux.HandleFunc("POST /api/sessions/{key}/approve", s.decide(true))
+	mux.HandleFunc("POST /api/sessions/{key}/reject", s.decide(false))
+	mux.HandleFunc("POST /api/sessions/{key}/cancel", s.cancel)
+	mux.HandleFunc("POST /api/sessions/{key}/permission", s.permit)
+	mux.HandleFunc("POST /api/sessions/{key}/follow-up", s.followUp)
+	return mux
+}
+
+// auth requires the configured token as a bearer token or, for
+// EventSource which cannot set headers, a token query parameter. It also
+// refuses what a browser would send from other sites' pages: requests with
+// a foreign Origin and POSTs that are not JSON.
+func (s *Server) auth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+r.Host && origin != "https://"+r.Host {
+			http.Error(w, "cross-origin requests are not allowed", http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodPost {
+			if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+				http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+				return
+			}
+		}
+		got := r.URL.Query().Get("token")
+		if h, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			got = h
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(s.cfg.Token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// do runs fn on the Manager's goroutine and waits for it. It reports false,
+// having written an error, if the request was cancelled first.
+func (s *Server) do(w http.ResponseWriter, r *http.Request, fn func(*session.Manager)) bool {
+	c := Call{fn: fn, done: make(chan struct{})}
+	select {
+	case s.calls <- c:
+	case <-r.Context().Done():
+		http.Error(w, "cancelled", http.StatusServiceUnavailable)
+		return false
+	}
+	<-c.done
+	return true
+}
+
+// sessionJSON is the API representation of a session.
+type sessionJSON struct {
+	Key       string   `json:"key"`
+	Ref       string   `json:"ref"`
+	Title     string   `json:"title"`
+	State     string   `json:"state"`
+	URL       string   `json:"url,omitempty"`
+	PR        string   `json:"pr,omitempty"`
+	Branch    string   `json:"branch,omitempty"`
+	Waiting   []string `json:"waiting,omitempty"`
+	Overlaps  []string `json:"overlaps,omitempty"`
+	Files     []string `json:"files,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Plan      string   `json:"plan,omitempty"`
+	Findings  string   `json:"findings,omitempty"`
+	Prompt    string   `json:"prompt,omitempty"`
+	FollowUps []string `json:"follow_ups,omitempty"`
+	Log       string   `json:"log,omitempty"`
+}
+
+func toJSON(m *session.Manager, key string, full bool) sessionJSON {
+	s := m.Get(key)
+	j := sessionJSON{
+		Key:    key,
+		Ref:    s.Issue.Ref(),
+		Title:  s.Issue.Title,
+		State:  s.State.String(),
+		URL:    s.Issue.URL,
+		PR:     s.PR,
+		Branch: s.Branch,
+		Files:  s.Files(),
+	}
+	if s.State == session.Pending || s.State == session.Queued {
+		j.Waiting = m.Waiting(key)
+	}
+	for _, o := range m.Overlaps(key) {
+		j.Overlaps = append(j.Overlaps, m.Get(o.Key).Issue.Ref())
+	}
+	if s.Err != nil {
+		j.Error = s.Err.Error()
+	}
+	j.Findings, j.Prompt, j.FollowUps = s.Findings, s.Prompt, s.FollowUps
+	if full {
+		j.Plan, j.Log = s.Plan, s.Log.String()
+	}
+	return j
+}
+
+func (s *Server) listSessions(w http.ResponseWriter, r *http.Request) {
+	var list []sessionJSON
+	if !s.do(w, r, func(m *session.Manager) {
+		list = make([]sessionJSON, 0, len(m.Order()))
+		for _, key := range m.Order() {
+			list = append(list, toJSON(m, key, false))
+		}
+	}) {
+		return
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// startRequest is the body of POST /api/sessions.
+type startRequest struct {
+	Refs []string `json:"refs"`
+}
+
+// startSessions adds sessions for the referenced issues, responding with
+// them. Issues that already have a session are left alone.
+func (s *Server) startSessions(w http.ResponseWriter, r *http.Request) {
+	var req startRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Refs) == 0 {
+		http.Error(w, "expected {\"refs\": [...]}", http.StatusBadRequest)
+		return
+	}
+	if s.resolve == nil {
+		http.Error(w, "starting sessions is not supported here", http.StatusNotImplemented)
+		return
+	}
+	// Resolve outside the Manager's goroutine; it may call the forge.
+	issues, err := s.resolve(req.Refs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var list []sessionJSON
+	if !s.do(w, r, func(m *session.Manager) {
+		m.Add(issues)
+		for _, iss := range issues {
+			list = append(list, toJSON(m, iss.Key(), false))
+		}
+	}) {
+		return
+	}
+	writeJSON(w, http.StatusCreated, list)
+}
+
+func (s *Server) getSession(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	var j *sessionJSON
+	if !s.do(w, r, func(m *session.Manager) {
+		if m.Get(key) != nil {
+			v := toJSON(m, key, true)
+			j = &v
+		}
+	}) {
+		return
+	}
+	if j == nil {
+		http.Error(w, "no such session", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+func (s *Server) getDiff(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	var root, dir string
+	if !s.do(w, r, func(m *session.Manager) {
+		if sess := m.Get(key); sess != nil && sess.State != session.Pending {
+			env := m.Env(key)
+			root, dir = env.RepoRoot, env.Worktrees.Path(env.RepoRoot, key)
+		}
+	}) {
+		return
+	}
+	if dir == "" {
+		http.Error(w, "no worktree for session", http.StatusNotFound)
+		return
+	}
+	diff, err := session.Diff(root, dir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-diff; charset=utf-8")
+	fmt.Fprintln(w, diff)
+}
+
+// streamLog sends the session's log so far and then its output as it
+// arrives, as server-sent "log" events carrying JSON strings.
+func (s *Server) streamLog(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	// Snapshot and subscribe on the Manager's goroutine, where Publish is
+	// called, so no output falls between the two.
+	ch := make(chan string, 256)
+	var snapshot string
+	var found bool
+	if !s.do(w, r, func(m *session.Manager) {
+		if sess := m.Get(key); sess != nil {
+			found, snapshot = true, sess.Log.String()
+			s.subscribe(key, ch)
+		}
+	}) {
+		return
+	}
+	if !found {
+		http.Error(w, "no such session", http.StatusNotFound)
+		return
+	}
+	defer s.unsubscribe(key, ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	send := func(text string) {
+		data, _ := json.Marshal(text)
+		fmt.Fprintf(w, "event: log\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+	send(snapshot)
+	for {
+		select {
+		case text := <-ch:
+			send(text)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *Server) subscribe(key string, ch chan string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.subs[key] == nil {
+		s.subs[key] = make(map[chan string]bool)
+	}
+	s.subs[key][ch] = true
+}
+
+func (s *Server) unsubscribe(key string, ch chan string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subs[key], ch)
+}
+
+func (s *Server) decide(approve bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.PathValue("key")
+		var ok bool
+		if !s.do(w, r, func(m *session.Manager) { ok = m.Approve(key, approve) }) {
+			return
+		}
+		if !ok {
+			http.Error(w, "session is not waiting for a decision", http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decisions are the answers accepted by POST /api/sessions/{key}/permission.
+var decisions = map[string]permission.Decision{
+	"allow":  permission.Allow,
+	"always": permission.AlwaysAllow,
+	"deny":   permission.Deny,
+}
+
+// permitRequest is the body of POST /api/sessions/{key}/permission.
+type permitRequest struct {
+	Decision string `json:"decision"`
+}
+
+// permit answers the permission request a session is waiting on.
+func (s *Server) permit(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	var req permitRequest
+	d, valid := permission.Deny, false
+	if err := json.NewDecoder(r.Body).Decode(&req); err == nil {
+		d, valid = decisions[req.Decision]
+	}
+	if !valid {
+		http.Error(w, "expected {\"decision\": \"allow\", \"always\" or \"deny\"}", http.StatusBadRequest)
+		return
+	}
+	var ok bool
+	if !s.do(w, r, func(m *session.Manager) { ok = m.Permit(key, d) }) {
+		return
+	}
+	if !ok {
+		http.Error(w, "session is not asking for permission", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// followUpRequest is the body of POST /api/sessions/{key}/follow-up.
+type followUpRequest struct {
+	Text string `json:"text"`
+}
+
+// followUp queues a message for a session's agent.
+func (s *Server) followUp(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	var req followUpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Text) == "" {
+		http.Error(w, "expected {\"text\": \"...\"}", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if !s.do(w, r, func(m *session.Manager) { err = m.FollowUp(key, strings.TrimSpace(req.Text)) }) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) cancel(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	var err error
+	if !s.do(w, r, func(m *session.Manager) { _, err = m.Cancel(key) }) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveMetrics writes the Prometheus metrics. They are process-wide, so
+// this needs no call to the Manager.
+func serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = telemetry.WriteMetrics(w)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tomfevang/go-work/internal/config"
+	"github.com/tomfevang/go-work/internal/session"
+)
+
+func TestAuth(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		header      map[string]string
+		want        int
+		wantMessage string
+	}{
+		{name: "bearer token", method: "GET", target: "/api/sessions", header: map[string]string{"Authorization": "Bearer secret"}, want: http.StatusOK},
+		{name: "token parameter", method: "GET", target: "/api/sessions/1/events?token=secret", want: http.StatusOK},
+		{name: "header over parameter", method: "GET", target: "/api/sessions?token=secret", header: map[string]string{"Authorization": "Bearer wrong"}, want: http.StatusUnauthorized},
+		{name: "no token", method: "GET", target: "/api/sessions", want: http.StatusUnauthorized, wantMessage: "unauthorized"},
+		{name: "wrong token", method: "GET", target: "/api/sessions", header: map[string]string{"Authorization": "Bearer secret2"}, want: http.StatusUnauthorized},
+		{name: "empty token", method: "GET", target: "/api/sessions?token=", want: http.StatusUnauthorized},
+		{name: "basic auth", method: "GET", target: "/api/sessions", header: map[string]string{"Authorization": "Basic c2VjcmV0"}, want: http.StatusUnauthorized},
+		{
+			name: "same origin", method: "GET", target: "/api/sessions?token=secret",
+			header: map[string]string{"Origin": "http://go-work.test:7070"}, want: http.StatusOK,
+		},
+		{
+			name: "same origin over https", method: "GET", target: "/api/sessions?token=secret",
+			header: map[string]string{"Origin": "https://go-work.test:7070"}, want: http.StatusOK,
+		},
+		{
+			name: "foreign origin", method: "GET", target: "/api/sessions?token=secret",
+			header: map[string]string{"Origin": "https://evil.example"}, want: http.StatusForbidden, wantMessage: "cross-origin",
+		},
+		{
+			name: "other port", method: "GET", target: "/api/sessions?token=secret",
+			header: map[string]string{"Origin": "http://go-work.test:8080"}, want: http.StatusForbidden,
+		},
+		{
+			name: "foreign origin without a token", method: "GET", target: "/api/sessions",
+			header: map[string]string{"Origin": "https://evil.example"}, want: http.StatusForbidden,
+		},
+		{
+			name: "JSON post", method: "POST", target: "/api/sessions/1/cancel",
+			header: map[string]string{"Authorization": "Bearer secret", "Content-Type": "application/json; charset=utf-8"}, want: http.StatusOK,
+		},
+		{
+			name: "form post", method: "POST", target: "/api/sessions/1/cancel",
+			header:      map[string]string{"Authorization": "Bearer secret", "Content-Type": "application/x-www-form-urlencoded"},
+			want:        http.StatusUnsupportedMediaType,
+			wantMessage: "application/json",
+		},
+		{
+			name: "text post", method: "POST", target: "/api/sessions/1/cancel?token=secret",
+			header: map[string]string{"Content-Type": "text/plain"}, want: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "post without a type", method: "POST", target: "/api/sessions/1/cancel",
+			header: map[string]string{"Authorization": "Bearer secret"}, want: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "JSON post without a token", method: "POST", target: "/api/sessions/1/cancel",
+			header: map[string]string{"Content-Type": "application/json"}, want: http.StatusUnauthorized,
+		},
+	}
+	s := New(config.Server{Addr: "127.0.0.1:0", Token: "secret"}, nil)
+	for _, tt := range tests {
+		var served bool
+		h := s.auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { served = true }))
+		req := httptest.NewRequest(tt.method, "http://go-work.test:7070"+tt.target, strings.NewReader("{}"))
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if served != (tt.want == http.StatusOK) {
+			t.Errorf("%s: served = %v with status %d", tt.name, served, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), tt.wantMessage) {
+			t.Errorf("%s: body %q, want it to mention %q", tt.name, rec.Body.String(), tt.wantMessage)
+		}
+	}
+}
+
+func TestStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := New(config.Server{Addr: "127.0.0.1:0"}, nil).Start(ctx); err == nil {
+		t.Error("Start without a token succeeded")
+	}
+
+	s := New(config.Server{Addr: "127.0.0.1:0", Token: "secret"}, nil)
+	addr, err := s.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		m := session.NewManager(nil)
+		for c := range s.Calls() {
+			c.Run(m)
+		}
+	}()
+
+	get := func(target, token string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest("GET", "http://"+addr+target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	if code, _ := get("/", ""); code != http.StatusUnauthorized {
+		t.Errorf("dashboard without a token: status %d, want 401", code)
+	}
+	if code, _ := get("/api/sessions", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("sessions with a wrong token: status %d, want 401", code)
+	}
+	if code, body := get("/api/sessions", "secret"); code != http.StatusOK || strings.TrimSpace(body) != "[]" {
+		t.Errorf("sessions: status %d, body %q, want 200 and no sessions", code, body)
+	}
+}
@@ -0,0 +1,228 @@
+// Package cli implements go-work's subcommands. Besides the daemon, they
+// work on the sessions persisted in the repo's state directory and reach the
+// process running them, the TUI or daemon, through its socket, so they can
+// be scripted or used from another terminal.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/tomfevang/go-work/internal/config"
+	"github.com/tomfevang/go-work/internal/daemon"
+	"github.com/tomfevang/go-work/internal/forge"
+	"github.com/tomfevang/go-work/internal/permission"
+	"github.com/tomfevang/go-work/internal/session"
+	"github.com/tomfevang/go-work/internal/telemetry"
+	"github.com/tomfevang/go-work/internal/workspace"
+)
+
+// command is a subcommand. run receives the arguments after its name.
+// Commands without a summary are internal and left out of the usage.
+type command struct {
+	name, args, summary string
+	run                 func(e *env, args []string) error
+}
+
+var commands []command
+
+func init() {
+	// Assigned in init to break the initialization cycle through flags,
+	// which reads it.
+	commands = []command{
+		{"list", "[flags]", "list open issues and local tasks with their session state", runList},
+		{"start", "[flags] <issue>...", "start sessions, in the running TUI or daemon if there is one", runStart},
+		{"status", "[flags] [issue]...", "show sessions and their state", runStatus},
+		{"logs", "[flags] <issue>", "print a session's log", runLogs},
+		{"approve", "[flags] <issue>", "approve or reject a session's plan, or its push after failed checks", runApprove},
+		{"clean", "[flags] [issue]...", "remove the worktrees and records of finished sessions", runClean},
+		{"doctor", "", "check that go-work's dependencies are set up", runDoctor},
+		{"daemon", "", "work on labeled issues unattended", runDaemon},
+		{"config", "show", "print the effective configuration", runConfig},
+		{permission.Command, "<socket>", "", runPermissionPrompt},
+	}
+}
+
+// Has reports whether name is a subcommand.
+func Has(name string) bool {
+	return name == "help" || name == "-h" || name == "--help" ||
+		slices.ContainsFunc(commands, func(c command) bool { return c.name == name })
+}
+
+// Run runs the subcommand named by args[0] for the repo or workspace at dir.
+func Run(dir string, args []string) error {
+	i := slices.IndexFunc(commands, func(c command) bool { return c.name == args[0] })
+	if i < 0 {
+		usage(os.Stdout)
+		return nil
+	}
+	e := &env{dir: dir, out: os.Stdout}
+	if err := commands[i].run(e, args[1:]); !errors.Is(err, flag.ErrHelp) {
+		return err
+	}
+	return nil
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: go-work [command]")
+	fmt.Fprintln(w, "\nWithout a command, go-work starts the TUI. Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, c := range commands {
+		if c.summary == "" {
+			continue
+		}
+		fmt.Fprintf(tw, "  %s %s\t%s\n", c.name, c.args, c.summary)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nIssues are given as numbers (12 or #12) or local task keys (task-2);")
+	fmt.Fprintln(w, "in a multi-repo workspace, prefixed with the repo (api#12, api/task-2).")
+	fmt.Fprintln(w, "Run go-work <command> -h for a command's flags.")
+}
+
+// env is what commands share: the repo or workspace, with its config and
+// forges, loaded on first use.
+type env struct {
+	dir string
+	out io.Writer
+	ws  *workspace.Workspace
+	cfg *config.Config
+}
+
+func (e *env) workspace() (*workspace.Workspace, error) {
+	if e.ws == nil {
+		ws, err := workspace.Load(e.dir)
+		if errors.Is(err, workspace.ErrNoRepo) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("loading config: %w", err)
+		}
+		e.ws, e.cfg = ws, ws.Config
+	}
+	return e.ws, nil
+}
+
+func (e *env) config() (*config.Config, error) {
+	if _, err := e.workspace(); err != nil {
+		return nil, err
+	}
+	return e.cfg, nil
+}
+
+func (e *env) forge() (forge.Forge, error) {
+	ws, err := e.workspace()
+	if err != nil {
+		return nil, err
+	}
+	if err := ws.DetectForges(); err != nil {
+		return nil, fmt.Errorf("detecting forge: %w", err)
+	}
+	return ws.Forge, nil
+}
+
+// flags returns a flag set for the named command whose usage line lists
+// its arguments.
+func flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		i := slices.IndexFunc(commands, func(c command) bool { return c.name == name })
+		fmt.Fprintf(fs.Output(), "Usage: go-work %s %s\n\n%s.\n", name, commands[i].args, commands[i].summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses args into fs, requiring between min and max positional
+// arguments; max < 0 means no limit.
+func parse(fs *flag.FlagSet, args []string, min, max int) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if n := fs.NArg(); n < min || max >= 0 && n > max {
+		fs.Usage()
+		return fmt.Errorf("%s: wrong number of arguments", fs.Name())
+	}
+	return nil
+}
+
+// signalContext returns a context cancelled on interrupt or termination.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// withTelemetry runs fn with trace export set up as configured.
+func withTelemetry(cfg *config.Config, fn func() error) error {
+	shutdown, err := telemetry.Setup(cfg.Telemetry.Traces)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+	return fn()
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func runDaemon(e *env, args []string) error {
+	if err := parse(flags("daemon"), args, 0, 0); err != nil {
+		return err
+	}
+	if _, err := e.forge(); err != nil {
+		return err
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	return withTelemetry(e.cfg, func() error {
+		return daemon.New(e.ws, os.Stderr).Run(ctx)
+	})
+}
+
+func runConfig(e *env, args []string) error {
+	fs := flags("config")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	if fs.Arg(0) != "show" {
+		fs.Usage()
+		return fmt.Errorf("config: unknown action %q", fs.Arg(0))
+	}
+	cfg, err := e.config()
+	if err != nil {
+		return err
+	}
+	return cfg.Show(e.out)
+}
+
+// runPermissionPrompt serves the MCP permission tool claude is started
+// with, on claude's end of stdin and stdout.
+func runPermissionPrompt(e *env, args []string) error {
+	if err := parse(flags(permission.Command), args, 1, 1); err != nil {
+		return err
+	}
+	return permission.Serve(args[0], os.Stdin, os.Stdout)
+}
+
+// store opens the session store of the repo or workspace.
+func (e *env) store() (*session.Store, error) {
+	ws, err := e.workspace()
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ws.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	return session.OpenStore(dir)
+}
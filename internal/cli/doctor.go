@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/tomfevang/go-work/internal/api"
+	"github.com/tomfevang/go-work/internal/forge"
+)
+
+// check is one doctor check. It returns a short description of what it
+// found, or an error.
+type check struct {
+	name string
+	run  func(e *env) (string, error)
+}
+
+var checks = []check{
+	{"git", func(e *env) (string, error) { return version("git", "--version") }},
+	{"repository", checkRepository},
+	{"config", checkConfig},
+	{"agent", checkAgent},
+	{"sandbox", checkSandbox},
+	{"forge", checkForge},
+	{"sessions", checkRunning},
+}
+
+func runDoctor(e *env, args []string) error {
+	if err := parse(flags("doctor"), args, 0, 0); err != nil {
+		return err
+	}
+	var failed int
+	for _, c := range checks {
+		found, err := c.run(e)
+		if err != nil {
+			failed++
+			fmt.Fprintf(e.out, "✗ %s: %v\n", c.name, err)
+			continue
+		}
+		fmt.Fprintf(e.out, "✓ %s: %s\n", c.name, found)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+func version(name string, args ...string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s not found in PATH", name)
+	}
+	out, err := exec.Command(path, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s %s: %w", path, strings.Join(args, " "), err)
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return line, nil
+}
+
+// checkRepository reports the repo, or the repos of a workspace, and where
+// sessions are kept.
+func checkRepository(e *env) (string, error) {
+	ws, err := e.workspace()
+	if err != nil {
+		return "", err
+	}
+	dir, err := ws.StateDir()
+	if err != nil {
+		return "", err
+	}
+	if !ws.Multi() {
+		return fmt.Sprintf("%s; state in %s", ws.Dir, dir), nil
+	}
+	names := make([]string, len(ws.Repos))
+	for i, r := range ws.Repos {
+		names[i] = r.Name
+	}
+	return fmt.Sprintf("workspace of %s; state in %s", strings.Join(names, ", "), dir), nil
+}
+
+func checkConfig(e *env) (string, error) {
+	cfg, err := e.config()
+	if err != nil {
+		return "", err
+	}
+	if len(cfg.Sources) == 0 {
+		return "no config files; using defaults", nil
+	}
+	return strings.Join(cfg.Sources, ", "), nil
+}
+
+func checkAgent(e *env) (string, error) {
+	cfg, err := e.config()
+	if err != nil {
+		return "", fmt.Errorf("skipped: no valid config")
+	}
+	return version(cfg.Agent.Command, "--version")
+}
+
+// checkSandbox checks that the sandbox runtime of each repo is installed.
+func checkSandbox(e *env) (string, error) {
+	ws, err := e.workspace()
+	if err != nil {
+		return "", fmt.Errorf("skipped: no valid config")
+	}
+	var found []string
+	for _, r := range ws.Repos {
+		sb := r.Config.Agent.Sandbox
+		desc := "none"
+		if sb.Enabled() {
+			v, err := version(sb.Runtime, "--version")
+			if err != nil {
+				return "", err
+			}
+			desc = v
+			if !sb.Network {
+				desc += ", no network"
+			}
+		}
+		if r.Name != "" {
+			desc = r.Name + ": " + desc
+		}
+		found = append(found, desc)
+	}
+	return strings.Join(found, "; "), nil
+}
+
+// checkForge detects each repo's forge and lists an issue, which fails
+// without access to the repo or valid credentials.
+func checkForge(e *env) (string, error) {
+	if _, err := e.forge(); err != nil {
+		return "", err
+	}
+	var found []string
+	for _, r := range e.ws.Repos {
+		fg := r.Forge
+		name := fg.Name()
+		if r.Name != "" {
+			name = r.Name + ": " + name
+		}
+		if fg.Name() == "GitHub" {
+			if _, err := version("gh", "--version"); err != nil {
+				return "", fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		if _, err := fg.ListIssues(forge.Filter{}, 1); err != nil {
+			return "", fmt.Errorf("%s: listing issues: %w", name, err)
+		}
+		found = append(found, name)
+	}
+	return strings.Join(found, ", ") + ", issues accessible", nil
+}
+
+func checkRunning(e *env) (string, error) {
+	st, err := e.store()
+	if err != nil {
+		return "", err
+	}
+	recs, err := st.Load()
+	if err != nil {
+		return "", err
+	}
+	running := "no TUI or daemon running"
+	if _, err := api.Dial(st.SocketPath()); err == nil {
+		running = "a TUI or daemon is running"
+	}
+	return fmt.Sprintf("%d recorded; %s", len(recs), running), nil
+}
@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tomfevang/go-work/internal/forge"
+)
+
+// issueJSON is an issue in list -json output.
+type issueJSON struct {
+	Ref     string   `json:"ref"`
+	Key     string   `json:"key"`
+	Title   string   `json:"title"`
+	URL     string   `json:"url,omitempty"`
+	Labels  []string `json:"labels,omitempty"`
+	Session string   `json:"session,omitempty"` // state of its latest session
+}
+
+func runList(e *env, args []string) error {
+	fs := flags("list")
+	var f forge.Filter
+	var labels string
+	fs.StringVar(&labels, "label", "", "only issues with these `labels`, comma-separated")
+	fs.StringVar(&f.Assignee, "assignee", "", "only issues assigned to `user` (@me for yourself)")
+	fs.StringVar(&f.Milestone, "milestone", "", "only issues in `milestone`")
+	fs.StringVar(&f.Author, "author", "", "only issues opened by `user`")
+	fs.StringVar(&f.Search, "search", "", "free-text `query`")
+	limit := fs.Int("limit", 0, "maximum issues to list (default issues.limit)")
+	local := fs.Bool("local", true, "include local tasks")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if labels != "" {
+		f.Labels = strings.Split(labels, ",")
+	}
+
+	fg, err := e.forge()
+	if err != nil {
+		return err
+	}
+	if *limit <= 0 {
+		*limit = e.cfg.Issues.Limit
+	}
+	issues, err := fg.ListIssues(f, *limit)
+	if err != nil {
+		return err
+	}
+	if *local {
+		locals, err := e.ws.Tasks()
+		if err != nil {
+			return fmt.Errorf("load local tasks: %w", err)
+		}
+		issues = append(issues, locals...)
+	}
+
+	states := make(map[string]string)
+	if st, err := e.store(); err == nil {
+		recs, _ := st.Load()
+		for _, r := range recs {
+			states[r.Key] = r.State
+		}
+	}
+
+	list := make([]issueJSON, len(issues))
+	for i, iss := range issues {
+		list[i] = issueJSON{
+			Ref:     iss.Ref(),
+			Key:     iss.Key(),
+			Title:   iss.Title,
+			URL:     iss.URL,
+			Labels:  iss.LabelNames(),
+			Session: states[iss.Key()],
+		}
+	}
+	if *asJSON {
+		return writeJSON(e.out, list)
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ISSUE\tSESSION\tTITLE\tLABELS")
+	for _, iss := range list {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", iss.Ref, iss.Session, iss.Title, strings.Join(iss.Labels, ", "))
+	}
+	return tw.Flush()
+}
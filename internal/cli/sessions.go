@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tomfevang/go-work/internal/api"
+	"github.com/tomfevang/go-work/internal/session"
+	"github.com/tomfevang/go-work/internal/workspace"
+)
+
+// records returns the persisted sessions, limited to the referenced ones
+// if any are given. Unknown references are an error.
+func records(st *session.Store, refs []string) ([]session.Record, error) {
+	recs, err := st.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 {
+		return recs, nil
+	}
+	var out []session.Record
+	for _, ref := range refs {
+		i := slices.IndexFunc(recs, func(r session.Record) bool { return r.Key == workspace.KeyOf(ref) })
+		if i < 0 {
+			return nil, fmt.Errorf("no session for %s", ref)
+		}
+		out = append(out, recs[i])
+	}
+	return out, nil
+}
+
+// stateOf describes a record's state, noting sessions whose process exited
+// before they finished.
+func stateOf(r session.Record) string {
+	if r.Orphaned() {
+		return r.State + " (stopped)"
+	}
+	return r.State
+}
+
+func runStatus(e *env, args []string) error {
+	fs := flags("status")
+	asJSON := fs.Bool("json", false, "print JSON")
+	if err := parse(fs, args, 0, -1); err != nil {
+		return err
+	}
+	st, err := e.store()
+	if err != nil {
+		return err
+	}
+	recs, err := records(st, fs.Args())
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		if recs == nil {
+			recs = []session.Record{}
+		}
+		return writeJSON(e.out, recs)
+	}
+	if len(recs) == 0 {
+		fmt.Fprintln(e.out, "No sessions.")
+		return nil
+	}
+	tw := tabwriter.NewWriter(e.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ISSUE\tSTATE\tUPDATED\tTITLE\tRESULT")
+	for _, r := range recs {
+		result := r.PR
+		switch {
+		case r.Error != "":
+			result = r.Error
+		case r.State == session.Blocked.Name():
+			result = "pre-push checks failed; go-work approve to push anyway"
+		case result == "" && r.State == session.Done.Name():
+			result = "branch " + r.Branch
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Ref, stateOf(r), r.Updated.Format(time.DateTime), r.Title, result)
+	}
+	return tw.Flush()
+}
+
+func runLogs(e *env, args []string) error {
+	fs := flags("logs")
+	follow := fs.Bool("f", false, "keep printing output until the session finishes")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	st, err := e.store()
+	if err != nil {
+		return err
+	}
+	if _, err := records(st, fs.Args()); err != nil {
+		return err
+	}
+	key := workspace.KeyOf(fs.Arg(0))
+
+	var offset int64
+	for {
+		n, err := copyFrom(e.out, st.LogPath(key), offset)
+		if err != nil {
+			return err
+		}
+		offset = n
+		if !*follow {
+			return nil
+		}
+		recs, err := records(st, fs.Args())
+		if err != nil {
+			return err
+		}
+		if recs[0].Finished() || recs[0].Orphaned() {
+			_, err := copyFrom(e.out, st.LogPath(key), offset) // output written since
+			return err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// copyFrom copies the file at path from offset to w, returning the new
+// offset. A file shorter than offset was restarted and is copied in full.
+func copyFrom(w io.Writer, path string, offset int64) (int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.Size() < offset {
+		offset = 0
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, f)
+	return offset + n, err
+}
+
+func runApprove(e *env, args []string) error {
+	fs := flags("approve")
+	reject := fs.Bool("reject", false, "reject the plan, or the push of a blocked session, instead")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+	st, err := e.store()
+	if err != nil {
+		return err
+	}
+	c, err := api.Dial(st.SocketPath())
+	if err != nil {
+		return err
+	}
+	if err := c.Decide(workspace.KeyOf(fs.Arg(0)), !*reject); err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+	verb := "Approved"
+	if *reject {
+		verb = "Rejected"
+	}
+	fmt.Fprintf(e.out, "%s %s.\n", verb, fs.Arg(0))
+	return nil
+}
+
+func runClean(e *env, args []string) error {
+	fs := flags("clean")
+	dryRun := fs.Bool("n", false, "only print what would be removed")
+	branches := fs.Bool("branches", false, "also delete the local branches of failed sessions")
+	if err := parse(fs, args, 0, -1); err != nil {
+		return err
+	}
+	st, err := e.store()
+	if err != nil {
+		return err
+	}
+	recs, err := records(st, fs.Args())
+	if err != nil {
+		return err
+	}
+
+	var removed []string
+	roots := make(map[string]bool) // repos to prune worktrees in
+	for _, r := range recs {
+		if !r.Finished() && !r.Orphaned() {
+			if len(fs.Args()) > 0 {
+				fmt.Fprintf(e.out, "Skipping %s: still %s.\n", r.Ref, r.State)
+			}
+			continue
+		}
+		fmt.Fprintf(e.out, "Removing %s (%s): %s\n", r.Ref, stateOf(r), r.Worktree)
+		removed = append(removed, r.Key)
+		if *dryRun {
+			continue
+		}
+		root := r.RepoRoot
+		roots[root] = true
+		if _, err := os.Stat(r.Worktree); err == nil {
+			if out, err := exec.Command("git", "-C", root, "worktree", "remove", "--force", r.Worktree).CombinedOutput(); err != nil {
+				return fmt.Errorf("remove worktree of %s: %s", r.Ref, strings.TrimSpace(string(out)))
+			}
+		}
+		if *branches && r.State == session.Failed.Name() && r.Branch != "" {
+			_ = exec.Command("git", "-C", root, "branch", "-D", r.Branch).Run() // may never have been created
+		}
+	}
+	if *dryRun || len(removed) == 0 {
+		return nil
+	}
+	for root := range roots {
+		_ = exec.Command("git", "-C", root, "worktree", "prune").Run()
+	}
+	return st.Remove(removed)
+}
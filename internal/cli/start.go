@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tomfevang/go-work/internal/api"
+	"github.com/tomfevang/go-work/internal/notify"
+	"github.com/tomfevang/go-work/internal/session"
+)
+
+func runStart(e *env, args []string) error {
+	fs := flags("start")
+	approve := fs.Bool("approve", false, "approve every plan without review (when running sessions in the foreground)")
+	if err := parse(fs, args, 1, -1); err != nil {
+		return err
+	}
+	st, err := e.store()
+	if err != nil {
+		return err
+	}
+
+	if c, err := api.Dial(st.SocketPath()); err == nil {
+		if *approve {
+			return fmt.Errorf("start: -approve only applies when no TUI or daemon is running")
+		}
+		keys, err := c.Start(fs.Args())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.out, "Started %s in the running go-work.\n", strings.Join(keys, ", "))
+		return nil
+	}
+
+	// Nothing else is running sessions here, so run them in the foreground.
+	if _, err := e.forge(); err != nil {
+		return err
+	}
+	issues, err := e.ws.Resolve(fs.Args())
+	if err != nil {
+		return err
+	}
+	ctx, stop := signalContext()
+	defer stop()
+	return withTelemetry(e.cfg, func() error {
+		return e.foreground(ctx, st, issues, *approve)
+	})
+}
+
+// foreground runs sessions for issues until all are finished, printing
+// their progress. Plans are approved automatically with autoApprove, and
+// otherwise through the socket, with go-work approve.
+func (e *env) foreground(ctx context.Context, st *session.Store, issues []session.Issue, autoApprove bool) error {
+	mgr := session.NewManager(e.ws.Envs())
+	defer mgr.Close()
+	mgr.SetStore(st)
+	srv := api.New(e.cfg.Server, e.ws.Resolve)
+	if err := srv.StartSocket(ctx, st.SocketPath()); err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+	if e.cfg.Server.Addr != "" {
+		addr, err := srv.Start(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(e.out, "Web dashboard on http://%s\n", addr)
+	}
+	notifier := notify.New(e.cfg.Notify, os.Stderr, func(err error) { fmt.Fprintln(os.Stderr, err) })
+
+	seen := make(map[string]session.State)
+	report := func(keys []string) {
+		for _, key := range keys {
+			s := mgr.Get(key)
+			notifier.Observe(s)
+			if prev, ok := seen[key]; ok && prev == s.State {
+				continue
+			}
+			seen[key] = s.State
+			switch s.State {
+			case session.WaitingApproval:
+				fmt.Fprintf(e.out, "%s: plan ready\n\n%s\n\n", s.Issue.Ref(), strings.TrimSpace(s.Plan))
+				if !autoApprove {
+					fmt.Fprintf(e.out, "Approve with: go-work approve %s (or -reject)\n", s.Issue.Ref())
+				}
+			case session.Blocked:
+				fmt.Fprintf(e.out, "%s: push blocked by the pre-push checks\n\n%s\n", s.Issue.Ref(), s.Findings)
+				fmt.Fprintf(e.out, "Push anyway with: go-work approve %s (or -reject)\n", s.Issue.Ref())
+			case session.Done:
+				result := s.PR
+				if result == "" {
+					result = "committed on branch " + s.Branch
+				}
+				fmt.Fprintf(e.out, "%s: done: %s\n", s.Issue.Ref(), result)
+			case session.Failed:
+				fmt.Fprintf(e.out, "%s: failed: %v\n", s.Issue.Ref(), s.Err)
+			default:
+				fmt.Fprintf(e.out, "%s: %s\n", s.Issue.Ref(), strings.ToLower(s.State.String()))
+			}
+		}
+	}
+
+	report(mgr.Add(issues))
+	for unfinished(mgr) > 0 {
+		select {
+		case <-ctx.Done():
+			for _, key := range mgr.Order() {
+				_, _ = mgr.Cancel(key) // fails only for finished sessions
+			}
+			return fmt.Errorf("interrupted; unfinished sessions were cancelled")
+		case c := <-srv.Calls():
+			c.Run(mgr)
+			report(mgr.Order())
+		case ev := <-mgr.Events():
+			report(mgr.Handle(ev))
+			srv.Publish(ev)
+			if ev.Type == session.EventPlanDone && autoApprove && mgr.Approve(ev.Key, true) {
+				report(mgr.Order())
+			}
+		}
+	}
+
+	var failed int
+	for _, key := range mgr.Order() {
+		if mgr.Get(key).State == session.Failed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sessions failed", failed, len(mgr.Order()))
+	}
+	return nil
+}
+
+func unfinished(mgr *session.Manager) int {
+	n := 0
+	for _, key := range mgr.Order() {
+		switch mgr.Get(key).State {
+		case session.Done, session.Failed, session.Merged:
+		default:
+			n++
+		}
+	}
+	return n
+}
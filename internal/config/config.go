@@ -0,0 +1,531 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tomfevang/go-work/internal/forge"
+	"github.com/tomfevang/go-work/internal/policy"
+	"github.com/tomfevang/go-work/internal/secrets"
+	"github.com/tomfevang/go-work/internal/session"
+	"github.com/tomfevang/go-work/internal/tasks"
+	"github.com/tomfevang/go-work/internal/telemetry"
+)
+
+// FileName is the repo-level config file, looked up in the repo root.
+const FileName = ".go-work.yaml"
+
+// UserFile returns the path of the user-level config file,
+// $XDG_CONFIG_HOME/go-work/config.yaml or the platform equivalent. It
+// returns "" if the platform has no user config directory.
+func UserFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "go-work", "config.yaml")
+}
+
+// Config is the effective configuration: the defaults, overlaid by the
+// user-level file, overlaid by the repo's .go-work.yaml.
+type Config struct {
+	// Sources are the files the config was read from, lowest precedence
+	// first.
+	Sources []string `yaml:"-"`
+
+	// Forge overrides detection of the code host from the origin remote.
+	Forge forge.Settings `yaml:"forge"`
+
+	// Issues configures the issue listing.
+	Issues Issues `yaml:"issues"`
+
+	// Agent configures the claude command, its tools and prompts.
+	Agent session.AgentSettings `yaml:"agent"`
+
+	// Worktrees configures where sessions work and their branch names.
+	Worktrees session.WorktreeSettings `yaml:"worktrees"`
+
+	// Filters are saved issue filter presets, cycled in the issue selector.
+	Filters []FilterPreset `yaml:"filters"`
+
+	// Tasks configures local work items listed alongside forge issues.
+	Tasks tasks.Settings `yaml:"tasks"`
+
+	// Dependencies configures how sessions for dependent issues are run.
+	Dependencies Dependencies `yaml:"dependencies"`
+
+	// PullRequests configures how finished sessions are turned into PRs.
+	PullRequests PullRequests `yaml:"pull_requests"`
+
+	// Secrets configures the scan for secrets before sessions push.
+	Secrets secrets.Settings `yaml:"secrets"`
+
+	// Policy configures the guardrails sessions' changes are checked
+	// against before they push.
+	Policy policy.Settings `yaml:"policy"`
+
+	// Daemon configures `go-work daemon`.
+	Daemon Daemon `yaml:"daemon"`
+
+	// Server configures the optional HTTP API and web dashboard.
+	Server Server `yaml:"server"`
+
+	// Notify configures notifications on session state changes.
+	Notify Notify `yaml:"notify"`
+
+	// Telemetry configures trace export. Metrics are always collected and
+	// served by the HTTP API.
+	Telemetry Telemetry `yaml:"telemetry"`
+
+	// Workspace lists the repos to work on together when go-work runs
+	// outside any git repo.
+	Workspace Workspace `yaml:"workspace"`
+
+	// TrustedRepos lists the roots of repos whose .go-work.yaml may set
+	// restricted keys. ~/ is expanded.
+	TrustedRepos []string `yaml:"trusted_repos"`
+}
+
+// restricted are the keys a repo's .go-work.yaml may only set if the user
+// file trusts the repo. They run commands or send tokens and session data
+// outside the sandbox, pick the directories and branches sessions write to
+// and delete, or turn off the checks guarding against the agent, so anyone
+// able to push to the repo could otherwise take over go-work.
+var restricted = map[string]bool{
+	"forge.url": true, "forge.token_env": true,
+	"worktrees.dir": true, "worktrees.branch": true,
+	"agent.command": true, "agent.sandbox": true, "agent.permission_prompts": true,
+	"secrets": true, "policy": true,
+	"daemon.approval": true, "daemon.webhook": true,
+	"server": true, "notify.commands": true, "notify.webhooks": true,
+	"telemetry": true, "trusted_repos": true,
+}
+
+func init() {
+	// Profiles may hand the agent any tool or command line.
+	for _, phase := range []string{"plan", "implement", "resolve"} {
+		for _, key := range []string{"allowed_tools", "bash", "mcp_config", "args"} {
+			restricted["agent."+phase+"."+key] = true
+		}
+	}
+}
+
+// Workspace configures a multi-repo workspace.
+type Workspace struct {
+	Repos []WorkspaceRepo `yaml:"repos"`
+}
+
+// WorkspaceRepo is one repo of a workspace. Each repo's own .go-work.yaml
+// applies to its sessions.
+type WorkspaceRepo struct {
+	// Path is the repo's directory, relative to the directory go-work runs
+	// in unless absolute or starting with ~/.
+	Path string `yaml:"path"`
+	// Name tags the repo's issues and sessions, as in "api#12". It defaults
+	// to the directory's base name.
+	Name string `yaml:"name"`
+}
+
+// RepoName returns the repo's name.
+func (r WorkspaceRepo) RepoName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return filepath.Base(filepath.Clean(r.Path))
+}
+
+// Issues configures the issue listing.
+type Issues struct {
+	// Limit is how many more issues are fetched each time the cursor
+	// reaches the end of the list.
+	Limit int `yaml:"limit"`
+}
+
+// Telemetry configures observability output.
+type Telemetry struct {
+	Traces telemetry.TraceSettings `yaml:"traces"`
+}
+
+// Notify configures the notifications sent when sessions change state. Each
+// channel lists the states it fires on, by name ("waiting_approval",
+// "done", "failed", …); none fire by default.
+type Notify struct {
+	// Bell rings the terminal bell.
+	Bell []string `yaml:"bell"`
+	// Commands run shell commands, with the session in GO_WORK_*
+	// environment variables.
+	Commands []NotifyCommand `yaml:"commands"`
+	// Webhooks post a JSON payload whose "text" field makes it acceptable
+	// to Slack, Teams and Mattermost incoming webhooks.
+	Webhooks []NotifyWebhook `yaml:"webhooks"`
+}
+
+// NotifyCommand is a shell command run on state changes.
+type NotifyCommand struct {
+	Run string   `yaml:"run"`
+	On  []string `yaml:"on"`
+}
+
+// NotifyWebhook is a URL posted to on state changes. Header values may
+// reference environment variables, e.g. "Bearer ${HOOK_TOKEN}".
+type NotifyWebhook struct {
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	On      []string          `yaml:"on"`
+}
+
+// Server configures the HTTP API and web dashboard served alongside the TUI
+// or daemon.
+type Server struct {
+	// Addr is the address to listen on, e.g. "127.0.0.1:7070". Empty
+	// disables the server.
+	Addr string `yaml:"addr"`
+	// Token must be sent as a bearer token or token query parameter with
+	// every request. It is required to listen on Addr.
+	Token string `yaml:"token"`
+}
+
+// PullRequests configures pull request creation.
+type PullRequests struct {
+	// Batch stops sessions once their work is committed, without opening a
+	// PR, so finished sessions can be combined into batch PRs from the
+	// dashboard.
+	Batch bool `yaml:"batch"`
+	// Title and Body optionally template the PR text.
+	session.PRTemplates `yaml:",inline"`
+}
+
+// Dependencies configures sessions for issues that say they are blocked by,
+// or depend on, other selected issues. Such sessions always wait for their
+// prerequisites to finish.
+type Dependencies struct {
+	// Stack branches dependents off their prerequisites' branches and
+	// targets their PRs at them, instead of the default branch.
+	Stack bool `yaml:"stack"`
+}
+
+// Daemon configures the long-running mode that picks up labeled issues
+// without the interactive issue selector.
+type Daemon struct {
+	// Label marks the issues the daemon works on.
+	Label string `yaml:"label"`
+	// Interval is how often the forge is polled for labeled issues and
+	// approval labels.
+	Interval time.Duration `yaml:"interval"`
+	// MaxSessions caps the sessions in progress at once.
+	MaxSessions int `yaml:"max_sessions"`
+	// Approval is how plans are approved: "auto" approves every plan,
+	// "label" waits for ApproveLabel or RejectLabel on the issue.
+	Approval     string `yaml:"approval"`
+	ApproveLabel string `yaml:"approve_label"`
+	RejectLabel  string `yaml:"reject_label"`
+	// Webhook optionally listens for forge webhooks, which trigger an
+	// immediate poll instead of waiting for the next interval.
+	Webhook Webhook `yaml:"webhook"`
+}
+
+// Webhook configures the daemon's webhook listener.
+type Webhook struct {
+	// Addr is the address to listen on, e.g. ":8089". Empty disables it.
+	Addr string `yaml:"addr"`
+	// Secret verifies deliveries: the HMAC signature for GitHub and Gitea,
+	// the token header for GitLab.
+	Secret string `yaml:"secret"`
+}
+
+// Approval modes for Daemon.Approval.
+const (
+	ApprovalAuto  = "auto"
+	ApprovalLabel = "label"
+)
+
+// defaults returns the configuration used for keys missing from the file.
+func defaults() Config {
+	return Config{
+		Issues:    Issues{Limit: 50},
+		Agent:     session.DefaultAgent(),
+		Worktrees: session.DefaultWorktrees(),
+		Tasks:     tasks.Settings{TODOs: true},
+		Secrets:   secrets.Defaults(),
+		Policy:    policy.Defaults(),
+		Daemon: Daemon{
+			Label:        "go-work",
+			Interval:     time.Minute,
+			MaxSessions:  2,
+			Approval:     ApprovalLabel,
+			ApproveLabel: "go-work:approved",
+			RejectLabel:  "go-work:rejected",
+		},
+	}
+}
+
+// FilterPreset is a named, reusable issue filter.
+type FilterPreset struct {
+	Name         string `yaml:"name"`
+	forge.Filter `yaml:",inline"`
+}
+
+// Load reads the user-level config file and then repoRoot's, each
+// overriding the keys it sets. Missing files are not an error; with neither,
+// Load yields the defaults.
+func Load(repoRoot string) (*Config, error) {
+	cfg := defaults()
+	user := UserFile()
+	for _, path := range []string{user, filepath.Join(repoRoot, FileName)} {
+		if path == "" {
+			continue
+		}
+		ok, err := cfg.load(path, path != user && !cfg.trusts(repoRoot))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			cfg.Sources = append(cfg.Sources, path)
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		source := "config"
+		if n := len(cfg.Sources); n > 0 {
+			source = strings.Join(cfg.Sources, ", ")
+		}
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return &cfg, nil
+}
+
+// load decodes the file at path over cfg, reporting whether it exists.
+// Unknown keys are errors, to catch typos, and so are restricted keys in an
+// untrusted file.
+func (cfg *Config) load(path string, untrusted bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if untrusted {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return false, fmt.Errorf("parse %s: %w", path, err)
+		}
+		if key := restrictedKey(&doc, ""); key != "" {
+			return false, fmt.Errorf("%s: %s may only be set in the user config file %s, or with the repo listed in its trusted_repos", path, key, UserFile())
+		}
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return true, nil
+}
+
+// restrictedKey returns the first restricted key set in the YAML node n,
+// whose keys are under prefix.
+func restrictedKey(n *yaml.Node, prefix string) string {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return restrictedKey(n.Alias, prefix)
+	case yaml.DocumentNode, yaml.SequenceNode: // sequences only as merged maps
+		for _, c := range n.Content {
+			if found := restrictedKey(c, prefix); found != "" {
+				return found
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if k := n.Content[i].Value; k == "<<" {
+				if found := restrictedKey(n.Content[i+1], prefix); found != "" {
+					return found
+				}
+				continue
+			}
+			key := prefix + n.Content[i].Value
+			if restricted[key] {
+				return key
+			}
+			if v := n.Content[i+1]; v.Kind != yaml.SequenceNode {
+				if found := restrictedKey(v, key+"."); found != "" {
+					return found
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// trusts reports whether TrustedRepos lists repoRoot.
+func (cfg *Config) trusts(repoRoot string) bool {
+	root, err := filepath.Abs(repoRoot)
+	if err != nil {
+		return false
+	}
+	home, _ := os.UserHomeDir()
+	for _, p := range cfg.TrustedRepos {
+		if rest, ok := strings.CutPrefix(p, "~/"); ok && home != "" {
+			p = filepath.Join(home, rest)
+		}
+		if abs, err := filepath.Abs(p); err == nil && abs == root {
+			return true
+		}
+	}
+	return false
+}
+
+// validate reports the first invalid setting, prefixed with its key.
+func (cfg *Config) validate() error {
+	if cfg.Issues.Limit <= 0 {
+		return fmt.Errorf("issues.limit: must be positive")
+	}
+	if err := cfg.Agent.Validate(); err != nil {
+		return fmt.Errorf("agent.%w", err)
+	}
+	if err := cfg.Worktrees.Validate(); err != nil {
+		return fmt.Errorf("worktrees.%w", err)
+	}
+	if err := cfg.PullRequests.Validate(); err != nil {
+		return fmt.Errorf("pull_requests.%w", err)
+	}
+	if err := cfg.Secrets.Validate(); err != nil {
+		return fmt.Errorf("secrets.%w", err)
+	}
+	if err := cfg.Policy.Validate(); err != nil {
+		return fmt.Errorf("policy.%w", err)
+	}
+	for i, p := range cfg.Filters {
+		if p.Name == "" {
+			return fmt.Errorf("filters[%d]: name is required", i)
+		}
+	}
+	switch cfg.Daemon.Approval {
+	case ApprovalAuto, ApprovalLabel:
+	default:
+		return fmt.Errorf("daemon.approval: must be %q or %q, not %q", ApprovalAuto, ApprovalLabel, cfg.Daemon.Approval)
+	}
+	if cfg.Daemon.Interval <= 0 {
+		return fmt.Errorf("daemon.interval: must be positive")
+	}
+	if cfg.Daemon.MaxSessions < 0 {
+		return fmt.Errorf("daemon.max_sessions: must not be negative")
+	}
+	if cfg.Server.Addr != "" && cfg.Server.Token == "" {
+		return fmt.Errorf("server.token: required when server.addr is set")
+	}
+	if err := cfg.Notify.validate(); err != nil {
+		return fmt.Errorf("notify.%w", err)
+	}
+	if err := cfg.Workspace.validate(); err != nil {
+		return fmt.Errorf("workspace.%w", err)
+	}
+	return nil
+}
+
+var repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+func (w Workspace) validate() error {
+	seen := make(map[string]bool)
+	for i, r := range w.Repos {
+		if r.Path == "" {
+			return fmt.Errorf("repos[%d]: path is required", i)
+		}
+		name := r.RepoName()
+		if !repoNamePattern.MatchString(name) {
+			return fmt.Errorf("repos[%d]: name %q may only contain letters, digits, '.', '_' and '-'", i, name)
+		}
+		if seen[name] {
+			return fmt.Errorf("repos[%d]: name %q is used twice", i, name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// Env returns the environment sessions in repoRoot run in.
+func (cfg *Config) Env(repoRoot string, fg forge.Forge) session.Env {
+	return session.Env{
+		RepoRoot:  repoRoot,
+		Forge:     fg,
+		Agent:     cfg.Agent,
+		Worktrees: cfg.Worktrees,
+		PRs:       cfg.PullRequests.PRTemplates,
+		LocalPRs:  cfg.Tasks.CreatePR,
+		Stack:     cfg.Dependencies.Stack,
+		BatchPRs:  cfg.PullRequests.Batch,
+		Secrets:   cfg.Secrets,
+		Policy:    cfg.Policy,
+	}
+}
+
+// Show writes the effective config as YAML, secrets masked, preceded by
+// comments naming the files it was read from.
+func (cfg *Config) Show(w io.Writer) error {
+	if len(cfg.Sources) == 0 {
+		fmt.Fprintln(w, "# no config files found; showing defaults")
+	}
+	for _, path := range cfg.Sources {
+		fmt.Fprintf(w, "# from %s\n", path)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(cfg.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Redacted returns a copy of cfg with secrets masked, for display.
+func (cfg *Config) Redacted() *Config {
+	c := *cfg
+	mask := func(s *string) {
+		if *s != "" {
+			*s = "<redacted>"
+		}
+	}
+	mask(&c.Server.Token)
+	mask(&c.Daemon.Webhook.Secret)
+	return &c
+}
+
+func (n Notify) validate() error {
+	check := func(field string, states []string) error {
+		for _, name := range states {
+			if _, err := session.ParseState(name); err != nil {
+				return fmt.Errorf("%s: %w", field, err)
+			}
+		}
+		return nil
+	}
+	if err := check("bell", n.Bell); err != nil {
+		return err
+	}
+	for i, c := range n.Commands {
+		if c.Run == "" {
+			return fmt.Errorf("commands[%d]: run is required", i)
+		}
+		if err := check(fmt.Sprintf("commands[%d].on", i), c.On); err != nil {
+			return err
+		}
+	}
+	for i, w := range n.Webhooks {
+		if w.URL == "" {
+			return fmt.Errorf("webhooks[%d]: url is required", i)
+		}
+		if err := check(fmt.Sprintf("webhooks[%d].on", i), w.On); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+// Package daemon runs go-work unattended: it polls the forge for labeled
+// issues, starts sessions for them under a concurrency limit, and approves
+// plans automatically or when the issue is labeled.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"slices"
+	"time"
+
+	"github.com/tomfevang/go-work/internal/api"
+	"github.com/tomfevang/go-work/internal/config"
+	"github.com/tomfevang/go-work/internal/forge"
+	"github.com/tomfevang/go-work/internal/notify"
+	"github.com/tomfevang/go-work/internal/session"
+	"github.com/tomfevang/go-work/internal/workspace"
+)
+
+// pollLimit is the number of labeled issues fetched per poll.
+const pollLimit = 100
+
+// Daemon picks up labeled issues and drives their sessions.
+type Daemon struct {
+	ws       *workspace.Workspace
+	cfg      config.Daemon
+	forge    forge.Forge
+	mgr      *session.Manager
+	srv      *api.Server
+	addr     string // HTTP API address, if enabled
+	notifier *notify.Notifier
+	log      *log.Logger
+	states   map[string]session.State // last logged state per session
+	poke     chan struct{}            // requests an immediate poll
+}
+
+// New returns a daemon for the workspace that logs to out. The workspace's
+// forges must have been detected.
+func New(ws *workspace.Workspace, out io.Writer) *Daemon {
+	cfg := ws.Config
+	mgr := session.NewManager(ws.Envs())
+	mgr.SetLimit(cfg.Daemon.MaxSessions)
+	logger := log.New(out, "", log.LstdFlags)
+	return &Daemon{
+		ws:       ws,
+		cfg:      cfg.Daemon,
+		forge:    ws.Forge,
+		mgr:      mgr,
+		srv:      api.New(cfg.Server, ws.Resolve),
+		addr:     cfg.Server.Addr,
+		notifier: notify.New(cfg.Notify, out, func(err error) { logger.Print(err) }),
+		log:      logger,
+		states:   make(map[string]session.State),
+		poke:     make(chan struct{}, 1),
+	}
+}
+
+// Run polls and handles session events until ctx is cancelled. Errors from
+// the forge are logged and retried on the next poll; only a failure to set
+// up the session store, webhook listener or API server is returned.
+func (d *Daemon) Run(ctx context.Context) error {
+	dir, err := d.ws.StateDir()
+	if err != nil {
+		return err
+	}
+	st, err := session.OpenStore(dir)
+	if err != nil {
+		return err
+	}
+	d.mgr.SetStore(st)
+	defer d.mgr.Close()
+	if err := d.srv.StartSocket(ctx, st.SocketPath()); err != nil {
+		return err
+	}
+	if d.cfg.Webhook.Addr != "" {
+		if err := d.listen(ctx); err != nil {
+			return err
+		}
+	}
+	if d.addr != "" {
+		addr, err := d.srv.Start(ctx)
+		if err != nil {
+			return err
+		}
+		d.log.Printf("web dashboard on http://%s", addr)
+	}
+	calls := d.srv.Calls()
+	d.log.Printf("watching %s issues labeled %q (approval: %s)", d.forge.Name(), d.cfg.Label, d.cfg.Approval)
+
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+	d.poll()
+	for {
+		select {
+		case <-ctx.Done():
+			d.log.Printf("stopping; %d session(s) still in progress are abandoned", d.inProgress())
+			return nil
+		case <-ticker.C:
+			d.poll()
+		case <-d.poke:
+			d.poll()
+		case c := <-calls:
+			c.Run(d.mgr)
+			d.report(d.mgr.Order())
+		case ev := <-d.mgr.Events():
+			d.report(d.mgr.Handle(ev))
+			d.srv.Publish(ev)
+			if ev.Type == session.EventPlanDone && d.cfg.Approval == config.ApprovalAuto {
+				d.decide(ev.Key, true)
+			}
+		}
+	}
+}
+
+// poll picks up new labeled issues and checks waiting plans for approval
+// labels.
+func (d *Daemon) poll() {
+	issues, err := d.forge.ListIssues(forge.Filter{Labels: []string{d.cfg.Label}}, pollLimit)
+	if err != nil {
+		d.log.Printf("list issues: %v", err)
+		return
+	}
+
+	var fresh []session.Issue
+	for _, iss := range issues {
+		key := iss.Key()
+		if _, seen := d.states[key]; seen {
+			continue
+		}
+		if branch, ok := d.hasBranch(iss); ok {
+			d.log.Printf("%s: branch %s already exists on origin; skipping", iss.Ref(), branch)
+			d.states[key] = session.Done
+			continue
+		}
+		d.log.Printf("%s: picked up %q", iss.Ref(), iss.Title)
+		fresh = append(fresh, iss)
+	}
+	if len(fresh) > 0 {
+		d.report(d.mgr.Add(fresh))
+	}
+
+	if d.cfg.Approval == config.ApprovalLabel {
+		d.checkApprovals()
+	}
+}
+
+// checkApprovals decides waiting plans whose issue carries the approve or
+// reject label.
+func (d *Daemon) checkApprovals() {
+	for _, key := range d.mgr.Order() {
+		s := d.mgr.Get(key)
+		if s.State != session.WaitingApproval || s.Issue.IsLocal() {
+			continue
+		}
+		iss, err := forge.Details(d.forge, s.Issue)
+		if err != nil {
+			d.log.Printf("%s: check approval: %v", s.Issue.Ref(), err)
+			continue
+		}
+		labels := iss.LabelNames()
+		switch {
+		case slices.Contains(labels, d.cfg.RejectLabel):
+			d.decide(key, false)
+		case slices.Contains(labels, d.cfg.ApproveLabel):
+			d.decide(key, true)
+		}
+	}
+}
+
+func (d *Daemon) decide(key string, approve bool) {
+	if d.mgr.Approve(key, approve) {
+		verb := "rejected"
+		if approve {
+			verb = "approved"
+		}
+		d.log.Printf("%s: plan %s", d.mgr.Get(key).Issue.Ref(), verb)
+		d.report(d.mgr.Order())
+	}
+}
+
+// report logs the state changes of the given sessions.
+func (d *Daemon) report(keys []string) {
+	for _, key := range keys {
+		s := d.mgr.Get(key)
+		d.notifier.Observe(s)
+		if prev, ok := d.states[key]; ok && prev == s.State {
+			continue
+		}
+		d.states[key] = s.State
+		switch s.State {
+		case session.WaitingApproval:
+			msg := "plan ready"
+			if d.cfg.Approval == config.ApprovalLabel {
+				msg += fmt.Sprintf("; label the issue %q or %q", d.cfg.ApproveLabel, d.cfg.RejectLabel)
+			}
+			d.log.Printf("%s: %s\n%s", s.Issue.Ref(), msg, s.Plan)
+		case session.Blocked:
+			d.log.Printf("%s: push blocked by the pre-push checks\n%spush anyway with go-work approve %s, or reject with -reject", s.Issue.Ref(), s.Findings, s.Issue.Ref())
+		case session.Done:
+			result := s.PR
+			if result == "" {
+				result = "committed on branch " + s.Branch
+			}
+			d.log.Printf("%s: done: %s", s.Issue.Ref(), result)
+		case session.Failed:
+			d.log.Printf("%s: failed: %v", s.Issue.Ref(), s.Err)
+		default:
+			d.log.Printf("%s: %s", s.Issue.Ref(), s.State)
+		}
+	}
+}
+
+// hasBranch reports whether the issue's branch was already pushed, meaning
+// an earlier run has worked on it, and returns the branch.
+func (d *Daemon) hasBranch(iss session.Issue) (string, bool) {
+	r := d.ws.Repo(iss.Repo)
+	if r == nil {
+		return "", false // Add fails the session
+	}
+	branch := r.Config.Worktrees.BranchName(iss)
+	err := exec.Command("git", "-C", r.Root, "ls-remote", "--exit-code", "--heads", "origin", branch).Run()
+	return branch, err == nil
+}
+
+func (d *Daemon) inProgress() int {
+	n := 0
+	for _, key := range d.mgr.Order() {
+		switch d.mgr.Get(key).State {
+		case session.Done, session.Failed, session.Merged:
+		default:
+			n++
+		}
+	}
+	return n
+}
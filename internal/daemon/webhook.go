@@ -0,0 +1,78 @@
+package daemon
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// listen starts the webhook listener. Any verified delivery triggers a
+// poll; the payload itself is not inspected, so the forge can be pointed at
+// the daemon for issue and label events alike.
+func (d *Daemon) listen(ctx context.Context) error {
+	ln, err := net.Listen("tcp", d.cfg.Webhook.Addr)
+	if err != nil {
+		return fmt.Errorf("webhook listener: %w", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(d.serveWebhook)}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.log.Printf("webhook listener: %v", err)
+		}
+	}()
+	d.log.Printf("listening for webhooks on %s", ln.Addr())
+	return nil
+}
+
+func (d *Daemon) serveWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+	if !verify(r.Header, body, d.cfg.Webhook.Secret) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+	select {
+	case d.poke <- struct{}{}:
+	default: // a poll is already pending
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// verify checks a delivery against secret using whichever scheme the
+// sending forge uses. Without a secret every delivery is accepted.
+func verify(h http.Header, body []byte, secret string) bool {
+	if secret == "" {
+		return true
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	sum := hex.EncodeToString(mac.Sum(nil))
+
+	switch {
+	case h.Get("X-Hub-Signature-256") != "": // GitHub
+		return hmac.Equal([]byte(strings.TrimPrefix(h.Get("X-Hub-Signature-256"), "sha256=")), []byte(sum))
+	case h.Get("X-Gitea-Signature") != "":
+		return hmac.Equal([]byte(h.Get("X-Gitea-Signature")), []byte(sum))
+	case h.Get("X-Gitlab-Token") != "":
+		return hmac.Equal([]byte(h.Get("X-Gitlab-Token")), []byte(secret))
+	}
+	return false
+}
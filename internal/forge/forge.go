@@ -0,0 +1,337 @@
+// Package forge abstracts the code hosting service (GitHub, GitLab, Gitea)
+// that issues are read from and pull requests are opened on.
+package forge
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Forge lists issues and opens pull (or merge) requests for one repository.
+type Forge interface {
+	// Name is a short human-readable name, e.g. "GitHub".
+	Name() string
+	// ListIssues returns up to limit open issues matching f.
+	ListIssues(f Filter, limit int) ([]Issue, error)
+	// GetIssue returns one issue with its comments and linked items.
+	GetIssue(number int) (Issue, error)
+	// CreatePR pushes the worktree's branch and opens a pull request,
+	// returning its URL.
+	CreatePR(worktreeDir string, pr PullRequest) (string, error)
+	// PRStatus returns the state of the pull request at prURL, as returned by
+	// CreatePR, and of the CI checks on its head commit.
+	PRStatus(prURL string) (PRStatus, error)
+}
+
+// PullRequest describes a pull (or merge) request to open.
+type PullRequest struct {
+	Title string
+	Body  string
+	Base  string // target branch; the repo's default branch when empty
+}
+
+// PRStatus is the state of a pull request and its CI checks.
+type PRStatus struct {
+	State  string // open, closed or merged
+	Checks []Check
+}
+
+// Check is one CI job or commit status on a pull request.
+type Check struct {
+	Name   string
+	Status string // pending, running, success, failure, …, as the forge words it, lowercased
+	URL    string
+}
+
+// prNumber returns the number ending a pull request URL, after the path
+// segment kind ("pull", "pulls" or "merge_requests").
+func prNumber(prURL, kind string) (int, error) {
+	_, rest, ok := strings.Cut(prURL, "/"+kind+"/")
+	if !ok {
+		return 0, fmt.Errorf("%s is not a pull request URL", prURL)
+	}
+	n, err := strconv.Atoi(strings.SplitN(rest, "/", 2)[0])
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a pull request URL", prURL)
+	}
+	return n, nil
+}
+
+// Issue holds the issue data we care about. ListIssues fills the summary
+// fields; Comments and Linked are only populated by GetIssue. The JSON tags
+// match gh's --json output.
+type Issue struct {
+	Number    int        `json:"number"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	URL       string     `json:"url"`
+	Author    Actor      `json:"author"`
+	Labels    []Label    `json:"labels"`
+	Milestone *Milestone `json:"milestone"`
+	Comments  []Comment  `json:"comments"`
+
+	Linked   []LinkedItem `json:"-"`
+	Detailed bool         `json:"-"` // set once GetIssue has run
+	Source   Source       `json:"-"`
+	Repo     string       `json:"-"` // the workspace repo's name; empty outside workspaces
+}
+
+// Source says where an issue came from. Issues from local sources are
+// numbered per source and are not known to the forge.
+type Source string
+
+const (
+	SourceForge  Source = ""       // the repo's issue tracker
+	SourceTasks  Source = "task"   // a local TASKS.md / TASKS.yaml file
+	SourceTODO   Source = "todo"   // a go-work TODO comment in the code
+	SourcePrompt Source = "prompt" // typed ad hoc in the issue selector
+)
+
+// IsLocal reports whether the issue comes from a local source rather than
+// the forge.
+func (i Issue) IsLocal() bool { return i.Source != SourceForge }
+
+// Key identifies the issue among all sources and, in workspaces, repos. It
+// is safe for use in branch and directory names.
+func (i Issue) Key() string {
+	key := strconv.Itoa(i.Number)
+	if i.IsLocal() {
+		key = fmt.Sprintf("%s-%d", i.Source, i.Number)
+	}
+	if i.Repo != "" {
+		key = i.Repo + "-" + key
+	}
+	return key
+}
+
+// Ref is the issue reference shown to users: "#12" for forge issues and the
+// key for local ones, prefixed in workspaces with the repo, as in "api#12"
+// and "api/task-2".
+func (i Issue) Ref() string {
+	ref := "#" + strconv.Itoa(i.Number)
+	if i.IsLocal() {
+		ref = fmt.Sprintf("%s-%d", i.Source, i.Number)
+		if i.Repo != "" {
+			return i.Repo + "/" + ref
+		}
+	}
+	return i.Repo + ref
+}
+
+// Actor is a user reference.
+type Actor struct {
+	Login string `json:"login"`
+}
+
+// Label is an issue label.
+type Label struct {
+	Name string `json:"name"`
+}
+
+// Milestone is the milestone an issue belongs to.
+type Milestone struct {
+	Title string `json:"title"`
+}
+
+// Comment is a single issue comment.
+type Comment struct {
+	Author    Actor     `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LinkedItem is an issue or pull request that references, or is referenced
+// by, an issue.
+type LinkedItem struct {
+	Number int
+	Title  string
+	State  string
+	IsPR   bool
+}
+
+// LabelNames returns the names of the issue's labels.
+func (i Issue) LabelNames() []string {
+	names := make([]string, len(i.Labels))
+	for j, l := range i.Labels {
+		names[j] = l.Name
+	}
+	return names
+}
+
+// Filter narrows the issues returned by ListIssues. Empty fields are ignored;
+// all non-empty fields must match.
+type Filter struct {
+	Labels    []string `yaml:"labels"`
+	Assignee  string   `yaml:"assignee"` // "@me" for the authenticated user
+	Milestone string   `yaml:"milestone"`
+	Author    string   `yaml:"author"`
+	Search    string   `yaml:"search"` // free-text query, e.g. "crash on start"
+}
+
+// IsZero reports whether the filter matches every open issue.
+func (f Filter) IsZero() bool {
+	return len(f.Labels) == 0 && f.Assignee == "" && f.Milestone == "" &&
+		f.Author == "" && f.Search == ""
+}
+
+// Settings overrides forge detection, for self-hosted instances whose host
+// name doesn't reveal the forge type.
+type Settings struct {
+	Type     string `yaml:"type"`      // "github", "gitlab" or "gitea"
+	URL      string `yaml:"url"`       // base URL, e.g. https://git.example.com
+	TokenEnv string `yaml:"token_env"` // env var holding the API token
+}
+
+// Detect picks a Forge for the repo at repoRoot from the URL of its origin
+// remote, unless s.Type names it. GitLab and Gitea are recognised by host
+// name or, for other hosts, by probing their version endpoints; anything else
+// is assumed to be GitHub (including Enterprise hosts, which gh handles
+// itself).
+func Detect(repoRoot string, s Settings) (Forge, error) {
+	out, err := exec.Command("git", "-C", repoRoot, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return nil, fmt.Errorf("git remote get-url origin: %w", err)
+	}
+	remote, err := ParseRemote(strings.TrimSpace(string(out)))
+	if err != nil {
+		return nil, err
+	}
+
+	base := remote.Scheme + "://" + remote.Host
+	if s.URL != "" {
+		base = strings.TrimRight(s.URL, "/")
+	}
+
+	typ := s.Type
+	if typ == "" {
+		typ = guessType(remote.Host, base)
+	}
+
+	switch typ {
+	case "github":
+		return NewGitHub(repoRoot), nil
+	case "gitlab":
+		return NewGitLab(base, remote.Path, token(s.TokenEnv, "GITLAB_TOKEN"), nil), nil
+	case "gitea":
+		return NewGitea(base, remote.Path, token(s.TokenEnv, "GITEA_TOKEN"), nil), nil
+	default:
+		return nil, fmt.Errorf("unknown forge type %q (want github, gitlab or gitea)", typ)
+	}
+}
+
+// guessed caches the forge types probed for, by base URL, so repos on the
+// same host are probed once.
+var guessed struct {
+	sync.Mutex
+	types map[string]string
+}
+
+func guessType(host, base string) string {
+	switch {
+	case host == "github.com":
+		return "github"
+	case strings.Contains(host, "gitlab"):
+		return "gitlab"
+	case strings.Contains(host, "gitea"), host == "codeberg.org":
+		return "gitea"
+	}
+
+	guessed.Lock()
+	defer guessed.Unlock()
+	if typ, ok := guessed.types[base]; ok {
+		return typ
+	}
+	typ := probeType(base)
+	if guessed.types == nil {
+		guessed.types = map[string]string{}
+	}
+	guessed.types[base] = typ
+	return typ
+}
+
+// probeType asks the forge at base for its version the GitLab and the Gitea
+// way at once. GitLab wins if both answer.
+func probeType(base string) string {
+	probes := []struct{ typ, path string }{
+		{"gitlab", "/api/v4/version"},
+		{"gitea", "/api/v1/version"},
+	}
+	client := &http.Client{Timeout: 3 * time.Second}
+	found := make([]bool, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(base + probe.path)
+			if err != nil {
+				return
+			}
+			resp.Body.Close()
+			// GitLab answers 401 without a token; Gitea serves the version openly.
+			found[i] = resp.StatusCode == http.StatusOK ||
+				(probe.typ == "gitlab" && resp.StatusCode == http.StatusUnauthorized)
+		}()
+	}
+	wg.Wait()
+	for i, probe := range probes {
+		if found[i] {
+			return probe.typ
+		}
+	}
+	return "github"
+}
+
+func token(env, fallback string) string {
+	if env == "" {
+		env = fallback
+	}
+	return os.Getenv(env)
+}
+
+// Remote is a parsed git remote URL.
+type Remote struct {
+	Scheme string // http or https; ssh remotes map to https
+	Host   string // includes the port for http(s) remotes
+	Path   string // "owner/repo" or "group/subgroup/repo", without .git
+}
+
+// ParseRemote parses https, ssh:// and scp-style (git@host:owner/repo)
+// remote URLs.
+func ParseRemote(raw string) (Remote, error) {
+	if !strings.Contains(raw, "://") {
+		// scp-style: [user@]host:path
+		hostPart, path, ok := strings.Cut(raw, ":")
+		if !ok {
+			return Remote{}, fmt.Errorf("unrecognised remote URL %q", raw)
+		}
+		if _, h, ok := strings.Cut(hostPart, "@"); ok {
+			hostPart = h
+		}
+		return Remote{Scheme: "https", Host: hostPart, Path: cleanPath(path)}, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return Remote{}, fmt.Errorf("parse remote URL: %w", err)
+	}
+	r := Remote{Scheme: "https", Host: u.Hostname(), Path: cleanPath(u.Path)}
+	if u.Scheme == "http" || u.Scheme == "https" {
+		r.Scheme, r.Host = u.Scheme, u.Host
+	}
+	if r.Host == "" || r.Path == "" {
+		return Remote{}, fmt.Errorf("unrecognised remote URL %q", raw)
+	}
+	return r, nil
+}
+
+func cleanPath(p string) string {
+	return strings.TrimSuffix(strings.Trim(p, "/"), ".git")
+}
@@ -0,0 +1,228 @@
+package forge
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRemote(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    Remote
+		wantErr bool
+	}{
+		{raw: "https://github.com/owner/repo.git", want: Remote{"https", "github.com", "owner/repo"}},
+		{raw: "https://github.com/owner/repo", want: Remote{"https", "github.com", "owner/repo"}},
+		{raw: "http://gitea.local:3000/owner/repo.git", want: Remote{"http", "gitea.local:3000", "owner/repo"}},
+		{raw: "https://gitlab.com/group/sub/repo.git/", want: Remote{"https", "gitlab.com", "group/sub/repo"}},
+		{raw: "git@github.com:owner/repo.git", want: Remote{"https", "github.com", "owner/repo"}},
+		{raw: "gitlab.example.com:group/repo", want: Remote{"https", "gitlab.example.com", "group/repo"}},
+		{raw: "ssh://git@gitlab.example.com:2222/group/repo.git", want: Remote{"https", "gitlab.example.com", "group/repo"}},
+		{raw: "/srv/git/repo.git", wantErr: true},
+		{raw: "https://github.com/", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRemote(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRemote(%q) = %+v, want an error", tt.raw, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRemote(%q) = %+v, %v; want %+v", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestGuessType(t *testing.T) {
+	for host, want := range map[string]string{
+		"github.com":         "github",
+		"gitlab.example.com": "gitlab",
+		"gitea.example.com":  "gitea",
+		"codeberg.org":       "gitea",
+	} {
+		// Known hosts are decided without probing, so base is never used.
+		if got := guessType(host, "http://invalid.test"); got != want {
+			t.Errorf("guessType(%q) = %q, want %q", host, got, want)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		status map[string]int // by probe path; others are 404
+		want   string
+	}{
+		{"gitlab without token", map[string]int{"/api/v4/version": 401}, "gitlab"},
+		{"gitlab with token", map[string]int{"/api/v4/version": 200}, "gitlab"},
+		{"gitea", map[string]int{"/api/v1/version": 200}, "gitea"},
+		{"gitea asking for auth", map[string]int{"/api/v1/version": 401}, "github"},
+		{"neither", nil, "github"},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if code, ok := tt.status[r.URL.Path]; ok {
+				w.WriteHeader(code)
+				return
+			}
+			http.NotFound(w, r)
+		}))
+		host := strings.TrimPrefix(srv.URL, "http://")
+		if got := guessType(host, srv.URL); got != tt.want {
+			t.Errorf("%s: guessType = %q, want %q", tt.name, got, tt.want)
+		}
+		srv.Close()
+	}
+}
+
+func TestGuessTypeProbes(t *testing.T) {
+	// The probes run at once: GitLab's waits for Gitea's, and wins though
+	// both answer.
+	giteaAsked := make(chan struct{})
+	var mu sync.Mutex
+	probes := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		probes[r.URL.Path]++
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v4/version":
+			select {
+			case <-giteaAsked:
+			case <-time.After(2 * time.Second):
+				t.Error("the GitLab probe did not wait for the Gitea probe")
+			}
+		case "/api/v1/version":
+			close(giteaAsked)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	if got := guessType(host, srv.URL); got != "gitlab" {
+		t.Errorf("guessType = %q, want gitlab", got)
+	}
+
+	// Later repos on the host use the cached result.
+	work := gitWorktree(t, "main")
+	if out, err := exec.Command("git", "-C", work, "remote", "set-url", "origin", srv.URL+"/group/repo.git").CombinedOutput(); err != nil {
+		t.Fatalf("git remote set-url: %v\n%s", err, out)
+	}
+	fg, err := Detect(work, Settings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fg.Name() != "GitLab" {
+		t.Errorf("Detect = %s, want GitLab", fg.Name())
+	}
+
+	// A configured type needs no probes.
+	fg, err = Detect(work, Settings{Type: "gitea", URL: "http://uncached.invalid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fg.Name() != "Gitea" {
+		t.Errorf("Detect = %s, want Gitea", fg.Name())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if want := map[string]int{"/api/v4/version": 1, "/api/v1/version": 1}; !reflect.DeepEqual(probes, want) {
+		t.Errorf("probes = %v, want each once", probes)
+	}
+}
+
+// fakeAPI is a local stand-in for a forge's REST API. It answers requests
+// with canned JSON by method and escaped path, ignoring the query, and
+// records the requests it gets.
+type fakeAPI struct {
+	*httptest.Server
+	t      *testing.T
+	routes map[string]string // "GET /api/v4/…" → response body
+
+	mu   sync.Mutex
+	reqs []fakeRequest
+}
+
+type fakeRequest struct {
+	method, path string
+	query        url.Values
+	header       http.Header
+	body         map[string]any
+}
+
+func newFakeAPI(t *testing.T, routes map[string]string) *fakeAPI {
+	f := &fakeAPI{t: t, routes: routes}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeAPI) serve(w http.ResponseWriter, r *http.Request) {
+	req := fakeRequest{method: r.Method, path: r.URL.EscapedPath(), query: r.URL.Query(), header: r.Header}
+	if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+		if err := json.Unmarshal(data, &req.body); err != nil {
+			f.t.Errorf("%s %s: body is not a JSON object: %v", r.Method, req.path, err)
+		}
+	}
+	f.mu.Lock()
+	f.reqs = append(f.reqs, req)
+	f.mu.Unlock()
+
+	resp, ok := f.routes[r.Method+" "+req.path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	io.WriteString(w, resp)
+}
+
+// request returns the last request to method and path.
+func (f *fakeAPI) request(method, path string) fakeRequest {
+	f.t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := len(f.reqs) - 1; i >= 0; i-- {
+		if r := f.reqs[i]; r.method == method && r.path == path {
+			return r
+		}
+	}
+	f.t.Fatalf("no %s %s request", method, path)
+	return fakeRequest{}
+}
+
+// gitWorktree returns a repository with branch checked out and a bare
+// repository as its origin, for CreatePR to push to.
+func gitWorktree(t *testing.T, branch string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	dir := t.TempDir()
+	origin, work := filepath.Join(dir, "origin.git"), filepath.Join(dir, "work")
+	for _, args := range [][]string{
+		{"init", "-q", "--bare", origin},
+		{"init", "-q", "-b", branch, work},
+		{"-C", work, "-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "-q", "--allow-empty", "-m", "Test"},
+		{"-C", work, "remote", "add", "origin", origin},
+	} {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	return work
+}
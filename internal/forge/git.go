@@ -0,0 +1,42 @@
+package forge
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/tomfevang/go-work/internal/telemetry"
+)
+
+// pushHead pushes the worktree's current branch to origin so a pull request
+// can be opened against it.
+func pushHead(worktreeDir string) error {
+	cmd := exec.Command("git", "push", "-u", "origin", "HEAD")
+	cmd.Dir = worktreeDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		telemetry.CommandErrors.Inc("git")
+		return fmt.Errorf("git push: %w\n%s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// currentBranch returns the branch checked out in dir.
+func currentBranch(dir string) (string, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if err != nil {
+		telemetry.CommandErrors.Inc("git")
+		return "", fmt.Errorf("git rev-parse: %w%s", err, stderrOf(err))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// stderrOf returns the trimmed stderr captured by exec.Cmd.Output, prefixed
+// with a newline, or "" if there is none.
+func stderrOf(err error) string {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return "\n" + strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return ""
+}
@@ -0,0 +1,218 @@
+package forge
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Gitea talks to the Gitea (and Forgejo) REST API (v1).
+type Gitea struct {
+	api  *apiClient
+	repo string // "owner/repo"
+}
+
+// NewGitea returns a Gitea forge for the repo at path ("owner/repo") on the
+// instance at baseURL. hc may be nil; tests pass a client for a local fake
+// server.
+func NewGitea(baseURL, path, token string, hc *http.Client) *Gitea {
+	auth := func(r *http.Request) {
+		if token != "" {
+			r.Header.Set("Authorization", "token "+token)
+		}
+	}
+	return &Gitea{api: newAPIClient(baseURL+"/api/v1", auth, hc), repo: path}
+}
+
+// Name implements Forge.
+func (g *Gitea) Name() string { return "Gitea" }
+
+type giteaIssue struct {
+	Number      int        `json:"number"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	State       string     `json:"state"`
+	HTMLURL     string     `json:"html_url"`
+	User        giteaUser  `json:"user"`
+	Labels      []Label    `json:"labels"`
+	Milestone   *Milestone `json:"milestone"`
+	PullRequest *struct{}  `json:"pull_request"`
+}
+
+type giteaUser struct {
+	Login string `json:"login"`
+}
+
+type giteaComment struct {
+	User      giteaUser `json:"user"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (gi giteaIssue) issue() Issue {
+	return Issue{
+		Number:    gi.Number,
+		Title:     gi.Title,
+		Body:      gi.Body,
+		URL:       gi.HTMLURL,
+		Author:    Actor{Login: gi.User.Login},
+		Labels:    gi.Labels,
+		Milestone: gi.Milestone,
+	}
+}
+
+// ListIssues implements Forge. Gitea has no "@me" shorthand, so it is
+// resolved to the token's user first.
+func (g *Gitea) ListIssues(f Filter, limit int) ([]Issue, error) {
+	q := url.Values{"state": {"open"}, "type": {"issues"}}
+	if len(f.Labels) > 0 {
+		q.Set("labels", strings.Join(f.Labels, ","))
+	}
+	if f.Milestone != "" {
+		q.Set("milestones", f.Milestone)
+	}
+	if f.Search != "" {
+		q.Set("q", f.Search)
+	}
+	for param, user := range map[string]string{"assigned_by": f.Assignee, "created_by": f.Author} {
+		if user == "@me" {
+			var me giteaUser
+			if err := g.api.do("GET", "/user", nil, &me); err != nil {
+				return nil, fmt.Errorf("resolve @me: %w", err)
+			}
+			user = me.Login
+		}
+		if user != "" {
+			q.Set(param, user)
+		}
+	}
+
+	const perPage = 50
+	q.Set("limit", strconv.Itoa(perPage))
+	raw, err := paginate(limit, perPage, func(page int) ([]giteaIssue, error) {
+		q.Set("page", strconv.Itoa(page))
+		var items []giteaIssue
+		err := g.api.do("GET", g.path("/issues?"+q.Encode()), nil, &items)
+		return items, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list Gitea issues: %w", err)
+	}
+
+	issues := make([]Issue, len(raw))
+	for i, gi := range raw {
+		issues[i] = gi.issue()
+	}
+	return issues, nil
+}
+
+// GetIssue implements Forge.
+func (g *Gitea) GetIssue(number int) (Issue, error) {
+	var gi giteaIssue
+	if err := g.api.do("GET", g.path(fmt.Sprintf("/issues/%d", number)), nil, &gi); err != nil {
+		return Issue{}, fmt.Errorf("get Gitea issue: %w", err)
+	}
+	iss := gi.issue()
+
+	var comments []giteaComment
+	if err := g.api.do("GET", g.path(fmt.Sprintf("/issues/%d/comments", number)), nil, &comments); err != nil {
+		return Issue{}, fmt.Errorf("get Gitea issue comments: %w", err)
+	}
+	for _, c := range comments {
+		iss.Comments = append(iss.Comments, Comment{
+			Author:    Actor{Login: c.User.Login},
+			Body:      c.Body,
+			CreatedAt: c.CreatedAt,
+		})
+	}
+
+	webBase, _, _ := strings.Cut(iss.URL, "/issues/")
+	iss.Linked = resolveReferences(iss, webBase, nil, func(n int) (LinkedItem, error) {
+		var ref giteaIssue
+		err := g.api.do("GET", g.path(fmt.Sprintf("/issues/%d", n)), nil, &ref)
+		return LinkedItem{Number: ref.Number, Title: ref.Title, State: ref.State, IsPR: ref.PullRequest != nil}, err
+	})
+	iss.Detailed = true
+	return iss, nil
+}
+
+// CreatePR implements Forge, targeting the repo's default branch.
+func (g *Gitea) CreatePR(worktreeDir string, pr PullRequest) (string, error) {
+	branch, err := currentBranch(worktreeDir)
+	if err != nil {
+		return "", err
+	}
+	if err := pushHead(worktreeDir); err != nil {
+		return "", err
+	}
+
+	base := pr.Base
+	if base == "" {
+		var repo struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if err := g.api.do("GET", g.path(""), nil, &repo); err != nil {
+			return "", fmt.Errorf("get Gitea repo: %w", err)
+		}
+		base = repo.DefaultBranch
+	}
+
+	req := map[string]string{
+		"head":  branch,
+		"base":  base,
+		"title": pr.Title,
+		"body":  pr.Body,
+	}
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := g.api.do("POST", g.path("/pulls"), req, &created); err != nil {
+		return "", fmt.Errorf("create Gitea pull request: %w", err)
+	}
+	return created.HTMLURL, nil
+}
+
+// PRStatus implements Forge with the pull request's state and the commit
+// statuses of its head.
+func (g *Gitea) PRStatus(prURL string) (PRStatus, error) {
+	n, err := prNumber(prURL, "pulls")
+	if err != nil {
+		return PRStatus{}, err
+	}
+	var pr struct {
+		State  string `json:"state"`
+		Merged bool   `json:"merged"`
+		Head   struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	if err := g.api.do("GET", g.path(fmt.Sprintf("/pulls/%d", n)), nil, &pr); err != nil {
+		return PRStatus{}, fmt.Errorf("get Gitea pull request: %w", err)
+	}
+	st := PRStatus{State: pr.State}
+	if pr.Merged {
+		st.State = "merged"
+	}
+	var combined struct {
+		Statuses []struct {
+			Context   string `json:"context"`
+			Status    string `json:"status"`
+			TargetURL string `json:"target_url"`
+		} `json:"statuses"`
+	}
+	if err := g.api.do("GET", g.path("/commits/"+pr.Head.SHA+"/status"), nil, &combined); err != nil {
+		return st, fmt.Errorf("get commit status: %w", err)
+	}
+	for _, s := range combined.Statuses {
+		st.Checks = append(st.Checks, Check{Name: s.Context, Status: s.Status, URL: s.TargetURL})
+	}
+	return st, nil
+}
+
+// path returns the API path of a repo sub-resource.
+func (g *Gitea) path(suffix string) string {
+	return "/repos/" + g.repo + suffix
+}
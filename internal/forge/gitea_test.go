@@ -0,0 +1,167 @@
+package forge
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const giteaRepo = "/api/v1/repos/owner/repo"
+
+func TestGiteaListIssues(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"GET /api/v1/user": `{"login": "me"}`,
+		"GET " + giteaRepo + "/issues": `[
+			{"number": 2, "title": "Crash", "state": "open", "html_url": "https://gitea.example.com/owner/repo/issues/2",
+			 "user": {"login": "ann"}, "labels": [{"name": "bug"}], "milestone": {"title": "v1"}},
+			{"number": 1, "title": "Docs", "state": "open", "user": {"login": "bob"}}
+		]`,
+	})
+	g := NewGitea(api.URL, "owner/repo", "s3cret", api.Client())
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   map[string]string // query parameters, "" for absent
+	}{
+		{
+			name:   "assigned to and created by me",
+			filter: Filter{Assignee: "@me", Author: "@me", Labels: []string{"bug", "ui"}},
+			want: map[string]string{
+				"state": "open", "type": "issues", "labels": "bug,ui",
+				"assigned_by": "me", "created_by": "me", "limit": "50", "page": "1",
+			},
+		},
+		{
+			name:   "other users",
+			filter: Filter{Assignee: "bob", Milestone: "v1", Search: "crash"},
+			want: map[string]string{
+				"assigned_by": "bob", "created_by": "", "milestones": "v1", "q": "crash", "labels": "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		issues, err := g.ListIssues(tt.filter, 10)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		q := api.request("GET", giteaRepo+"/issues").query
+		for k, v := range tt.want {
+			if got := q.Get(k); got != v {
+				t.Errorf("%s: query %s = %q, want %q", tt.name, k, got, v)
+			}
+		}
+		want := []Issue{
+			{
+				Number: 2, Title: "Crash", URL: "https://gitea.example.com/owner/repo/issues/2",
+				Author: Actor{Login: "ann"}, Labels: []Label{{Name: "bug"}}, Milestone: &Milestone{Title: "v1"},
+			},
+			{Number: 1, Title: "Docs", Author: Actor{Login: "bob"}},
+		}
+		if !reflect.DeepEqual(issues, want) {
+			t.Errorf("%s: ListIssues = %+v, want %+v", tt.name, issues, want)
+		}
+	}
+
+	if got := api.request("GET", "/api/v1/user").header.Get("Authorization"); got != "token s3cret" {
+		t.Errorf("Authorization = %q, want %q", got, "token s3cret")
+	}
+}
+
+func TestGiteaGetIssue(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"GET " + giteaRepo + "/issues/5": `{"number": 5, "title": "Crash", "body": "Fixed by #9?",
+			"html_url": "https://gitea.example.com/owner/repo/issues/5", "user": {"login": "ann"}}`,
+		"GET " + giteaRepo + "/issues/5/comments": `[
+			{"body": "Also https://gitea.example.com/owner/repo/issues/4", "user": {"login": "bob"}, "created_at": "2024-05-01T10:00:00Z"}
+		]`,
+		"GET " + giteaRepo + "/issues/9": `{"number": 9, "title": "Fix crash", "state": "open", "pull_request": {}}`,
+		"GET " + giteaRepo + "/issues/4": `{"number": 4, "title": "Old crash", "state": "closed"}`,
+	})
+	g := NewGitea(api.URL, "owner/repo", "", api.Client())
+
+	iss, err := g.GetIssue(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !iss.Detailed || iss.Title != "Crash" || iss.Author.Login != "ann" {
+		t.Errorf("GetIssue = %+v", iss)
+	}
+	if len(iss.Comments) != 1 || iss.Comments[0].Author.Login != "bob" {
+		t.Errorf("Comments = %+v, want bob's comment", iss.Comments)
+	}
+	want := []LinkedItem{
+		{Number: 9, Title: "Fix crash", State: "open", IsPR: true},
+		{Number: 4, Title: "Old crash", State: "closed"},
+	}
+	if !reflect.DeepEqual(iss.Linked, want) {
+		t.Errorf("Linked = %+v, want %+v", iss.Linked, want)
+	}
+}
+
+func TestGiteaCreatePR(t *testing.T) {
+	work := gitWorktree(t, "agent/5")
+	api := newFakeAPI(t, map[string]string{
+		"GET " + giteaRepo:             `{"default_branch": "main"}`,
+		"POST " + giteaRepo + "/pulls": `{"html_url": "https://gitea.example.com/owner/repo/pulls/12"}`,
+	})
+	g := NewGitea(api.URL, "owner/repo", "", api.Client())
+
+	got, err := g.CreatePR(work, PullRequest{Title: "Fix crash", Body: "Closes #5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://gitea.example.com/owner/repo/pulls/12"; got != want {
+		t.Errorf("CreatePR = %q, want %q", got, want)
+	}
+	body := api.request("POST", giteaRepo+"/pulls").body
+	want := map[string]any{"head": "agent/5", "base": "main", "title": "Fix crash", "body": "Closes #5"}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("pull request = %v, want %v", body, want)
+	}
+	origin := filepath.Join(filepath.Dir(work), "origin.git")
+	if err := exec.Command("git", "--git-dir", origin, "rev-parse", "--verify", "-q", "refs/heads/agent/5").Run(); err != nil {
+		t.Errorf("branch not pushed to origin: %v", err)
+	}
+}
+
+func TestGiteaPRStatus(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"GET " + giteaRepo + "/pulls/12": `{"state": "open", "merged": false, "head": {"sha": "abc"}}`,
+		"GET " + giteaRepo + "/pulls/13": `{"state": "closed", "merged": true, "head": {"sha": "def"}}`,
+		"GET " + giteaRepo + "/commits/abc/status": `{"statuses": [
+			{"context": "ci/test", "status": "pending", "target_url": "https://ci.example.com/1"}
+		]}`,
+		"GET " + giteaRepo + "/commits/def/status": `{"statuses": []}`,
+	})
+	g := NewGitea(api.URL, "owner/repo", "", api.Client())
+
+	tests := []struct {
+		url     string
+		want    PRStatus
+		wantErr bool
+	}{
+		{
+			url: "https://gitea.example.com/owner/repo/pulls/12",
+			want: PRStatus{State: "open", Checks: []Check{
+				{Name: "ci/test", Status: "pending", URL: "https://ci.example.com/1"},
+			}},
+		},
+		{url: "https://gitea.example.com/owner/repo/pulls/13/files", want: PRStatus{State: "merged"}},
+		{url: "https://gitea.example.com/owner/repo/pulls/99", wantErr: true},
+		{url: "https://gitea.example.com/owner/repo/issues/5", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := g.PRStatus(tt.url)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("PRStatus(%s) = %+v, want an error", tt.url, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PRStatus(%s) = %+v, %v; want %+v", tt.url, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package forge
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/tomfevang/go-work/internal/telemetry"
+)
+
+// listFields are the JSON fields requested by ListIssues. Comments are left
+// out to keep large pages fast.
+const listFields = "number,title,body,url,author,labels,milestone"
+
+// GitHub talks to GitHub through the gh CLI, which handles authentication
+// and Enterprise hosts.
+type GitHub struct {
+	dir string // repo root; gh infers the repo from it
+}
+
+// NewGitHub returns a GitHub forge for the repo at dir.
+func NewGitHub(dir string) *GitHub {
+	return &GitHub{dir: dir}
+}
+
+// Name implements Forge.
+func (g *GitHub) Name() string { return "GitHub" }
+
+func (g *GitHub) gh(args ...string) ([]byte, error) {
+	cmd := exec.Command("gh", args...)
+	cmd.Dir = g.dir
+	out, err := cmd.Output()
+	if err != nil {
+		telemetry.CommandErrors.Inc("gh")
+	}
+	return out, err
+}
+
+// ListIssues implements Forge. gh has no offset flag, so callers page by
+// re-requesting with a larger limit.
+func (g *GitHub) ListIssues(f Filter, limit int) ([]Issue, error) {
+	args := []string{"issue", "list",
+		"--state", "open",
+		"--json", listFields,
+		"--limit", strconv.Itoa(limit),
+	}
+	for _, l := range f.Labels {
+		args = append(args, "--label", l)
+	}
+	if f.Assignee != "" {
+		args = append(args, "--assignee", f.Assignee)
+	}
+	if f.Milestone != "" {
+		args = append(args, "--milestone", f.Milestone)
+	}
+	if f.Author != "" {
+		args = append(args, "--author", f.Author)
+	}
+	if f.Search != "" {
+		args = append(args, "--search", f.Search)
+	}
+
+	out, err := g.gh(args...)
+	if err != nil {
+		return nil, fmt.Errorf("gh issue list: %w%s", err, stderrOf(err))
+	}
+
+	var issues []Issue
+	if err := json.Unmarshal(out, &issues); err != nil {
+		return nil, fmt.Errorf("parse issues: %w", err)
+	}
+	return issues, nil
+}
+
+// GetIssue implements Forge. Failing to resolve links is not an error; the
+// issue is returned with whatever could be found.
+func (g *GitHub) GetIssue(number int) (Issue, error) {
+	out, err := g.gh("issue", "view", strconv.Itoa(number),
+		"--json", listFields+",comments",
+	)
+	if err != nil {
+		return Issue{}, fmt.Errorf("gh issue view: %w%s", err, stderrOf(err))
+	}
+
+	var iss Issue
+	if err := json.Unmarshal(out, &iss); err != nil {
+		return Issue{}, fmt.Errorf("parse issue: %w", err)
+	}
+	iss.Linked = g.linkedItems(iss)
+	iss.Detailed = true
+	return iss, nil
+}
+
+// restIssue is the subset of the REST issue payload used for linked items.
+// The issues endpoint also serves pull requests, marked by PullRequest.
+type restIssue struct {
+	Number      int             `json:"number"`
+	Title       string          `json:"title"`
+	State       string          `json:"state"`
+	PullRequest json.RawMessage `json:"pull_request"`
+}
+
+func (r restIssue) linked() LinkedItem {
+	return LinkedItem{Number: r.Number, Title: r.Title, State: r.State, IsPR: len(r.PullRequest) > 0}
+}
+
+// linkedItems collects items that cross-reference iss (typically PRs saying
+// "fixes #N") followed by items referenced from its body and comments.
+func (g *GitHub) linkedItems(iss Issue) []LinkedItem {
+	var items []LinkedItem
+	seen := map[int]bool{iss.Number: true}
+
+	var timeline []struct {
+		Event  string `json:"event"`
+		Source struct {
+			Issue restIssue `json:"issue"`
+		} `json:"source"`
+	}
+	path := fmt.Sprintf("repos/{owner}/{repo}/issues/%d/timeline?per_page=100", iss.Number)
+	if out, err := g.gh("api", path); err == nil {
+		_ = json.Unmarshal(out, &timeline)
+	}
+	for _, ev := range timeline {
+		n := ev.Source.Issue.Number
+		if ev.Event != "cross-referenced" || n == 0 || seen[n] {
+			continue
+		}
+		seen[n] = true
+		items = append(items, ev.Source.Issue.linked())
+	}
+
+	webBase, _, _ := strings.Cut(iss.URL, "/issues/")
+	return resolveReferences(iss, webBase, items, func(n int) (LinkedItem, error) {
+		out, err := g.gh("api", fmt.Sprintf("repos/{owner}/{repo}/issues/%d", n))
+		if err != nil {
+			return LinkedItem{}, err
+		}
+		var r restIssue
+		err = json.Unmarshal(out, &r)
+		return r.linked(), err
+	})
+}
+
+// ghCheck is an entry of gh's statusCheckRollup: a check run or, with
+// Context set, a commit status.
+type ghCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`     // check runs: queued, in_progress, completed
+	Conclusion string `json:"conclusion"` // check runs, once completed
+	DetailsURL string `json:"detailsUrl"`
+	Context    string `json:"context"`
+	State      string `json:"state"` // commit statuses
+	TargetURL  string `json:"targetUrl"`
+}
+
+// PRStatus implements Forge.
+func (g *GitHub) PRStatus(prURL string) (PRStatus, error) {
+	out, err := g.gh("pr", "view", prURL, "--json", "state,statusCheckRollup")
+	if err != nil {
+		return PRStatus{}, fmt.Errorf("gh pr view: %w", err)
+	}
+	var pr struct {
+		State  string    `json:"state"`
+		Checks []ghCheck `json:"statusCheckRollup"`
+	}
+	if err := json.Unmarshal(out, &pr); err != nil {
+		return PRStatus{}, fmt.Errorf("parse gh output: %w", err)
+	}
+	st := PRStatus{State: strings.ToLower(pr.State)}
+	for _, c := range pr.Checks {
+		switch {
+		case c.Context != "":
+			st.Checks = append(st.Checks, Check{Name: c.Context, Status: strings.ToLower(c.State), URL: c.TargetURL})
+		case !strings.EqualFold(c.Status, "completed"):
+			st.Checks = append(st.Checks, Check{Name: c.Name, Status: strings.ToLower(c.Status), URL: c.DetailsURL})
+		default:
+			st.Checks = append(st.Checks, Check{Name: c.Name, Status: strings.ToLower(c.Conclusion), URL: c.DetailsURL})
+		}
+	}
+	return st, nil
+}
+
+// CreatePR implements Forge.
+func (g *GitHub) CreatePR(worktreeDir string, pr PullRequest) (string, error) {
+	if err := pushHead(worktreeDir); err != nil {
+		return "", err
+	}
+
+	args := []string{"pr", "create",
+		"--title", pr.Title,
+		"--body", pr.Body,
+	}
+	if pr.Base != "" {
+		args = append(args, "--base", pr.Base)
+	}
+	prCmd := exec.Command("gh", args...)
+	prCmd.Dir = worktreeDir
+
+	out, err := prCmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("gh pr create: %w\n%s", err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
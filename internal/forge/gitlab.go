@@ -0,0 +1,270 @@
+package forge
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GitLab talks to the GitLab REST API (v4). Merge requests stand in for pull
+// requests.
+type GitLab struct {
+	api     *apiClient
+	project string // URL-encoded project path, used as the :id parameter
+	me      int    // id of the token's user, once looked up
+}
+
+// NewGitLab returns a GitLab forge for the project at path (e.g.
+// "group/sub/repo") on the instance at baseURL. hc may be nil; tests pass a
+// client for a local fake server.
+func NewGitLab(baseURL, path, token string, hc *http.Client) *GitLab {
+	auth := func(r *http.Request) {
+		if token != "" {
+			r.Header.Set("PRIVATE-TOKEN", token)
+		}
+	}
+	return &GitLab{
+		api:     newAPIClient(baseURL+"/api/v4", auth, hc),
+		project: url.PathEscape(path),
+	}
+}
+
+// Name implements Forge.
+func (g *GitLab) Name() string { return "GitLab" }
+
+type gitlabIssue struct {
+	IID         int        `json:"iid"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	WebURL      string     `json:"web_url"`
+	Labels      []string   `json:"labels"`
+	Author      gitlabUser `json:"author"`
+	Milestone   *struct {
+		Title string `json:"title"`
+	} `json:"milestone"`
+}
+
+type gitlabUser struct {
+	Username string `json:"username"`
+}
+
+type gitlabNote struct {
+	Body      string     `json:"body"`
+	System    bool       `json:"system"`
+	Author    gitlabUser `json:"author"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (gi gitlabIssue) issue() Issue {
+	iss := Issue{
+		Number: gi.IID,
+		Title:  gi.Title,
+		Body:   gi.Description,
+		URL:    gi.WebURL,
+		Author: Actor{Login: gi.Author.Username},
+	}
+	for _, l := range gi.Labels {
+		iss.Labels = append(iss.Labels, Label{Name: l})
+	}
+	if gi.Milestone != nil {
+		iss.Milestone = &Milestone{Title: gi.Milestone.Title}
+	}
+	return iss
+}
+
+// ListIssues implements Forge.
+func (g *GitLab) ListIssues(f Filter, limit int) ([]Issue, error) {
+	q := url.Values{"state": {"opened"}, "scope": {"all"}}
+	if len(f.Labels) > 0 {
+		q.Set("labels", strings.Join(f.Labels, ","))
+	}
+	// @me is matched by user id rather than scope, which only takes one
+	// of assigned_to_me and created_by_me.
+	for _, p := range []struct{ value, key string }{{f.Assignee, "assignee"}, {f.Author, "author"}} {
+		switch p.value {
+		case "":
+		case "@me":
+			id, err := g.userID()
+			if err != nil {
+				return nil, fmt.Errorf("list GitLab issues: %w", err)
+			}
+			q.Set(p.key+"_id", strconv.Itoa(id))
+		default:
+			q.Set(p.key+"_username", p.value)
+		}
+	}
+	if f.Milestone != "" {
+		q.Set("milestone", f.Milestone)
+	}
+	if f.Search != "" {
+		q.Set("search", f.Search)
+	}
+
+	const perPage = 100
+	q.Set("per_page", strconv.Itoa(perPage))
+	raw, err := paginate(limit, perPage, func(page int) ([]gitlabIssue, error) {
+		q.Set("page", strconv.Itoa(page))
+		var items []gitlabIssue
+		err := g.api.do("GET", g.path("/issues?"+q.Encode()), nil, &items)
+		return items, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list GitLab issues: %w", err)
+	}
+
+	issues := make([]Issue, len(raw))
+	for i, gi := range raw {
+		issues[i] = gi.issue()
+	}
+	return issues, nil
+}
+
+// GetIssue implements Forge.
+func (g *GitLab) GetIssue(number int) (Issue, error) {
+	var gi gitlabIssue
+	if err := g.api.do("GET", g.path(fmt.Sprintf("/issues/%d", number)), nil, &gi); err != nil {
+		return Issue{}, fmt.Errorf("get GitLab issue: %w", err)
+	}
+	iss := gi.issue()
+
+	var notes []gitlabNote
+	path := g.path(fmt.Sprintf("/issues/%d/notes?sort=asc&per_page=100", number))
+	if err := g.api.do("GET", path, nil, &notes); err != nil {
+		return Issue{}, fmt.Errorf("get GitLab issue notes: %w", err)
+	}
+	for _, n := range notes {
+		if n.System {
+			continue // "changed the description", "added label", …
+		}
+		iss.Comments = append(iss.Comments, Comment{
+			Author:    Actor{Login: n.Author.Username},
+			Body:      n.Body,
+			CreatedAt: n.CreatedAt,
+		})
+	}
+
+	iss.Linked = g.linkedItems(iss)
+	iss.Detailed = true
+	return iss, nil
+}
+
+func (g *GitLab) linkedItems(iss Issue) []LinkedItem {
+	var items []LinkedItem
+
+	var mrs []gitlabIssue
+	if g.api.do("GET", g.path(fmt.Sprintf("/issues/%d/related_merge_requests", iss.Number)), nil, &mrs) == nil {
+		for _, mr := range mrs {
+			items = append(items, LinkedItem{Number: mr.IID, Title: mr.Title, State: mr.State, IsPR: true})
+		}
+	}
+	var links []gitlabIssue
+	if g.api.do("GET", g.path(fmt.Sprintf("/issues/%d/links", iss.Number)), nil, &links) == nil {
+		for _, l := range links {
+			items = append(items, LinkedItem{Number: l.IID, Title: l.Title, State: l.State})
+		}
+	}
+
+	webBase, _, _ := strings.Cut(iss.URL, "/-/issues/")
+	return resolveReferences(iss, webBase, items, func(n int) (LinkedItem, error) {
+		var gi gitlabIssue
+		err := g.api.do("GET", g.path(fmt.Sprintf("/issues/%d", n)), nil, &gi)
+		return LinkedItem{Number: gi.IID, Title: gi.Title, State: gi.State}, err
+	})
+}
+
+// CreatePR implements Forge by opening a merge request into the project's
+// default branch.
+func (g *GitLab) CreatePR(worktreeDir string, pr PullRequest) (string, error) {
+	branch, err := currentBranch(worktreeDir)
+	if err != nil {
+		return "", err
+	}
+	if err := pushHead(worktreeDir); err != nil {
+		return "", err
+	}
+
+	base := pr.Base
+	if base == "" {
+		var project struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		if err := g.api.do("GET", g.path(""), nil, &project); err != nil {
+			return "", fmt.Errorf("get GitLab project: %w", err)
+		}
+		base = project.DefaultBranch
+	}
+
+	req := map[string]any{
+		"source_branch": branch,
+		"target_branch": base,
+		"title":         pr.Title,
+		"description":   pr.Body,
+	}
+	var mr struct {
+		WebURL string `json:"web_url"`
+	}
+	if err := g.api.do("POST", g.path("/merge_requests"), req, &mr); err != nil {
+		return "", fmt.Errorf("create merge request: %w", err)
+	}
+	return mr.WebURL, nil
+}
+
+// PRStatus implements Forge with the merge request's state and the jobs of
+// its head pipeline.
+func (g *GitLab) PRStatus(prURL string) (PRStatus, error) {
+	iid, err := prNumber(prURL, "merge_requests")
+	if err != nil {
+		return PRStatus{}, err
+	}
+	var mr struct {
+		State        string `json:"state"`
+		HeadPipeline *struct {
+			ID int `json:"id"`
+		} `json:"head_pipeline"`
+	}
+	if err := g.api.do("GET", g.path(fmt.Sprintf("/merge_requests/%d", iid)), nil, &mr); err != nil {
+		return PRStatus{}, fmt.Errorf("get merge request: %w", err)
+	}
+	st := PRStatus{State: mr.State}
+	if st.State == "opened" {
+		st.State = "open"
+	}
+	if mr.HeadPipeline == nil {
+		return st, nil
+	}
+	var jobs []struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+		WebURL string `json:"web_url"`
+	}
+	if err := g.api.do("GET", g.path(fmt.Sprintf("/pipelines/%d/jobs?per_page=100", mr.HeadPipeline.ID)), nil, &jobs); err != nil {
+		return st, fmt.Errorf("get pipeline jobs: %w", err)
+	}
+	for _, j := range jobs {
+		st.Checks = append(st.Checks, Check{Name: j.Name, Status: j.Status, URL: j.WebURL})
+	}
+	return st, nil
+}
+
+// userID returns the id of the user the token belongs to.
+func (g *GitLab) userID() (int, error) {
+	if g.me == 0 {
+		var user struct {
+			ID int `json:"id"`
+		}
+		if err := g.api.do("GET", "/user", nil, &user); err != nil {
+			return 0, fmt.Errorf("get GitLab user: %w", err)
+		}
+		g.me = user.ID
+	}
+	return g.me, nil
+}
+
+// path returns the API path of a project sub-resource.
+func (g *GitLab) path(suffix string) string {
+	return "/projects/" + g.project + suffix
+}
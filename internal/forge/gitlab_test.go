@@ -0,0 +1,201 @@
+package forge
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const gitlabProject = "/api/v4/projects/group%2Frepo"
+
+func TestGitLabListIssues(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"GET /api/v4/user": `{"id": 7, "username": "me"}`,
+		"GET " + gitlabProject + "/issues": `[
+			{"iid": 2, "title": "Crash", "state": "opened", "web_url": "https://gitlab.example.com/group/repo/-/issues/2",
+			 "labels": ["bug"], "author": {"username": "ann"}, "milestone": {"title": "v1"}},
+			{"iid": 1, "title": "Docs", "state": "opened", "labels": []}
+		]`,
+	})
+	g := NewGitLab(api.URL, "group/repo", "s3cret", api.Client())
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   map[string]string // query parameters, "" for absent
+	}{
+		{
+			name:   "assigned to and created by me",
+			filter: Filter{Assignee: "@me", Author: "@me", Labels: []string{"bug", "ui"}},
+			want: map[string]string{
+				"scope": "all", "state": "opened", "labels": "bug,ui",
+				"assignee_id": "7", "author_id": "7", "assignee_username": "", "author_username": "",
+			},
+		},
+		{
+			name:   "other users",
+			filter: Filter{Assignee: "bob", Author: "ann", Milestone: "v1", Search: "crash"},
+			want: map[string]string{
+				"scope": "all", "assignee_username": "bob", "author_username": "ann",
+				"assignee_id": "", "author_id": "", "milestone": "v1", "search": "crash",
+			},
+		},
+	}
+	for _, tt := range tests {
+		issues, err := g.ListIssues(tt.filter, 10)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		q := api.request("GET", gitlabProject+"/issues").query
+		for k, v := range tt.want {
+			if got := q.Get(k); got != v {
+				t.Errorf("%s: query %s = %q, want %q", tt.name, k, got, v)
+			}
+		}
+		want := []Issue{
+			{
+				Number: 2, Title: "Crash", URL: "https://gitlab.example.com/group/repo/-/issues/2",
+				Author: Actor{Login: "ann"}, Labels: []Label{{Name: "bug"}}, Milestone: &Milestone{Title: "v1"},
+			},
+			{Number: 1, Title: "Docs"},
+		}
+		if !reflect.DeepEqual(issues, want) {
+			t.Errorf("%s: ListIssues = %+v, want %+v", tt.name, issues, want)
+		}
+	}
+
+	if got := api.request("GET", "/api/v4/user").header.Get("PRIVATE-TOKEN"); got != "s3cret" {
+		t.Errorf("PRIVATE-TOKEN = %q, want s3cret", got)
+	}
+	users := 0
+	for _, r := range api.reqs {
+		if r.path == "/api/v4/user" {
+			users++
+		}
+	}
+	if users != 1 {
+		t.Errorf("looked up the current user %d times, want once", users)
+	}
+
+	issues, err := g.ListIssues(Filter{}, 1)
+	if err != nil || len(issues) != 1 {
+		t.Errorf("ListIssues with limit 1 = %d issues, %v; want 1", len(issues), err)
+	}
+}
+
+func TestGitLabGetIssue(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"GET " + gitlabProject + "/issues/5": `{"iid": 5, "title": "Crash", "description": "Like #9, and #5 itself.",
+			"web_url": "https://gitlab.example.com/group/repo/-/issues/5", "author": {"username": "ann"}}`,
+		"GET " + gitlabProject + "/issues/5/notes": `[
+			{"body": "added ~bug label", "system": true, "author": {"username": "bot"}},
+			{"body": "Same as !12", "author": {"username": "bob"}, "created_at": "2024-05-01T10:00:00Z"}
+		]`,
+		"GET " + gitlabProject + "/issues/5/related_merge_requests": `[{"iid": 12, "title": "Fix crash", "state": "opened"}]`,
+		"GET " + gitlabProject + "/issues/5/links":                  `[{"iid": 3, "title": "Old crash", "state": "closed"}]`,
+		"GET " + gitlabProject + "/issues/9":                        `{"iid": 9, "title": "Other crash", "state": "opened"}`,
+	})
+	g := NewGitLab(api.URL, "group/repo", "", api.Client())
+
+	iss, err := g.GetIssue(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !iss.Detailed || iss.Title != "Crash" || iss.Author.Login != "ann" {
+		t.Errorf("GetIssue = %+v", iss)
+	}
+	if len(iss.Comments) != 1 || iss.Comments[0].Author.Login != "bob" || iss.Comments[0].Body != "Same as !12" {
+		t.Errorf("Comments = %+v, want only bob's note", iss.Comments)
+	}
+	want := []LinkedItem{
+		{Number: 12, Title: "Fix crash", State: "opened", IsPR: true},
+		{Number: 3, Title: "Old crash", State: "closed"},
+		{Number: 9, Title: "Other crash", State: "opened"},
+	}
+	if !reflect.DeepEqual(iss.Linked, want) {
+		t.Errorf("Linked = %+v, want %+v", iss.Linked, want)
+	}
+	if q := api.request("GET", gitlabProject+"/issues/5/notes").query; q.Get("sort") != "asc" {
+		t.Errorf("notes sort = %q, want asc", q.Get("sort"))
+	}
+}
+
+func TestGitLabCreatePR(t *testing.T) {
+	work := gitWorktree(t, "agent/5")
+	api := newFakeAPI(t, map[string]string{
+		"GET " + gitlabProject:                      `{"default_branch": "main"}`,
+		"POST " + gitlabProject + "/merge_requests": `{"web_url": "https://gitlab.example.com/group/repo/-/merge_requests/12"}`,
+	})
+	g := NewGitLab(api.URL, "group/repo", "", api.Client())
+
+	got, err := g.CreatePR(work, PullRequest{Title: "Fix crash", Body: "Closes #5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://gitlab.example.com/group/repo/-/merge_requests/12"; got != want {
+		t.Errorf("CreatePR = %q, want %q", got, want)
+	}
+	body := api.request("POST", gitlabProject+"/merge_requests").body
+	want := map[string]any{"source_branch": "agent/5", "target_branch": "main", "title": "Fix crash", "description": "Closes #5"}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("merge request = %v, want %v", body, want)
+	}
+	origin := filepath.Join(filepath.Dir(work), "origin.git")
+	if err := exec.Command("git", "--git-dir", origin, "rev-parse", "--verify", "-q", "refs/heads/agent/5").Run(); err != nil {
+		t.Errorf("branch not pushed to origin: %v", err)
+	}
+
+	// An explicit base skips the project lookup.
+	api.reqs = nil
+	if _, err := g.CreatePR(work, PullRequest{Title: "Fix crash", Base: "release"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := api.request("POST", gitlabProject+"/merge_requests").body["target_branch"]; got != "release" {
+		t.Errorf("target_branch = %v, want release", got)
+	}
+	if len(api.reqs) != 1 {
+		t.Errorf("made %d requests, want only the merge request", len(api.reqs))
+	}
+}
+
+func TestGitLabPRStatus(t *testing.T) {
+	api := newFakeAPI(t, map[string]string{
+		"GET " + gitlabProject + "/merge_requests/12": `{"state": "opened", "head_pipeline": {"id": 40}}`,
+		"GET " + gitlabProject + "/merge_requests/13": `{"state": "merged", "head_pipeline": null}`,
+		"GET " + gitlabProject + "/pipelines/40/jobs": `[
+			{"name": "test", "status": "success", "web_url": "https://gitlab.example.com/jobs/1"},
+			{"name": "lint", "status": "failed", "web_url": "https://gitlab.example.com/jobs/2"}
+		]`,
+	})
+	g := NewGitLab(api.URL, "group/repo", "", api.Client())
+
+	tests := []struct {
+		url     string
+		want    PRStatus
+		wantErr bool
+	}{
+		{
+			url: "https://gitlab.example.com/group/repo/-/merge_requests/12",
+			want: PRStatus{State: "open", Checks: []Check{
+				{Name: "test", Status: "success", URL: "https://gitlab.example.com/jobs/1"},
+				{Name: "lint", Status: "failed", URL: "https://gitlab.example.com/jobs/2"},
+			}},
+		},
+		{url: "https://gitlab.example.com/group/repo/-/merge_requests/13/diffs", want: PRStatus{State: "merged"}},
+		{url: "https://gitlab.example.com/group/repo/-/merge_requests/99", wantErr: true},
+		{url: "https://gitlab.example.com/group/repo/-/issues/5", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := g.PRStatus(tt.url)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("PRStatus(%s) = %+v, want an error", tt.url, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("PRStatus(%s) = %+v, %v; want %+v", tt.url, got, err, tt.want)
+		}
+	}
+}
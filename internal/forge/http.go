@@ -0,0 +1,97 @@
+package forge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tomfevang/go-work/internal/telemetry"
+)
+
+// apiClient is a minimal JSON REST client shared by the GitLab and Gitea
+// forges.
+type apiClient struct {
+	base    string // API root, e.g. https://gitlab.example.com/api/v4
+	setAuth func(*http.Request)
+	http    *http.Client
+}
+
+func newAPIClient(base string, setAuth func(*http.Request), hc *http.Client) *apiClient {
+	if hc == nil {
+		hc = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &apiClient{base: strings.TrimRight(base, "/"), setAuth: setAuth, http: hc}
+}
+
+// do sends a request with an optional JSON body and decodes a JSON response
+// into out, if non-nil.
+func (c *apiClient) do(method, path string, body, out any) (err error) {
+	defer func() {
+		if err != nil {
+			telemetry.CommandErrors.Inc("api")
+		}
+	}()
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.base+path, r)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.setAuth(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s\n%s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: parse response: %w", method, path, err)
+	}
+	return nil
+}
+
+// paginate calls fetch for pages 1, 2, … until it returns fewer than perPage
+// items or want items have been collected.
+func paginate[T any](want, perPage int, fetch func(page int) ([]T, error)) ([]T, error) {
+	var all []T
+	for page := 1; len(all) < want; page++ {
+		items, err := fetch(page)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if len(items) < perPage {
+			break
+		}
+	}
+	if len(all) > want {
+		all = all[:want]
+	}
+	return all, nil
+}
@@ -0,0 +1,88 @@
+package forge
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Multi combines the forges of the repos in a workspace. The issues it lists
+// are tagged with their repo's name; use Details to fetch one.
+type Multi struct {
+	names  []string
+	forges map[string]Forge
+}
+
+// NewMulti returns a Multi over the named repos' forges.
+func NewMulti(names []string, forges []Forge) *Multi {
+	m := &Multi{names: names, forges: make(map[string]Forge, len(names))}
+	for i, name := range names {
+		m.forges[name] = forges[i]
+	}
+	return m
+}
+
+func (m *Multi) Name() string { return "workspace" }
+
+// Repo returns the forge of the named repo, or nil.
+func (m *Multi) Repo(name string) Forge { return m.forges[name] }
+
+// ListIssues lists up to limit issues from each repo, in the order the repos
+// were given.
+func (m *Multi) ListIssues(f Filter, limit int) ([]Issue, error) {
+	results := make([][]Issue, len(m.names))
+	errs := make([]error, len(m.names))
+	var wg sync.WaitGroup
+	for i, name := range m.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			issues, err := m.forges[name].ListIssues(f, limit)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", name, err)
+				return
+			}
+			for j := range issues {
+				issues[j].Repo = name
+			}
+			results[i] = issues
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	var all []Issue
+	for _, issues := range results {
+		all = append(all, issues...)
+	}
+	return all, nil
+}
+
+// GetIssue fails: a number alone doesn't say which repo is meant.
+func (m *Multi) GetIssue(number int) (Issue, error) {
+	return Issue{}, fmt.Errorf("issue #%d: the repo is ambiguous in a workspace", number)
+}
+
+// CreatePR fails: pull requests are opened through the repo's own forge.
+func (m *Multi) CreatePR(worktreeDir string, pr PullRequest) (string, error) {
+	return "", errors.New("cannot open a pull request for a whole workspace")
+}
+
+// PRStatus fails: pull requests are looked up through the repo's own forge.
+func (m *Multi) PRStatus(prURL string) (PRStatus, error) {
+	return PRStatus{}, errors.New("cannot look up a pull request for a whole workspace")
+}
+
+// Details fetches the full issue from fg, or from the issue's repo if fg is
+// a Multi, keeping its repo tag.
+func Details(fg Forge, iss Issue) (Issue, error) {
+	if m, ok := fg.(*Multi); ok {
+		if fg = m.Repo(iss.Repo); fg == nil {
+			return Issue{}, fmt.Errorf("%s: unknown repo %q", iss.Ref(), iss.Repo)
+		}
+	}
+	full, err := fg.GetIssue(iss.Number)
+	full.Repo = iss.Repo
+	return full, err
+}
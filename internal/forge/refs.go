@@ -0,0 +1,78 @@
+package forge
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// refPattern matches "#123". References qualified with another repo
+// ("owner/repo#123") are skipped by the leading-character check.
+var refPattern = regexp.MustCompile(`(?:^|[^\w/])#(\d+)\b`)
+
+// References returns the distinct issue and PR numbers mentioned as "#N" in
+// text, in order of first appearance.
+func References(text string) []int {
+	return matchNumbers(refPattern, text)
+}
+
+// urlReferences returns issue and PR numbers linked by URL within the repo
+// at base, e.g. "https://github.com/owner/repo".
+func urlReferences(base, text string) []int {
+	re := regexp.MustCompile(regexp.QuoteMeta(base) + `/(?:issues|pull)/(\d+)\b`)
+	return matchNumbers(re, text)
+}
+
+func matchNumbers(re *regexp.Regexp, text string) []int {
+	var nums []int
+	seen := map[int]bool{}
+	for _, m := range re.FindAllStringSubmatch(text, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || seen[n] {
+			continue
+		}
+		seen[n] = true
+		nums = append(nums, n)
+	}
+	return nums
+}
+
+// maxLinked caps how many linked items GetIssue resolves, since each
+// reference in the issue text costs one API call.
+const maxLinked = 10
+
+// textReferences returns the numbers referenced from an issue's body and
+// comments, including URLs into the same repo when webBase is set.
+func textReferences(iss Issue, webBase string) []int {
+	text := iss.Body
+	for _, c := range iss.Comments {
+		text += "\n" + c.Body
+	}
+	refs := References(text)
+	if webBase != "" {
+		refs = append(refs, urlReferences(webBase, text)...)
+	}
+	return refs
+}
+
+// resolveReferences appends the items referenced from iss's text to items,
+// looking each up with get, until maxLinked is reached. Numbers already in
+// items, and lookups that fail, are skipped.
+func resolveReferences(iss Issue, webBase string, items []LinkedItem, get func(int) (LinkedItem, error)) []LinkedItem {
+	seen := map[int]bool{iss.Number: true}
+	for _, it := range items {
+		seen[it.Number] = true
+	}
+	for _, n := range textReferences(iss, webBase) {
+		if len(items) >= maxLinked {
+			break
+		}
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+		if it, err := get(n); err == nil {
+			items = append(items, it)
+		}
+	}
+	return items
+}
@@ -0,0 +1,45 @@
+// Package glob matches repo paths against the path patterns used in the
+// config.
+package glob
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Match reports whether the slash-separated path file matches pattern. A
+// pattern ending in a slash matches everything in directories of that name
+// or path; one without a slash matches file names, as in path.Match; any
+// other pattern matches the whole path.
+func Match(pattern, file string) bool {
+	if dir, ok := strings.CutSuffix(pattern, "/"); ok {
+		return strings.HasPrefix(file, dir+"/") || strings.Contains(file, "/"+dir+"/")
+	}
+	name := file
+	if !strings.Contains(pattern, "/") {
+		name = path.Base(file)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// Any returns the first of patterns matching file, or "".
+func Any(patterns []string, file string) string {
+	for _, p := range patterns {
+		if Match(p, file) {
+			return p
+		}
+	}
+	return ""
+}
+
+// Validate reports the first invalid pattern, by its index.
+func Validate(patterns []string) error {
+	for i, p := range patterns {
+		if _, err := path.Match(strings.TrimSuffix(p, "/"), ""); err != nil || strings.Trim(p, "/") == "" {
+			return fmt.Errorf("[%d]: %q is not a valid pattern", i, p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package glob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern, file string
+		want          bool
+	}{
+		// File name patterns match in any directory.
+		{"go.sum", "go.sum", true},
+		{"go.sum", "tools/go.sum", true},
+		{"*.lock", "web/yarn.lock", true},
+		{"*.lock", "lock", false},
+		{"*.pem", "certs/server.pem.bak", false},
+		{".env*", "api/.env.local", true},
+
+		// Directory patterns match everything below a directory of that
+		// name, at any depth, or of that path.
+		{"vendor/", "vendor/modules.txt", true},
+		{"vendor/", "tools/vendor/x/y.go", true},
+		{"vendor/", "vendor", false},
+		{"vendor/", "vendored/x.go", false},
+		{"vendor/", "myvendor/x.go", false},
+		{".github/workflows/", ".github/workflows/ci.yml", true},
+		{".github/workflows/", "sub/.github/workflows/ci.yml", true},
+		{".github/workflows/", ".github/ci.yml", false},
+
+		// Other patterns match the whole path.
+		{"cmd/*/main.go", "cmd/go-work/main.go", true},
+		{"cmd/*/main.go", "x/cmd/go-work/main.go", false},
+		{"cmd/*/main.go", "cmd/a/b/main.go", false},
+		{"docs/*.md", "docs/README.md", true},
+		{"docs/*.md", "README.md", false},
+
+		{"[", "[", false}, // malformed patterns match nothing
+	}
+	for _, tt := range tests {
+		if got := Match(tt.pattern, tt.file); got != tt.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestAny(t *testing.T) {
+	patterns := []string{"*.lock", "vendor/", "go.sum"}
+	tests := []struct{ file, want string }{
+		{"vendor/a.lock", "*.lock"},
+		{"vendor/a.go", "vendor/"},
+		{"go.sum", "go.sum"},
+		{"main.go", ""},
+	}
+	for _, tt := range tests {
+		if got := Any(patterns, tt.file); got != tt.want {
+			t.Errorf("Any(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		wantErr  bool
+	}{
+		{[]string{"*.lock", "vendor/", "cmd/*/main.go"}, false},
+		{[]string{"*.lock", "["}, true},
+		{[]string{"/"}, true},
+		{[]string{""}, true},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.patterns); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) = %v, want error %v", tt.patterns, err, tt.wantErr)
+		}
+	}
+}
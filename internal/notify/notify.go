@@ -0,0 +1,164 @@
+// Package notify tells the user about session state changes through the
+// channels configured under notify: the terminal bell, shell commands and
+// JSON webhooks.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"time"
+
+	"github.com/tomfevang/go-work/internal/config"
+	"github.com/tomfevang/go-work/internal/session"
+)
+
+// timeout bounds each command and webhook delivery.
+const timeout = 30 * time.Second
+
+// Notifier fires notifications when it observes a session in a new state.
+type Notifier struct {
+	cfg     config.Notify
+	bell    io.Writer
+	onError func(error)
+	client  *http.Client
+	seen    map[string]session.State // last observed state per session
+}
+
+// New returns a Notifier that rings the bell on bell and reports failed
+// deliveries to onError, which may be nil and is called from other
+// goroutines.
+func New(cfg config.Notify, bell io.Writer, onError func(error)) *Notifier {
+	if onError == nil {
+		onError = func(error) {}
+	}
+	return &Notifier{
+		cfg:     cfg,
+		bell:    bell,
+		onError: onError,
+		client:  &http.Client{Timeout: timeout},
+		seen:    make(map[string]session.State),
+	}
+}
+
+// Observe fires the notifications configured for the session's state if it
+// changed since the session was last observed. Commands and webhooks run in
+// the background. Like the Manager, Observe is not safe for concurrent use.
+func (n *Notifier) Observe(s *session.Session) {
+	key := s.Issue.Key()
+	if prev, ok := n.seen[key]; ok && prev == s.State {
+		return
+	}
+	n.seen[key] = s.State
+	state := s.State.Name()
+
+	if slices.Contains(n.cfg.Bell, state) {
+		fmt.Fprint(n.bell, "\a")
+	}
+	p := newPayload(s)
+	for _, c := range n.cfg.Commands {
+		if slices.Contains(c.On, state) {
+			go n.run(c.Run, p)
+		}
+	}
+	for _, w := range n.cfg.Webhooks {
+		if slices.Contains(w.On, state) {
+			go n.post(w, p)
+		}
+	}
+}
+
+// payload is the JSON body posted to webhooks. Text carries a readable
+// summary for chat webhooks; the other fields are for scripts.
+type payload struct {
+	Text  string `json:"text"`
+	Key   string `json:"key"`
+	Ref   string `json:"ref"`
+	Title string `json:"title"`
+	State string `json:"state"`
+	URL   string `json:"url,omitempty"`
+	PR    string `json:"pr,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func newPayload(s *session.Session) payload {
+	p := payload{
+		Key:   s.Issue.Key(),
+		Ref:   s.Issue.Ref(),
+		Title: s.Issue.Title,
+		State: s.State.Name(),
+		URL:   s.Issue.URL,
+		PR:    s.PR,
+	}
+	if s.Err != nil {
+		p.Error = s.Err.Error()
+	}
+
+	var what string
+	switch s.State {
+	case session.WaitingApproval:
+		what = "plan is ready for approval"
+	case session.Blocked:
+		what = "push blocked by the pre-push checks"
+	case session.Done:
+		what = "done"
+		if s.PR != "" {
+			what += ": " + s.PR
+		} else if s.Branch != "" {
+			what += ", committed on branch " + s.Branch
+		}
+	case session.Failed:
+		what = "failed: " + p.Error
+	default:
+		what = s.State.String()
+	}
+	p.Text = fmt.Sprintf("go-work: %s %s — %s", p.Ref, p.Title, what)
+	return p
+}
+
+func (n *Notifier) run(command string, p payload) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"GO_WORK_TEXT="+p.Text,
+		"GO_WORK_KEY="+p.Key,
+		"GO_WORK_REF="+p.Ref,
+		"GO_WORK_TITLE="+p.Title,
+		"GO_WORK_STATE="+p.State,
+		"GO_WORK_URL="+p.URL,
+		"GO_WORK_PR="+p.PR,
+		"GO_WORK_ERROR="+p.Error,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		n.onError(fmt.Errorf("notify command %q: %w\n%s", command, err, out))
+	}
+}
+
+func (n *Notifier) post(w config.NotifyWebhook, p payload) {
+	body, _ := json.Marshal(p)
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		n.onError(fmt.Errorf("notify webhook: %w", err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		req.Header.Set(k, os.ExpandEnv(v)) // keep tokens out of the file
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		n.onError(fmt.Errorf("notify webhook: %w", err))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		n.onError(fmt.Errorf("notify webhook %s: %s", w.URL, resp.Status))
+	}
+}
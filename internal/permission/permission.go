@@ -0,0 +1,250 @@
+// Package permission bridges claude's permission prompts to go-work. Claude
+// runs non-interactively, so a tool use it may not perform on its own would
+// otherwise fail. Instead, claude is pointed at a small MCP server, go-work
+// itself run as "go-work permission-prompt <socket>", whose one tool it
+// calls to ask; the server forwards each request over a unix socket to the
+// go-work process running the session, where the user answers it.
+package permission
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Command is the hidden go-work subcommand running the MCP server.
+const Command = "permission-prompt"
+
+// Tool is the name claude knows the permission tool by, for
+// --permission-prompt-tool.
+const Tool = "mcp__go-work__approve"
+
+// Request is a tool use claude asks permission for.
+type Request struct {
+	Tool  string          `json:"tool_name"`
+	Input json.RawMessage `json:"input"`
+}
+
+// Summary describes the request in one line, for prompts: the command of a
+// Bash call, the file of an edit, or else the raw input.
+func (r Request) Summary() string {
+	var in struct {
+		Command  string `json:"command"`
+		FilePath string `json:"file_path"`
+		URL      string `json:"url"`
+		Pattern  string `json:"pattern"`
+	}
+	_ = json.Unmarshal(r.Input, &in)
+	for _, s := range []string{in.Command, in.FilePath, in.URL, in.Pattern} {
+		if s != "" {
+			return r.Tool + ": " + s
+		}
+	}
+	return r.Tool + ": " + string(r.Input)
+}
+
+// Decision answers a Request.
+type Decision int
+
+const (
+	Deny        Decision = iota
+	Allow                // this once
+	AlwaysAllow          // this and later uses of the tool in the session
+)
+
+// response is what the MCP tool returns to claude.
+type response struct {
+	Behavior     string          `json:"behavior"` // allow or deny
+	UpdatedInput json.RawMessage `json:"updatedInput,omitempty"`
+	Message      string          `json:"message,omitempty"`
+}
+
+// Bridge receives the requests of one claude run.
+type Bridge struct {
+	dir string
+	ln  net.Listener
+	wg  sync.WaitGroup
+}
+
+// Listen starts a bridge whose socket and MCP config live in a new
+// directory under parent, which must be reachable from where claude runs,
+// sandbox included. ask is called for each request, one at a time, and
+// blocks until it is answered.
+func Listen(parent string, ask func(Request) Decision) (*Bridge, error) {
+	dir, err := os.MkdirTemp(parent, "perm-")
+	if err != nil {
+		return nil, err
+	}
+	sock := filepath.Join(dir, "s")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		ln.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	config, _ := json.Marshal(map[string]any{
+		"mcpServers": map[string]any{
+			"go-work": map[string]any{"command": exe, "args": []string{Command, sock}},
+		},
+	})
+	if err := os.WriteFile(filepath.Join(dir, "mcp.json"), config, 0o600); err != nil {
+		ln.Close()
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	b := &Bridge{dir: dir, ln: ln}
+	var mu sync.Mutex // one prompt at a time
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return // closed
+			}
+			b.wg.Add(1)
+			go func() {
+				defer b.wg.Done()
+				defer conn.Close()
+				var req Request
+				if err := json.NewDecoder(conn).Decode(&req); err != nil {
+					return
+				}
+				mu.Lock()
+				d := ask(req)
+				mu.Unlock()
+				resp := response{Behavior: "deny", Message: "denied by the user"}
+				if d != Deny {
+					resp = response{Behavior: "allow", UpdatedInput: req.Input}
+				}
+				_ = json.NewEncoder(conn).Encode(resp)
+			}()
+		}
+	}()
+	return b, nil
+}
+
+// ConfigPath is the MCP config file to pass to claude with --mcp-config.
+func (b *Bridge) ConfigPath() string { return filepath.Join(b.dir, "mcp.json") }
+
+// Close stops the bridge once pending requests are answered; ask must
+// return for them.
+func (b *Bridge) Close() {
+	b.ln.Close()
+	b.wg.Wait()
+	os.RemoveAll(b.dir)
+}
+
+// rpc is a JSON-RPC 2.0 message, as MCP exchanges them over stdio, one per
+// line.
+type rpc struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+var toolSchema = map[string]any{
+	"name":        "approve",
+	"description": "Asks the go-work user whether a tool may be used.",
+	"inputSchema": map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"tool_name":   map[string]any{"type": "string"},
+			"input":       map[string]any{"type": "object"},
+			"tool_use_id": map[string]any{"type": "string"},
+		},
+		"required": []string{"tool_name", "input"},
+	},
+}
+
+// Serve runs the MCP server on in and out, claude's end of its stdio,
+// forwarding calls of its tool to the bridge listening on socket.
+func Serve(socket string, in io.Reader, out io.Writer) error {
+	enc := json.NewEncoder(out)
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var msg rpc
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil || len(msg.ID) == 0 {
+			continue // unparsable, or a notification
+		}
+		reply := rpc{JSONRPC: "2.0", ID: msg.ID}
+		switch msg.Method {
+		case "initialize":
+			var p struct {
+				ProtocolVersion string `json:"protocolVersion"`
+			}
+			_ = json.Unmarshal(msg.Params, &p)
+			reply.Result = map[string]any{
+				"protocolVersion": p.ProtocolVersion,
+				"capabilities":    map[string]any{"tools": map[string]any{}},
+				"serverInfo":      map[string]any{"name": "go-work", "version": "1"},
+			}
+		case "ping":
+			reply.Result = map[string]any{}
+		case "tools/list":
+			reply.Result = map[string]any{"tools": []any{toolSchema}}
+		case "tools/call":
+			reply.Result = call(socket, msg.Params)
+		default:
+			reply.Error = &rpcError{Code: -32601, Message: "method not found: " + msg.Method}
+		}
+		if err := enc.Encode(reply); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// call forwards a tools/call to the bridge and returns the tool result. A
+// bridge that cannot be reached denies.
+func call(socket string, params json.RawMessage) any {
+	var p struct {
+		Arguments Request `json:"arguments"`
+	}
+	resp := response{Behavior: "deny"}
+	if err := json.Unmarshal(params, &p); err != nil {
+		resp.Message = "invalid request: " + err.Error()
+	} else if err := ask(socket, p.Arguments, &resp); err != nil {
+		resp = response{Behavior: "deny", Message: "go-work is not answering: " + err.Error()}
+	}
+	text, _ := json.Marshal(resp)
+	return map[string]any{"content": []any{map[string]any{"type": "text", "text": string(text)}}}
+}
+
+func ask(socket string, req Request, resp *response) error {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	if err := json.NewDecoder(conn).Decode(resp); err != nil {
+		return fmt.Errorf("read answer: %w", err)
+	}
+	if resp.Behavior != "allow" && resp.Behavior != "deny" {
+		return fmt.Errorf("unexpected answer %q", resp.Behavior)
+	}
+	return nil
+}
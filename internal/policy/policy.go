@@ -0,0 +1,106 @@
+// Package policy checks the changes a session made against the repo's
+// guardrails: paths the agent must not touch without a human noticing, file
+// types it must not add, and limits on the size of the change.
+package policy
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/tomfevang/go-work/internal/glob"
+)
+
+// Actions on violations.
+const (
+	ActionApprove = "approve" // hold the PR until the user approves it
+	ActionBlock   = "block"   // fail the session
+)
+
+// Settings configures the policy. Patterns are as for glob.Match.
+type Settings struct {
+	// Protected lists paths that may only change with the user's approval,
+	// such as CI workflows, migrations and vendored code.
+	Protected []string `yaml:"protected"`
+	// Forbidden lists files that may not be added or changed at all, such
+	// as binaries.
+	Forbidden []string `yaml:"forbidden"`
+	// MaxFiles and MaxLines limit the files changed and the lines added
+	// plus deleted; 0 means no limit.
+	MaxFiles int `yaml:"max_files"`
+	MaxLines int `yaml:"max_lines"`
+	// Action is what violations lead to: approve or block.
+	Action string `yaml:"action"`
+}
+
+// Defaults returns the built-in settings: no rules, and approval for
+// violations once there are.
+func Defaults() Settings {
+	return Settings{Action: ActionApprove}
+}
+
+// Validate reports the first invalid setting, naming its key.
+func (s Settings) Validate() error {
+	if err := glob.Validate(s.Protected); err != nil {
+		return fmt.Errorf("protected%w", err)
+	}
+	if err := glob.Validate(s.Forbidden); err != nil {
+		return fmt.Errorf("forbidden%w", err)
+	}
+	if s.MaxFiles < 0 {
+		return fmt.Errorf("max_files: must not be negative")
+	}
+	if s.MaxLines < 0 {
+		return fmt.Errorf("max_lines: must not be negative")
+	}
+	if s.Action != ActionApprove && s.Action != ActionBlock {
+		return fmt.Errorf("action: %q is not approve or block", s.Action)
+	}
+	return nil
+}
+
+// Enabled reports whether there are any rules.
+func (s Settings) Enabled() bool {
+	return len(s.Protected) > 0 || len(s.Forbidden) > 0 || s.MaxFiles > 0 || s.MaxLines > 0
+}
+
+// Check returns the violations of the changes between base and HEAD in the
+// worktree dir, one per line.
+func Check(dir, base string, s Settings) ([]string, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+	cmd := exec.Command("git", "-C", dir, "-c", "core.quotePath=false", "diff", "--numstat", "--no-renames", base, "HEAD")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff: %w", err)
+	}
+
+	var violations []string
+	files, lines := 0, 0
+	for _, row := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		// added, deleted and path, tab-separated; "-" counts for binaries
+		fields := strings.SplitN(row, "\t", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		file := fields[2]
+		files++
+		added, _ := strconv.Atoi(fields[0])
+		deleted, _ := strconv.Atoi(fields[1])
+		lines += added + deleted
+		if p := glob.Any(s.Forbidden, file); p != "" {
+			violations = append(violations, fmt.Sprintf("%s: forbidden (%s)", file, p))
+		} else if p := glob.Any(s.Protected, file); p != "" {
+			violations = append(violations, fmt.Sprintf("%s: protected path (%s)", file, p))
+		}
+	}
+	if s.MaxFiles > 0 && files > s.MaxFiles {
+		violations = append(violations, fmt.Sprintf("%d files changed; the limit is %d", files, s.MaxFiles))
+	}
+	if s.MaxLines > 0 && lines > s.MaxLines {
+		violations = append(violations, fmt.Sprintf("%d lines changed; the limit is %d", lines, s.MaxLines))
+	}
+	return violations, nil
+}
@@ -0,0 +1,255 @@
+// Package secrets scans the changes a session is about to push for
+// credentials: known token formats, private keys, secrets assigned in code,
+// high-entropy strings and files such as .env that should not be committed.
+package secrets
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tomfevang/go-work/internal/glob"
+)
+
+// Settings configures the scan.
+type Settings struct {
+	// Scan enables the scan before every push.
+	Scan bool `yaml:"scan"`
+	// Allow lists files exempt from the scan, as patterns for glob.Match.
+	Allow []string `yaml:"allow"`
+}
+
+// Defaults returns the built-in settings. Lock files are exempt, being
+// full of checksums.
+func Defaults() Settings {
+	return Settings{
+		Scan:  true,
+		Allow: []string{"go.sum", "*.lock", "package-lock.json", "pnpm-lock.yaml"},
+	}
+}
+
+// Validate reports the first invalid setting, naming its key.
+func (s Settings) Validate() error {
+	if err := glob.Validate(s.Allow); err != nil {
+		return fmt.Errorf("allow%w", err)
+	}
+	return nil
+}
+
+// allowed reports whether file is exempt from the scan.
+func (s Settings) allowed(file string) bool { return glob.Any(s.Allow, file) != "" }
+
+// Finding is a likely secret.
+type Finding struct {
+	File string
+	Line int // 0 for findings about the file itself
+	Rule string
+	// Match is the offending text, mostly masked so the report does not
+	// spread the secret further.
+	Match string
+}
+
+func (f Finding) String() string {
+	loc := f.File
+	if f.Line > 0 {
+		loc += ":" + strconv.Itoa(f.Line)
+	}
+	if f.Match == "" {
+		return loc + ": " + f.Rule
+	}
+	return fmt.Sprintf("%s: %s (%s)", loc, f.Rule, f.Match)
+}
+
+// Report lists findings one per line.
+func Report(findings []Finding) string {
+	var b strings.Builder
+	for _, f := range findings {
+		b.WriteString("- " + f.String() + "\n")
+	}
+	return b.String()
+}
+
+type rule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// rules match known secret formats in added lines. A rule's first
+// submatch, if any, is the secret itself.
+var rules = []rule{
+	{"private key", regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----`)},
+	{"AWS access key", regexp.MustCompile(`\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`)},
+	{"GitHub token", regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"GitLab token", regexp.MustCompile(`\b(glpat-[A-Za-z0-9_-]{20,})`)},
+	{"Slack token", regexp.MustCompile(`\b(xox[abposr]-[A-Za-z0-9-]{10,})`)},
+	{"Slack webhook", regexp.MustCompile(`(https://hooks\.slack\.com/services/[A-Za-z0-9/]{20,})`)},
+	{"Stripe key", regexp.MustCompile(`\b([sr]k_live_[A-Za-z0-9]{20,})`)},
+	{"Google API key", regexp.MustCompile(`\b(AIza[0-9A-Za-z_-]{35})`)},
+	{"API key", regexp.MustCompile(`\b(sk-(?:ant-|proj-)?[A-Za-z0-9_-]{32,})`)},
+	{"JSON web token", regexp.MustCompile(`\b(eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,})`)},
+	{"password in URL", regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@"']+:([^/\s:@"'$]{3,})@`)},
+	{"hard-coded secret", regexp.MustCompile(`(?i)(?:password|passwd|secret|token|api_?key|access_?key|client_?secret)["']?\s*[:=]\s*["']([^"'\s$]{8,})["']`)},
+}
+
+// candidate matches quoted or assigned strings long enough to be a
+// generated secret.
+var candidate = regexp.MustCompile(`["'=:]\s*["']?([A-Za-z0-9+/_=-]{20,})`)
+
+// highEntropy reports whether s looks randomly generated: mixed case and
+// digits, with high Shannon entropy. Words, identifiers and hex digests
+// fall short.
+func highEntropy(s string) bool {
+	var upper, lower, digit bool
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+	}
+	if !upper || !lower || !digit {
+		return false
+	}
+	var h float64
+	for _, n := range counts {
+		p := float64(n) / float64(len(s))
+		h -= p * math.Log2(p)
+	}
+	return h >= 4.0
+}
+
+// sensitiveFile reports why a file should not be committed at all, or "".
+func sensitiveFile(file string) string {
+	name := path.Base(file)
+	switch {
+	case name == ".env" || strings.HasPrefix(name, ".env.") && !isTemplate(name):
+		return "environment file"
+	case name == "id_rsa" || name == "id_dsa" || name == "id_ecdsa" || name == "id_ed25519":
+		return "SSH private key"
+	case strings.HasSuffix(name, ".pem") || strings.HasSuffix(name, ".key") ||
+		strings.HasSuffix(name, ".p12") || strings.HasSuffix(name, ".pfx"):
+		return "key or certificate file"
+	}
+	return ""
+}
+
+func isTemplate(name string) bool {
+	for _, s := range []string{".example", ".sample", ".template", ".dist"} {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScanDiff scans the changes between base and HEAD in the worktree dir.
+func ScanDiff(dir, base string, s Settings) ([]Finding, error) {
+	names, err := git(dir, "diff", "--name-only", "--diff-filter=d", base, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, file := range strings.Split(names, "\n") {
+		if file == "" || s.allowed(file) {
+			continue
+		}
+		if why := sensitiveFile(file); why != "" {
+			findings = append(findings, Finding{File: file, Rule: why})
+		}
+	}
+	diff, err := git(dir, "diff", "--no-color", "--no-ext-diff", "--unified=0", base, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	return append(findings, Scan(diff, s)...), nil
+}
+
+// hunkHeader captures the first new line number of a hunk.
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)`)
+
+// Scan scans the added lines of a unified diff.
+func Scan(diff string, s Settings) []Finding {
+	var findings []Finding
+	var file string
+	line := 0
+	sc := bufio.NewScanner(strings.NewReader(diff))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		text := sc.Text()
+		switch {
+		case strings.HasPrefix(text, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/")
+			if file == "/dev/null" || s.allowed(file) {
+				file = ""
+			}
+			continue
+		case strings.HasPrefix(text, "@@"):
+			if m := hunkHeader.FindStringSubmatch(text); m != nil {
+				line, _ = strconv.Atoi(m[1])
+			}
+			continue
+		case !strings.HasPrefix(text, "+") || file == "":
+			continue
+		}
+		findings = append(findings, scanLine(file, line, text[1:])...)
+		line++
+	}
+	return findings
+}
+
+func scanLine(file string, line int, text string) []Finding {
+	var findings []Finding
+	seen := make(map[string]bool)
+	for _, r := range rules {
+		m := r.re.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		secret := m[0]
+		if len(m) > 1 {
+			secret = m[1]
+		}
+		if seen[secret] {
+			continue // reported by a more specific rule
+		}
+		seen[secret] = true
+		findings = append(findings, Finding{File: file, Line: line, Rule: r.name, Match: mask(secret)})
+	}
+	for _, m := range candidate.FindAllStringSubmatch(text, -1) {
+		if !seen[m[1]] && highEntropy(m[1]) {
+			seen[m[1]] = true
+			findings = append(findings, Finding{File: file, Line: line, Rule: "high-entropy string", Match: mask(m[1])})
+		}
+	}
+	return findings
+}
+
+// mask keeps the first and last few characters of a secret.
+func mask(s string) string {
+	if strings.HasPrefix(s, "-----") {
+		return "" // the rule says it all
+	}
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+	n := min(4, len(s)/4)
+	return s[:n] + "…" + s[len(s)-n:]
+}
+
+func git(dir string, args ...string) (string, error) {
+	out, err := exec.Command("git", append([]string{"-C", dir, "-c", "core.quotePath=false"}, args...)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,134 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/tomfevang/go-work/internal/forge"
+	"github.com/tomfevang/go-work/internal/permission"
+	"github.com/tomfevang/go-work/internal/telemetry"
+)
+
+// SourceBatch marks the pseudo-issue of a batch session, which combines the
+// branches of finished sessions into one pull request.
+const SourceBatch forge.Source = "batch"
+
+// RunBatch cherry-picks the commits of each branch, in order, onto a new
+// branch off the repo's HEAD and opens one PR resolving all issues. Commits
+// already applied through an earlier branch, as with stacked branches, are
+// skipped. Conflicts are handed to claude to resolve.
+//
+// All progress is sent to eventCh under batch's key. If the pre-push checks
+// block the push, the user's decision is awaited on approveCh, and answers
+// to permission prompts on permitCh. Cancelling ctx stops conflict
+// resolution.
+func RunBatch(ctx context.Context, batch Issue, branches []string, issues []Issue, env Env, eventCh chan<- Event, approveCh <-chan Decision, permitCh <-chan permission.Decision) {
+	key := batch.Key()
+	send := func(t EventType, text string) {
+		eventCh <- Event{Key: key, Type: t, Text: text}
+	}
+	ctx, span := telemetry.StartSpan(ctx, "batch", map[string]any{"issue.key": key, "batch.branches": strings.Join(branches, ",")})
+	var runErr error
+	defer func() { span.End(runErr) }()
+	fail := func(err error) {
+		runErr = err
+		send(EventError, err.Error())
+	}
+	perms := newPermits(env.Agent, permitCh, send)
+
+	worktreeDir := env.Worktrees.Path(env.RepoRoot, key)
+	branch := env.Worktrees.BranchName(batch)
+	_ = exec.Command("git", "-C", env.RepoRoot, "worktree", "remove", "--force", worktreeDir).Run()
+	_ = exec.Command("git", "-C", env.RepoRoot, "branch", "-D", branch).Run()
+	if out, err := exec.Command("git", "-C", env.RepoRoot, "worktree", "add", worktreeDir, "-b", branch).CombinedOutput(); err != nil {
+		telemetry.CommandErrors.Inc("git")
+		fail(fmt.Errorf("create worktree: %w\n%s", err, out))
+		return
+	}
+	base, err := gitOutput(worktreeDir, "rev-parse", "HEAD")
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	for _, b := range branches {
+		commits, err := gitOutput(worktreeDir, "rev-list", "--reverse", "--no-merges", "--cherry-pick", "--right-only", "HEAD..."+b)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if commits == "" {
+			send(EventOutput, fmt.Sprintf("%s: nothing to pick.\n", b))
+			continue
+		}
+		for _, c := range strings.Split(commits, "\n") {
+			subject, _ := gitOutput(worktreeDir, "log", "-1", "--format=%s", c)
+			send(EventOutput, fmt.Sprintf("%s: picking %.10s %s\n", b, c, subject))
+			if err := cherryPick(ctx, env.Agent, worktreeDir, c, perms, send); err != nil {
+				fail(fmt.Errorf("%s: %w", b, err))
+				return
+			}
+		}
+	}
+
+	if err := checkPush(ctx, worktreeDir, base, env, send, approveCh); err != nil {
+		fail(err)
+		return
+	}
+	send(EventOutput, "\n=== Creating PR ===\n")
+	title, body, err := env.PRs.prText(issues)
+	if err != nil {
+		fail(err)
+		return
+	}
+	_, endPR := phase(ctx, "pull_request")
+	prURL, err := env.Forge.CreatePR(worktreeDir, forge.PullRequest{Title: title, Body: body})
+	endPR(err)
+	if err != nil {
+		fail(fmt.Errorf("create PR: %w", err))
+		return
+	}
+	send(EventPRDone, prURL)
+}
+
+// cherryPick applies commit in dir. On conflict, claude is asked to resolve
+// the conflicted files; the pick is aborted if markers remain.
+func cherryPick(ctx context.Context, agent AgentSettings, dir, commit string, perms *permits, send func(EventType, string)) error {
+	out, err := exec.Command("git", "-C", dir, "cherry-pick", "--allow-empty", commit).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	conflicted, _ := gitOutput(dir, "diff", "--name-only", "--diff-filter=U")
+	if conflicted == "" {
+		_ = exec.Command("git", "-C", dir, "cherry-pick", "--abort").Run()
+		return fmt.Errorf("cherry-pick %.10s: %w\n%s", commit, err, out)
+	}
+
+	send(EventOutput, "Conflicts in "+strings.ReplaceAll(conflicted, "\n", ", ")+"; resolving.\n")
+	prompt := fmt.Sprintf(
+		"A cherry-pick of commit %s stopped with merge conflicts in these files:\n\n%s\n\n"+
+			"Resolve the conflicts by editing the files so that both sides' changes are kept "+
+			"where they are compatible. Remove all conflict markers. Do NOT run git commands.",
+		commit, conflicted)
+	if _, _, err := runClaude(ctx, agent, agent.Resolve, dir, prompt, "", perms, send); err != nil {
+		_ = exec.Command("git", "-C", dir, "cherry-pick", "--abort").Run()
+		return fmt.Errorf("resolve conflicts: %w", err)
+	}
+
+	if out, err := exec.Command("git", "-C", dir, "add", "-A").CombinedOutput(); err != nil {
+		_ = exec.Command("git", "-C", dir, "cherry-pick", "--abort").Run()
+		return fmt.Errorf("stage resolution: %w\n%s", err, out)
+	}
+	// diff --check reports leftover conflict markers in staged changes.
+	if out, err := exec.Command("git", "-C", dir, "diff", "--cached", "--check").CombinedOutput(); err != nil && strings.Contains(string(out), "conflict marker") {
+		_ = exec.Command("git", "-C", dir, "cherry-pick", "--abort").Run()
+		return fmt.Errorf("conflicts in %.10s left unresolved:\n%s", commit, out)
+	}
+	if out, err := exec.Command("git", "-C", dir, "-c", "core.editor=true", "cherry-pick", "--continue").CombinedOutput(); err != nil {
+		_ = exec.Command("git", "-C", dir, "cherry-pick", "--abort").Run()
+		return fmt.Errorf("continue cherry-pick: %w\n%s", err, out)
+	}
+	return nil
+}
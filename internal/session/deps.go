@@ -0,0 +1,87 @@
+package session
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// depPattern matches phrases like "blocked by #12", "depends on #3 and #4"
+// or "runs after task-2, #7". A bare "after" is left alone, being common in
+// prose such as "regressed after #12". The list is picked apart by
+// depRefPattern.
+var depPattern = regexp.MustCompile(`(?i)\b(?:blocked by|depends on|requires|runs after)\s+` +
+	`((?:#\d+|(?:task|todo|prompt)-\d+)(?:\s*(?:,|and|&)\s*(?:#\d+|(?:task|todo|prompt)-\d+))*)`)
+
+var depRefPattern = regexp.MustCompile(`(?i)#(\d+)|((?:task|todo|prompt)-\d+)`)
+
+// Dependencies returns the keys of the issues that iss says it depends on,
+// parsed from its body and comments. In workspaces, references are to
+// issues of the same repo.
+func Dependencies(iss Issue) []string {
+	text := iss.Body
+	for _, c := range iss.Comments {
+		text += "\n" + c.Body
+	}
+
+	var keys []string
+	seen := map[string]bool{iss.Key(): true}
+	for _, m := range depPattern.FindAllStringSubmatch(text, -1) {
+		for _, ref := range depRefPattern.FindAllStringSubmatch(m[1], -1) {
+			key := ref[1]
+			if key == "" {
+				key = strings.ToLower(ref[2])
+			}
+			if iss.Repo != "" {
+				key = iss.Repo + "-" + key
+			}
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// findCycle returns the keys forming a dependency cycle through start, in
+// order and ending with start again, or nil if there is none.
+func findCycle(start string, deps map[string][]string) []string {
+	var path []string
+	onPath := map[string]bool{}
+	done := map[string]bool{}
+
+	var visit func(k string) []string
+	visit = func(k string) []string {
+		if onPath[k] {
+			if k != start {
+				return nil // a cycle, but not one through start
+			}
+			return append(append([]string{}, path...), k)
+		}
+		if done[k] {
+			return nil
+		}
+		onPath[k] = true
+		path = append(path, k)
+		for _, d := range deps[k] {
+			if c := visit(d); c != nil {
+				return c
+			}
+		}
+		path = path[:len(path)-1]
+		onPath[k] = false
+		done[k] = true
+		return nil
+	}
+	return visit(start)
+}
+
+// describeCycle formats a cycle of session keys for the log.
+func describeCycle(cycle []string, sessions map[string]*Session) string {
+	refs := make([]string, len(cycle))
+	for i, k := range cycle {
+		refs[i] = sessions[k].Issue.Ref()
+	}
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(refs, " → "))
+}
@@ -0,0 +1,70 @@
+package session
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tomfevang/go-work/internal/forge"
+)
+
+func TestDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		comments []string
+		repo     string
+		want     []string
+	}{
+		{name: "none", body: "Crashes on start."},
+		{name: "blocked by", body: "Blocked by #12.", want: []string{"12"}},
+		{name: "depends on a list", body: "Depends on #3, #4 and #5 & #6", want: []string{"3", "4", "5", "6"}},
+		{name: "requires", body: "This requires #7 first.", want: []string{"7"}},
+		{name: "runs after", body: "Runs after task-2, #7", want: []string{"task-2", "7"}},
+		{name: "case and local keys", body: "DEPENDS ON TODO-3 and Prompt-1", want: []string{"todo-3", "prompt-1"}},
+		{name: "bare after is prose", body: "This regressed after #12 was merged."},
+		{name: "mentions", body: "Like #12, see also #13."},
+		{name: "in comments", body: "Crash.", comments: []string{"Blocked by #9", "blocked by #9 and #1"}, want: []string{"9"}},
+		{name: "itself", body: "Depends on #1 and #2", want: []string{"2"}},
+		{name: "workspace", body: "Blocked by #2 and task-3", repo: "api", want: []string{"api-2", "api-task-3"}},
+	}
+	for _, tt := range tests {
+		iss := Issue{Number: 1, Body: tt.body, Repo: tt.repo}
+		for _, c := range tt.comments {
+			iss.Comments = append(iss.Comments, forge.Comment{Body: c})
+		}
+		if got := Dependencies(iss); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Dependencies = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		deps  map[string][]string
+		want  []string
+	}{
+		{name: "no deps", start: "1"},
+		{name: "chain", start: "1", deps: map[string][]string{"1": {"2"}, "2": {"3"}}},
+		{name: "diamond", start: "1", deps: map[string][]string{"1": {"2", "3"}, "2": {"4"}, "3": {"4"}}},
+		{name: "self", start: "1", deps: map[string][]string{"1": {"1"}}, want: []string{"1", "1"}},
+		{name: "pair", start: "1", deps: map[string][]string{"1": {"2"}, "2": {"1"}}, want: []string{"1", "2", "1"}},
+		{
+			name:  "longer",
+			start: "1",
+			deps:  map[string][]string{"1": {"5", "2"}, "2": {"3"}, "3": {"4", "1"}},
+			want:  []string{"1", "2", "3", "1"},
+		},
+		{
+			name:  "cycle not through start",
+			start: "1",
+			deps:  map[string][]string{"1": {"2"}, "2": {"3"}, "3": {"2"}},
+		},
+	}
+	for _, tt := range tests {
+		if got := findCycle(tt.start, tt.deps); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: findCycle = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
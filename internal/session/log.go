@@ -0,0 +1,123 @@
+package session
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	// logChunk is the size of a LogBuffer's in-memory chunks.
+	logChunk = 32 << 10
+	// maxLogMemory is how much of a session's log is kept in memory. Older
+	// chunks are dropped, to be read back from the log file if there is one.
+	maxLogMemory = 1 << 20
+)
+
+// LogBuffer is a session's streamed output. It is kept in chunks, only the
+// most recent maxLogMemory or so in memory, so a verbose session neither
+// grows without bound nor copies its whole log on every append. Once
+// attached to the session's log file in the store, it writes all output
+// there too, and reads older output back from it. The zero value is an
+// empty log.
+type LogBuffer struct {
+	chunks []*strings.Builder // in memory, oldest first
+	start  int                // offset of chunks[0] in the log
+	size   int
+	file   *os.File // the log file, if attached and writable
+	base   int      // offset in the log of the file's first byte
+}
+
+// Attach makes f, an empty file opened for reading and appending, the
+// log's file. Output dropped from memory before is not in it.
+func (l *LogBuffer) Attach(f *os.File) error {
+	l.Close()
+	if _, err := f.WriteString(l.Since(l.start)); err != nil {
+		f.Close()
+		return err
+	}
+	l.file, l.base = f, l.start
+	return nil
+}
+
+// WriteString appends s to the log. It never fails: if the log file can't
+// be written, the log goes on in memory only.
+func (l *LogBuffer) WriteString(s string) (int, error) {
+	if l.file != nil {
+		if _, err := l.file.WriteString(s); err != nil {
+			l.Close()
+		}
+	}
+	n := len(s)
+	for s != "" {
+		if len(l.chunks) == 0 || l.chunks[len(l.chunks)-1].Len() >= logChunk {
+			b := new(strings.Builder)
+			b.Grow(logChunk)
+			l.chunks = append(l.chunks, b)
+		}
+		last := l.chunks[len(l.chunks)-1]
+		part := min(len(s), logChunk-last.Len())
+		last.WriteString(s[:part])
+		s = s[part:]
+	}
+	l.size += n
+	for l.size-l.start > maxLogMemory && len(l.chunks) > 1 {
+		l.start += l.chunks[0].Len()
+		l.chunks[0] = nil
+		l.chunks = l.chunks[1:]
+	}
+	return n, nil
+}
+
+// Write appends p to the log, so fmt.Fprintf can write to it.
+func (l *LogBuffer) Write(p []byte) (int, error) { return l.WriteString(string(p)) }
+
+// Close closes the log file. Output dropped from memory can no longer be
+// read back, and new output is only kept in memory.
+func (l *LogBuffer) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// Len returns the length of the log in bytes.
+func (l *LogBuffer) Len() int { return l.size }
+
+// Start returns the offset of the oldest output held in memory.
+func (l *LogBuffer) Start() int { return l.start }
+
+// Lost reports whether some output before Start can't be read back.
+func (l *LogBuffer) Lost() bool {
+	return l.start > 0 && (l.file == nil || l.base > 0)
+}
+
+// Since returns the log from offset off on. Output before Start is read
+// back from the log file, as far as it holds it.
+func (l *LogBuffer) Since(off int) string {
+	off = max(off, 0)
+	var b strings.Builder
+	b.Grow(l.size - min(off, l.size))
+	if off < l.start {
+		if l.file != nil && l.base < l.start {
+			from := max(off, l.base)
+			buf := make([]byte, l.start-from)
+			if n, err := l.file.ReadAt(buf, int64(from-l.base)); err == nil {
+				b.Write(buf[:n])
+			}
+		}
+		off = l.start
+	}
+	pos := l.start
+	for _, c := range l.chunks {
+		if end := pos + c.Len(); off < end {
+			b.WriteString(c.String()[max(off-pos, 0):])
+		}
+		pos += c.Len()
+	}
+	return b.String()
+}
+
+// String returns the whole log, as far as it can be read.
+func (l *LogBuffer) String() string { return l.Since(0) }
@@ -0,0 +1,150 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// logLines returns n numbered lines, so any part of the log is unique.
+func logLines(from, n int) string {
+	var b strings.Builder
+	for i := from; i < from+n; i++ {
+		fmt.Fprintf(&b, "line %07d of some session output\n", i)
+	}
+	return b.String()
+}
+
+func logFile(t *testing.T) *os.File {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(t.TempDir(), "1.log"), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestLogBufferEviction(t *testing.T) {
+	var l LogBuffer
+	want := logLines(0, 100000) // about 3.4 MiB
+	for rest := want; rest != ""; {
+		n := min(len(rest), 5000) // writes straddle chunks
+		l.WriteString(rest[:n])
+		rest = rest[n:]
+	}
+
+	if l.Len() != len(want) {
+		t.Errorf("Len = %d, want %d", l.Len(), len(want))
+	}
+	if mem := l.Len() - l.Start(); mem > maxLogMemory+logChunk || mem < maxLogMemory-logChunk {
+		t.Errorf("%d bytes in memory, want about %d", mem, maxLogMemory)
+	}
+	if !l.Lost() {
+		t.Error("Lost = false without a log file")
+	}
+	if got := l.String(); got != want[l.Start():] {
+		t.Errorf("String returns %d bytes, want the %d in memory", len(got), len(want)-l.Start())
+	}
+	for _, off := range []int{0, l.Start() - 10, l.Start(), l.Start() + 12345, l.Len(), l.Len() + 1} {
+		if got, wantSince := l.Since(off), want[min(max(off, l.Start()), len(want)):]; got != wantSince {
+			t.Errorf("Since(%d) returns %d bytes, want %d", off, len(got), len(wantSince))
+		}
+	}
+}
+
+func TestLogBufferFile(t *testing.T) {
+	var l LogBuffer
+	head := "before the file\n"
+	l.WriteString(head)
+	f := logFile(t)
+	if err := l.Attach(f); err != nil {
+		t.Fatal(err)
+	}
+	want := head + logLines(0, 100000)
+	l.WriteString(want[len(head):])
+
+	if l.Start() == 0 {
+		t.Fatal("nothing was dropped from memory")
+	}
+	if l.Lost() {
+		t.Error("Lost = true with the whole log in the file")
+	}
+	// Output before Start is read back from the file.
+	for _, off := range []int{0, 5, l.Start() - 1, l.Start(), l.Start() + 1} {
+		if got := l.Since(off); got != want[off:] {
+			t.Errorf("Since(%d) returns %d bytes, want %d", off, len(got), len(want)-off)
+		}
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil || string(data) != want {
+		t.Errorf("log file has %d bytes, %v; want the whole log of %d", len(data), err, len(want))
+	}
+
+	// Once the file fails, output goes on in memory only, and what was
+	// dropped is lost.
+	f.Close()
+	more := logLines(100000, 100000)
+	l.WriteString(more)
+	want += more
+	if !l.Lost() {
+		t.Error("Lost = false after the log file failed")
+	}
+	if got := l.String(); got != want[l.Start():] {
+		t.Errorf("String returns %d bytes, want the %d in memory", len(got), len(want)-l.Start())
+	}
+}
+
+func TestLogBufferAttachLate(t *testing.T) {
+	var l LogBuffer
+	want := logLines(0, 50000)
+	l.WriteString(want)
+	start := l.Start()
+	if start == 0 {
+		t.Fatal("nothing was dropped from memory")
+	}
+	f := logFile(t)
+	if err := l.Attach(f); err != nil {
+		t.Fatal(err)
+	}
+	more := logLines(50000, 50000)
+	l.WriteString(more)
+	want += more
+
+	if !l.Lost() {
+		t.Error("Lost = false with output dropped before the file was attached")
+	}
+	// The file holds the log from where memory started when attached.
+	if got := l.String(); got != want[start:] {
+		t.Errorf("String returns %d bytes, want %d from offset %d", len(got), len(want)-start, start)
+	}
+	if got := l.Since(start + 100); got != want[start+100:] {
+		t.Errorf("Since(%d) returns %d bytes, want %d", start+100, len(got), len(want)-start-100)
+	}
+}
+
+func TestStoreAttachLog(t *testing.T) {
+	st, err := OpenStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A log left by an earlier run is replaced.
+	if err := os.WriteFile(st.LogPath("1"), []byte("old run\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var l LogBuffer
+	defer l.Close()
+	l.WriteString("first\n")
+	for range 2 {
+		if err := st.attachLog("1", &l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.WriteString("second\n")
+	data, err := os.ReadFile(st.LogPath("1"))
+	if err != nil || string(data) != "first\nsecond\n" {
+		t.Errorf("log file = %q, %v; want %q", data, err, "first\nsecond\n")
+	}
+}
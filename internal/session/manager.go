@@ -0,0 +1,755 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/tomfevang/go-work/internal/permission"
+	"github.com/tomfevang/go-work/internal/telemetry"
+)
+
+// Manager owns the sessions of one go-work run. It starts a runner goroutine
+// per session, applies the runners' events to session state, and holds back
+// sessions until the issues they depend on are done.
+//
+// Manager is not safe for concurrent use; the TUI drives it from its update
+// loop, feeding it the events read from Events.
+type Manager struct {
+	envs       map[string]Env // by Issue.Repo
+	events     chan Event
+	sessions   map[string]*Session // keyed by Issue.Key
+	order      []string            // keys in the order sessions were added
+	approveChs map[string]chan Decision
+	permitChs  map[string]chan permission.Decision
+	followChs  map[string]chan FollowUp
+	prompts    bool                          // someone is watching to answer permission prompts
+	cancels    map[string]context.CancelFunc // of started runners
+	started    map[string]bool
+	batches    int              // number of batch sessions created
+	limit      int              // maximum sessions in progress at once; 0 means no limit
+	recorded   map[string]State // states last reported to telemetry
+	store      *Store           // persists sessions, if set
+}
+
+// NewManager returns a Manager with no sessions, which runs each session in
+// the environment of its issue's repo. Outside workspaces, envs has the one
+// key "".
+func NewManager(envs map[string]Env) *Manager {
+	return &Manager{
+		envs:       envs,
+		events:     make(chan Event, 64),
+		sessions:   make(map[string]*Session),
+		approveChs: make(map[string]chan Decision),
+		permitChs:  make(map[string]chan permission.Decision),
+		followChs:  make(map[string]chan FollowUp),
+		cancels:    make(map[string]context.CancelFunc),
+		started:    make(map[string]bool),
+		recorded:   make(map[string]State),
+	}
+}
+
+// SetLimit caps the number of sessions in progress at once. Further ready
+// sessions stay pending until others finish. Zero removes the cap.
+func (m *Manager) SetLimit(n int) {
+	defer m.record()
+	m.limit = n
+	m.startReady()
+}
+
+// EnablePrompts lets sessions started from now on ask the user for
+// permission to use tools, where the agent settings allow it. Only
+// interactive front ends, which answer with Permit, should enable them.
+func (m *Manager) EnablePrompts() { m.prompts = true }
+
+// SetStore persists the sessions to st after every change, from now on.
+func (m *Manager) SetStore(st *Store) {
+	m.store = st
+	m.record()
+}
+
+// Close releases what the sessions hold outside memory, such as their log
+// files, once the Manager is no longer used. Runners still going are
+// not stopped.
+func (m *Manager) Close() {
+	for _, s := range m.sessions {
+		s.Log.Close()
+	}
+}
+
+// Env returns the environment the session with the given key runs in.
+func (m *Manager) Env(key string) Env { return m.envs[m.sessions[key].Issue.Repo] }
+
+// Events is the channel runners report progress on. Every event read from it
+// must be passed to Handle.
+func (m *Manager) Events() <-chan Event { return m.events }
+
+// Order returns the session keys in display order.
+func (m *Manager) Order() []string { return m.order }
+
+// Get returns the session with the given key, or nil.
+func (m *Manager) Get(key string) *Session { return m.sessions[key] }
+
+// Add creates a session per issue and starts those whose prerequisites are
+// met. It returns the keys of all sessions whose state changed.
+func (m *Manager) Add(issues []Issue) []string {
+	defer m.record()
+	var added []string
+	for _, iss := range issues {
+		key := iss.Key()
+		if _, ok := m.sessions[key]; ok {
+			continue
+		}
+		m.sessions[key] = &Session{Issue: iss, State: Pending}
+		m.approveChs[key] = make(chan Decision, 1)
+		m.permitChs[key] = make(chan permission.Decision, 1)
+		m.followChs[key] = make(chan FollowUp, maxFollowUps)
+		m.order = append(m.order, key)
+		added = append(added, key)
+	}
+
+	// Resolve dependencies only once all new sessions exist, so issues
+	// selected together can depend on each other in any order.
+	for _, key := range added {
+		s := m.sessions[key]
+		if _, ok := m.envs[s.Issue.Repo]; !ok {
+			m.fail(s, fmt.Sprintf("unknown repo %q", s.Issue.Repo))
+			m.started[key] = true
+			continue
+		}
+		for _, dep := range Dependencies(s.Issue) {
+			if _, ok := m.sessions[dep]; ok {
+				s.Deps = append(s.Deps, dep)
+			} else {
+				fmt.Fprintf(&s.Log, "Depends on %s, which is not part of this run; not waiting for it.\n", depRef(dep))
+			}
+		}
+	}
+	deps := make(map[string][]string, len(m.sessions))
+	for k, s := range m.sessions {
+		deps[k] = s.Deps
+	}
+	for _, key := range added {
+		if cycle := findCycle(key, deps); cycle != nil {
+			m.fail(m.sessions[key], describeCycle(cycle, m.sessions))
+		}
+	}
+
+	return append(added, m.startReady()...)
+}
+
+// Handle applies an event from a runner to its session. It returns the keys
+// of all sessions whose state changed, which includes dependents started or
+// failed as a result.
+func (m *Manager) Handle(ev Event) []string {
+	defer m.record()
+	s, ok := m.sessions[ev.Key]
+	if !ok {
+		return nil
+	}
+
+	if s.State == Merged {
+		// The runner is winding down; keep its output but not its state.
+		switch ev.Type {
+		case EventOutput:
+			s.Log.WriteString(ev.Text)
+		case EventPlanDone:
+			m.approveChs[ev.Key] <- Decision{Approve: false}
+		}
+		return []string{ev.Key}
+	}
+
+	switch ev.Type {
+	case EventOutput:
+		s.Log.WriteString(ev.Text)
+	case EventPlanDone:
+		s.Plan, s.Edited = ev.Text, false
+		if len(s.FollowUps) == 0 { // else the plan is about to be revised
+			s.State = WaitingApproval
+		}
+	case EventImplDone:
+		s.State = CreatingPR
+	case EventPRDone:
+		s.PR = ev.Text
+		s.State = Done
+		s.Log.WriteString("\n✓ PR: " + ev.Text + "\n")
+	case EventFinished:
+		s.Branch = ev.Text
+		s.State = Done
+		s.Log.WriteString("\n✓ Committed on branch " + ev.Text + "\n")
+	case EventError:
+		if s.State != Failed { // already failed if rejected or cancelled
+			m.fail(s, ev.Text)
+		}
+	case EventPlannedFiles:
+		s.PlannedFiles = splitLines(ev.Text)
+	case EventFilesChanged:
+		s.ChangedFiles = union(s.ChangedFiles, splitLines(ev.Text))
+	case EventTool:
+		s.Tools = append(s.Tools, ev.Text)
+	case EventFollowUp:
+		if len(s.FollowUps) > 0 {
+			s.FollowUps = s.FollowUps[1:]
+		}
+		s.Log.WriteString("\n> " + ev.Text + "\n")
+		if s.State == WaitingApproval {
+			s.State = Planning
+		}
+	case EventPermission:
+		s.Prompt = ev.Text
+		if ev.Text != "" {
+			s.Log.WriteString("\n? Permission requested: " + ev.Text + "\n")
+		}
+	case EventBlocked:
+		s.Findings = ev.Text
+		s.State = Blocked
+		s.Log.WriteString("\n✗ Push blocked by the pre-push checks.\n\n" + ev.Text +
+			"\nApprove to push anyway, or reject to fail the session.\n")
+	}
+
+	if s.State != Planning && s.State != WaitingApproval && s.State != Implementing {
+		m.dropFollowUps(s)
+	}
+	changed := []string{ev.Key}
+	if s.State == Done || s.State == Failed {
+		s.Prompt = ""
+		if s.Batch != nil {
+			changed = append(changed, m.settleBatch(s)...)
+		}
+		changed = append(changed, m.startReady()...)
+	}
+	return changed
+}
+
+// Batch combines finished sessions, in display order, into a new batch
+// session that opens a single PR for all their issues. It returns the key of
+// the batch session.
+func (m *Manager) Batch(keys []string) (string, error) {
+	defer m.record()
+	var members []string
+	for _, k := range m.order {
+		if !slices.Contains(keys, k) {
+			continue
+		}
+		if s := m.sessions[k]; !s.Batchable() {
+			return "", fmt.Errorf("%s has no committed branch to batch", s.Issue.Ref())
+		}
+		members = append(members, k)
+	}
+	if len(members) == 0 {
+		return "", fmt.Errorf("no sessions to batch")
+	}
+
+	repo := m.sessions[members[0]].Issue.Repo
+	var branches, refs []string
+	var issues []Issue
+	for _, k := range members {
+		s := m.sessions[k]
+		if s.Issue.Repo != repo {
+			return "", fmt.Errorf("cannot batch sessions of different repos")
+		}
+		branches = append(branches, s.Branch)
+		refs = append(refs, s.Issue.Ref())
+		issues = append(append(issues, s.Issue), s.Also...)
+	}
+	m.batches++
+	batch := Issue{
+		Source:   SourceBatch,
+		Repo:     repo,
+		Number:   m.batches,
+		Title:    "Batch: " + strings.Join(refs, ", "),
+		Detailed: true,
+	}
+	key := batch.Key()
+	b := &Session{Issue: batch, State: CreatingPR, Batch: members}
+	fmt.Fprintf(&b.Log, "Combining %s.\n", strings.Join(branches, ", "))
+	m.sessions[key] = b
+	m.order = append(m.order, key)
+	m.started[key] = true
+	m.approveChs[key] = make(chan Decision, 1)
+	m.permitChs[key] = make(chan permission.Decision, 1)
+	for _, k := range members {
+		m.sessions[k].BatchedInto = key
+	}
+
+	go RunBatch(m.runCtx(key), batch, branches, issues, m.promptEnv(repo), m.events, m.approveChs[key], m.permitChs[key])
+	return key, nil
+}
+
+// settleBatch records a finished batch on its members: the shared PR if it
+// was opened, otherwise the members are freed to be batched again.
+func (m *Manager) settleBatch(b *Session) []string {
+	for _, k := range b.Batch {
+		s := m.sessions[k]
+		if b.State == Done {
+			s.PR = b.PR
+			s.Log.WriteString("\n✓ Batched into PR: " + b.PR + "\n")
+		} else {
+			s.BatchedInto = ""
+		}
+	}
+	return b.Batch
+}
+
+// Approve delivers the user's decision on a plan, or on pushing a blocked
+// session anyway. It reports whether the session was waiting for one. An
+// approved serialized session whose prerequisites are unfinished is queued
+// until they are done. Other sessions may be started or failed as a result.
+func (m *Manager) Approve(key string, approved bool) bool {
+	defer m.record()
+	s, ok := m.sessions[key]
+	if ok && s.State == Blocked {
+		m.override(s, approved)
+		return true
+	}
+	if !ok || s.State != WaitingApproval || len(s.FollowUps) > 0 {
+		return false
+	}
+	if !approved {
+		s.State = Failed
+		s.Err = fmt.Errorf("plan rejected by user")
+		s.Log.WriteString("\n✗ Plan rejected by user.\n")
+		m.approveChs[key] <- Decision{Approve: false}
+		m.startReady()
+		return true
+	}
+
+	s.State = Queued
+	m.startReady()
+	return true
+}
+
+// override lets a session blocked by the pre-push checks push anyway, or
+// fails it.
+func (m *Manager) override(s *Session, push bool) {
+	m.approveChs[s.Issue.Key()] <- Decision{Approve: push}
+	if !push {
+		s.State = Failed
+		s.Err = fmt.Errorf("push blocked by the pre-push checks")
+		s.Log.WriteString("\n✗ Push rejected by user.\n")
+		if s.Batch != nil {
+			m.settleBatch(s)
+		}
+		m.startReady()
+		return
+	}
+	s.State = CreatingPR
+	s.Findings = ""
+}
+
+// Permit answers the permission request a session is waiting on. It
+// reports whether there was one.
+func (m *Manager) Permit(key string, d permission.Decision) bool {
+	s, ok := m.sessions[key]
+	if !ok || s.Prompt == "" {
+		return false
+	}
+	m.permitChs[key] <- d
+	switch d {
+	case permission.Allow:
+		s.Log.WriteString("→ Allowed.\n")
+	case permission.AlwaysAllow:
+		s.Log.WriteString("→ Allowed for the rest of the session.\n")
+	default:
+		s.Log.WriteString("→ Denied.\n")
+	}
+	s.Prompt = ""
+	return true
+}
+
+// EditPlan replaces the plan of a session waiting for approval with the
+// user's edit of it, old, recording the changes in the log. Approving the
+// session then implements the edited plan.
+func (m *Manager) EditPlan(key, old, edited string) error {
+	defer m.record()
+	s, ok := m.sessions[key]
+	if !ok || s.State != WaitingApproval {
+		return fmt.Errorf("no plan waiting for approval")
+	}
+	if s.Plan != old {
+		return fmt.Errorf("the plan changed while it was being edited")
+	}
+	if strings.TrimSpace(edited) == "" {
+		return fmt.Errorf("the edited plan is empty")
+	}
+	if edited == old {
+		return nil
+	}
+	env := m.envs[s.Issue.Repo]
+	s.Plan, s.Edited = edited, true
+	s.PlannedFiles = plannedFiles(env.Worktrees.Path(env.RepoRoot, key), edited)
+	s.Log.WriteString("\n✎ Plan edited:\n\n```diff\n" + planDiff(old, edited) + "```\n")
+	return nil
+}
+
+// maxFollowUps is how many follow-up messages a session queues at most.
+const maxFollowUps = 8
+
+// FollowUp queues a message for the agent of a session that is planning,
+// waiting for approval or implementing. Until the plan is approved the
+// message revises it, so a waiting plan goes back to planning; during
+// implementation it is delivered once the agent finishes its current run.
+// A reply to a plan the user edited comes with the edit, so the agent
+// revises that rather than its own plan.
+func (m *Manager) FollowUp(key, text string) error {
+	defer m.record()
+	s, ok := m.sessions[key]
+	if !ok {
+		return fmt.Errorf("no session %s", key)
+	}
+	switch s.State {
+	case Planning, WaitingApproval, Implementing:
+	default:
+		return fmt.Errorf("%s is %s; only planning or implementing agents take follow-ups", s.Issue.Ref(), strings.ToLower(s.State.String()))
+	}
+	f := FollowUp{Text: text}
+	if s.State == WaitingApproval && s.Edited {
+		f.Plan = s.Plan
+	}
+	select {
+	case m.followChs[key] <- f:
+	default:
+		return fmt.Errorf("%s already has %d follow-ups queued", s.Issue.Ref(), maxFollowUps)
+	}
+	s.FollowUps = append(s.FollowUps, text)
+	if s.State == WaitingApproval {
+		s.State = Planning
+	}
+	return nil
+}
+
+// dropFollowUps discards the follow-ups of a session whose agent will not
+// run again.
+func (m *Manager) dropFollowUps(s *Session) {
+	if len(s.FollowUps) == 0 {
+		return
+	}
+	s.Log.WriteString("\n✗ Follow-ups not delivered:\n")
+	for _, text := range s.FollowUps {
+		s.Log.WriteString("> " + text + "\n")
+	}
+	s.FollowUps = nil
+}
+
+// Waiting returns the references of the unfinished issues a pending or
+// queued session is waiting for.
+func (m *Manager) Waiting(key string) []string {
+	var refs []string
+	for _, dep := range m.sessions[key].Deps {
+		if d := m.resolve(dep); d.State != Done {
+			refs = append(refs, d.Issue.Ref())
+		}
+	}
+	return refs
+}
+
+// Overlap is another session touching some of the same files.
+type Overlap struct {
+	Key   string
+	Files []string
+}
+
+// Overlaps returns the other active sessions whose planned or changed files
+// intersect the session's. Sessions already ordered by a dependency, in
+// either direction, are not reported.
+func (m *Manager) Overlaps(key string) []Overlap {
+	s := m.sessions[key]
+	if !s.State.Active() {
+		return nil
+	}
+	mine := make(map[string]bool)
+	for _, f := range s.Files() {
+		mine[f] = true
+	}
+	if len(mine) == 0 {
+		return nil
+	}
+
+	var out []Overlap
+	for _, k := range m.order {
+		o := m.sessions[k]
+		if k == key || !o.State.Active() || slices.Contains(s.Deps, k) || slices.Contains(o.Deps, key) {
+			continue
+		}
+		var shared []string
+		for _, f := range o.Files() {
+			if mine[f] {
+				shared = append(shared, f)
+			}
+		}
+		if len(shared) > 0 {
+			out = append(out, Overlap{Key: k, Files: shared})
+		}
+	}
+	return out
+}
+
+// Serialize makes a session wait for the session it overlaps with: its
+// implementation starts only once the other is done, on top of the other's
+// branch. It returns the keys whose state changed.
+func (m *Manager) Serialize(key string) ([]string, error) {
+	defer m.record()
+	s := m.sessions[key]
+	other, err := m.overlapTarget(key)
+	if err != nil {
+		return nil, err
+	}
+	deps := map[string][]string{key: append(slices.Clone(s.Deps), other)}
+	for k, o := range m.sessions {
+		if k != key {
+			deps[k] = o.Deps
+		}
+	}
+	if cycle := findCycle(key, deps); cycle != nil {
+		return nil, fmt.Errorf("cannot serialize: %s", describeCycle(cycle, m.sessions))
+	}
+
+	s.Deps = deps[key]
+	s.Serialized = true
+	fmt.Fprintf(&s.Log, "\nSerialized after %s.\n", m.sessions[other].Issue.Ref())
+	return append([]string{key}, m.startReady()...), nil
+}
+
+// Merge hands a session's issue to the session it overlaps with, which
+// then resolves both in one branch and PR. The other session must not have
+// started implementing. It returns the keys whose state changed.
+func (m *Manager) Merge(key string) ([]string, error) {
+	defer m.record()
+	s := m.sessions[key]
+	other, err := m.overlapTarget(key)
+	if err != nil {
+		return nil, err
+	}
+	o := m.sessions[other]
+	switch o.State {
+	case Pending, Planning, WaitingApproval, Queued:
+	default:
+		return nil, fmt.Errorf("cannot merge: %s is already %s", o.Issue.Ref(), strings.ToLower(o.State.String()))
+	}
+
+	o.Also = append(append(o.Also, s.Issue), s.Also...)
+	fmt.Fprintf(&o.Log, "\nMerged %s into this session.\n", s.Issue.Ref())
+	if s.State == WaitingApproval {
+		m.approveChs[key] <- Decision{Approve: false}
+	}
+	m.started[key] = true // never start a pending runner
+	s.State = Merged
+	s.MergedInto = other
+	s.Also = nil
+	m.dropFollowUps(s)
+	fmt.Fprintf(&s.Log, "\n⇢ Merged into %s.\n", o.Issue.Ref())
+	return []string{key, other}, nil
+}
+
+// overlapTarget picks the session that Serialize and Merge act against,
+// checking that key's session can still be reordered.
+func (m *Manager) overlapTarget(key string) (string, error) {
+	s := m.sessions[key]
+	switch s.State {
+	case Pending, Planning, WaitingApproval:
+	default:
+		return "", fmt.Errorf("%s is already %s", s.Issue.Ref(), strings.ToLower(s.State.String()))
+	}
+	overlaps := m.Overlaps(key)
+	if len(overlaps) == 0 {
+		return "", fmt.Errorf("%s does not overlap another session", s.Issue.Ref())
+	}
+	return overlaps[0].Key, nil
+}
+
+// resolve follows merges from a session to the one now handling its issue.
+func (m *Manager) resolve(key string) *Session {
+	s := m.sessions[key]
+	for s.State == Merged {
+		s = m.sessions[s.MergedInto]
+	}
+	return s
+}
+
+// startReady starts pending sessions and releases queued ones whose
+// prerequisites are all done, and fails those with a failed prerequisite,
+// repeating until nothing changes since failures cascade. It returns the
+// keys of the affected sessions.
+func (m *Manager) startReady() []string {
+	var changed []string
+	for progress := true; progress; {
+		progress = false
+		for _, key := range m.order {
+			s := m.sessions[key]
+			pending := s.State == Pending && !m.started[key]
+			if !pending && s.State != Queued {
+				continue
+			}
+
+			ready := true
+			var failed []string
+			for _, dep := range s.Deps {
+				switch d := m.resolve(dep); d.State {
+				case Done:
+				case Failed:
+					failed = append(failed, d.Issue.Ref())
+				default:
+					ready = false
+				}
+			}
+
+			switch {
+			case len(failed) > 0:
+				if s.State == Queued {
+					m.approveChs[key] <- Decision{Approve: false}
+				}
+				m.fail(s, "prerequisite failed: "+strings.Join(failed, ", "))
+			case ready && pending:
+				if m.limit > 0 && m.inProgress() >= m.limit {
+					continue
+				}
+				m.start(key)
+			case ready:
+				m.release(key)
+			default:
+				continue
+			}
+			changed = append(changed, key)
+			progress = true
+		}
+	}
+	return changed
+}
+
+// inProgress counts the sessions whose runner is working or waiting on the
+// user.
+func (m *Manager) inProgress() int {
+	n := 0
+	for _, s := range m.sessions {
+		switch s.State {
+		case Planning, WaitingApproval, Queued, Implementing, CreatingPR, Blocked:
+			n++
+		}
+	}
+	return n
+}
+
+// start launches the runner for a session. With Env.Stack set, the session
+// branches off its prerequisites' branches instead of the repo's HEAD.
+func (m *Manager) start(key string) {
+	s := m.sessions[key]
+	env := m.promptEnv(s.Issue.Repo)
+	var bases []string
+	if env.Stack || s.Serialized {
+		bases = m.depBranches(s)
+	}
+	if len(bases) > 0 {
+		fmt.Fprintf(&s.Log, "Stacked on %s.\n", strings.Join(bases, ", "))
+	}
+
+	m.started[key] = true
+	s.State = Planning
+	go Run(m.runCtx(key), s.Issue, bases, env, m.events, m.approveChs[key], m.permitChs[key], m.followChs[key])
+}
+
+// promptEnv returns the environment of repo, with permission prompts off
+// unless enabled.
+func (m *Manager) promptEnv(repo string) Env {
+	env := m.envs[repo]
+	env.Agent.PermissionPrompts = env.Agent.PermissionPrompts && m.prompts
+	return env
+}
+
+// runCtx returns the context for a session's runner, recording its cancel
+// function for Cancel.
+func (m *Manager) runCtx(key string) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancels[key] = cancel
+	return ctx
+}
+
+// Cancel stops an unfinished session, killing its runner's claude process
+// if one is running. It returns the keys of all sessions whose state
+// changed, including dependents failed as a result.
+func (m *Manager) Cancel(key string) ([]string, error) {
+	defer m.record()
+	s, ok := m.sessions[key]
+	if !ok {
+		return nil, fmt.Errorf("no session %s", key)
+	}
+	switch s.State {
+	case Done, Failed, Merged:
+		return nil, fmt.Errorf("%s is already %s", s.Issue.Ref(), strings.ToLower(s.State.String()))
+	}
+	if cancel := m.cancels[key]; cancel != nil {
+		cancel()
+	}
+	m.started[key] = true // never start a pending runner
+	m.fail(s, "cancelled by user")
+	if s.Batch != nil {
+		m.settleBatch(s)
+	}
+	return append([]string{key}, append(s.Batch, m.startReady()...)...), nil
+}
+
+// release sends the approval of a queued session to its runner. Serialized
+// sessions that started before their prerequisites finished are moved onto
+// the prerequisites' branches first.
+func (m *Manager) release(key string) {
+	s := m.sessions[key]
+	d := Decision{Approve: true, Also: s.Also, Plan: s.Plan}
+	if s.Serialized {
+		d.Bases = m.depBranches(s)
+	}
+	s.State = Implementing
+	m.approveChs[key] <- d
+}
+
+// depBranches returns the branches of the sessions s depends on.
+func (m *Manager) depBranches(s *Session) []string {
+	var bases []string
+	for _, dep := range s.Deps {
+		bases = append(bases, m.envs[s.Issue.Repo].Worktrees.BranchName(m.resolve(dep).Issue))
+	}
+	return bases
+}
+
+// record updates the session metrics and the store after a change.
+func (m *Manager) record() {
+	if m.store != nil {
+		m.store.save(m)
+	}
+	counts := make(map[State]int)
+	for key, s := range m.sessions {
+		counts[s.State]++
+		if prev, ok := m.recorded[key]; (!ok || prev != s.State) && (s.State == Done || s.State == Failed) {
+			telemetry.SessionsFinished.Inc(s.State.Name())
+		}
+		m.recorded[key] = s.State
+	}
+	for st, name := range stateNames {
+		telemetry.Sessions.Set(float64(counts[st]), name)
+	}
+}
+
+func (m *Manager) fail(s *Session, msg string) {
+	s.Err = fmt.Errorf("%s", msg)
+	s.State = Failed
+	s.Prompt = ""
+	m.dropFollowUps(s)
+	s.Log.WriteString("\n✗ Error: " + msg + "\n")
+}
+
+func splitLines(s string) []string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// depRef formats a dependency key the way users write it.
+func depRef(key string) string {
+	if strings.ContainsRune(key, '-') {
+		return key
+	}
+	return "#" + key
+}
@@ -0,0 +1,59 @@
+package session
+
+import (
+	"strings"
+	"testing"
+)
+
+// waitingManager returns a Manager with one session, as if its runner had
+// given plan and were waiting for approval.
+func waitingManager(t *testing.T, plan string) (*Manager, *Session) {
+	t.Helper()
+	m := NewManager(map[string]Env{"": {RepoRoot: t.TempDir(), Worktrees: DefaultWorktrees()}})
+	s := &Session{Issue: Issue{Number: 1, Title: "Crash"}, State: Planning}
+	m.sessions["1"], m.order = s, []string{"1"}
+	m.followChs["1"] = make(chan FollowUp, maxFollowUps)
+	m.Handle(Event{Key: "1", Type: EventPlanDone, Text: plan})
+	if s.State != WaitingApproval {
+		t.Fatalf("state = %s, want waiting for approval", s.State)
+	}
+	return m, s
+}
+
+func TestFollowUpAfterEditPlan(t *testing.T) {
+	m, s := waitingManager(t, "1. Read\n2. Write\n")
+	if err := m.EditPlan("1", s.Plan, "1. Read\n2. Test\n3. Write\n"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(s.Log.String(), "+2. Test") {
+		t.Errorf("log lacks the plan diff:\n%s", s.Log.String())
+	}
+	if err := m.FollowUp("1", "Also update the docs"); err != nil {
+		t.Fatal(err)
+	}
+	f := <-m.followChs["1"]
+	if f.Text != "Also update the docs" || f.Plan != "1. Read\n2. Test\n3. Write\n" {
+		t.Errorf("follow-up = %+v, want it to carry the edited plan", f)
+	}
+
+	// The revised plan replaces the edit, so later follow-ups need not
+	// carry it.
+	m.Handle(Event{Key: "1", Type: EventFollowUp, Text: f.Text})
+	m.Handle(Event{Key: "1", Type: EventPlanDone, Text: "1. Read\n2. Test\n3. Write\n4. Docs\n"})
+	if err := m.FollowUp("1", "Looks good"); err != nil {
+		t.Fatal(err)
+	}
+	if f := <-m.followChs["1"]; f.Plan != "" {
+		t.Errorf("follow-up = %+v, want no plan after the agent revised it", f)
+	}
+}
+
+func TestFollowUpWithoutEdit(t *testing.T) {
+	m, _ := waitingManager(t, "1. Read\n")
+	if err := m.FollowUp("1", "Shorter, please"); err != nil {
+		t.Fatal(err)
+	}
+	if f := <-m.followChs["1"]; f.Plan != "" {
+		t.Errorf("follow-up = %+v, want no plan without an edit", f)
+	}
+}
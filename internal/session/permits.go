@@ -0,0 +1,60 @@
+package session
+
+import (
+	"context"
+
+	"github.com/tomfevang/go-work/internal/permission"
+)
+
+// permits answers claude's permission requests for one session. It asks the
+// user with EventPermission and waits for the Decision on ch, remembering
+// the tools the user always allows for the rest of the session.
+type permits struct {
+	ch     <-chan permission.Decision
+	send   func(EventType, string)
+	always map[string]bool // tool names
+}
+
+// newPermits returns the session's permits, or nil if permission prompts
+// are disabled and claude should be left to refuse on its own.
+func newPermits(agent AgentSettings, ch <-chan permission.Decision, send func(EventType, string)) *permits {
+	if !agent.PermissionPrompts || ch == nil {
+		return nil
+	}
+	return &permits{ch: ch, send: send, always: make(map[string]bool)}
+}
+
+// listen starts a bridge for one claude run in the worktree dir. Requests
+// still unanswered when ctx is done are denied.
+func (p *permits) listen(ctx context.Context, dir string) (*permission.Bridge, error) {
+	// The worktree's own git directory is writable inside sandboxes and
+	// not part of the work tree.
+	gitDir, err := gitOutput(dir, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return nil, err
+	}
+	return permission.Listen(gitDir, func(req permission.Request) permission.Decision {
+		return p.ask(ctx, req)
+	})
+}
+
+func (p *permits) ask(ctx context.Context, req permission.Request) permission.Decision {
+	if p.always[req.Tool] {
+		return permission.Allow
+	}
+	select {
+	case <-p.ch: // an answer that came too late for an earlier request
+	default:
+	}
+	p.send(EventPermission, req.Summary())
+	select {
+	case d := <-p.ch:
+		if d == permission.AlwaysAllow {
+			p.always[req.Tool] = true
+		}
+		return d
+	case <-ctx.Done():
+		p.send(EventPermission, "") // withdraw the prompt
+		return permission.Deny
+	}
+}
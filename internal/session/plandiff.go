@@ -0,0 +1,76 @@
+package session
+
+import "strings"
+
+// planDiff returns the line changes from old to edited, as a unified diff
+// body without hunk headers: unchanged lines start with a space, removed
+// ones with "-" and added ones with "+". Runs of unchanged lines are cut to
+// the context lines around changes.
+func planDiff(old, edited string) string {
+	a, b := planLines(old), planLines(edited)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]. Plans are short enough for the quadratic table.
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, " "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+
+	const context = 2
+	var out strings.Builder
+	skipped := false
+	for k, line := range lines {
+		near := false
+		for d := max(0, k-context); d <= min(len(lines)-1, k+context); d++ {
+			if lines[d][0] != ' ' {
+				near = true
+				break
+			}
+		}
+		if !near {
+			skipped = true
+			continue
+		}
+		if skipped && out.Len() > 0 {
+			out.WriteString("…\n")
+		}
+		skipped = false
+		out.WriteString(line + "\n")
+	}
+	return out.String()
+}
+
+// planLines splits a plan into lines, of which an empty plan has none.
+func planLines(plan string) []string {
+	plan = strings.TrimRight(plan, "\n")
+	if plan == "" {
+		return nil
+	}
+	return strings.Split(plan, "\n")
+}
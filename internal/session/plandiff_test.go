@@ -0,0 +1,57 @@
+package session
+
+import "testing"
+
+func TestPlanDiff(t *testing.T) {
+	tests := []struct {
+		name, old, edited, want string
+	}{
+		{
+			name:   "identical",
+			old:    "1. Read\n2. Write\n",
+			edited: "1. Read\n2. Write",
+			want:   "",
+		},
+		{
+			name:   "empty original",
+			old:    "",
+			edited: "1. Read\n2. Write\n",
+			want:   "+1. Read\n+2. Write\n",
+		},
+		{
+			name:   "insertion",
+			old:    "a\nb\nc\nd\ne\nf\ng\n",
+			edited: "a\nb\nc\nd\nnew\ne\nf\ng\n",
+			want:   " c\n d\n+new\n e\n f\n",
+		},
+		{
+			name:   "deletion",
+			old:    "a\nb\nc\nd\ne\n",
+			edited: "a\nc\nd\ne\n",
+			want:   " a\n-b\n c\n d\n",
+		},
+		{
+			name:   "replacement",
+			old:    "a\nold\nb\n",
+			edited: "a\nnew\nb\n",
+			want:   " a\n-old\n+new\n b\n",
+		},
+		{
+			name:   "distant changes",
+			old:    "x\n1\n2\n3\n4\n5\n6\ny\n",
+			edited: "X\n1\n2\n3\n4\n5\n6\nY\n",
+			want:   "-x\n+X\n 1\n 2\n…\n 5\n 6\n-y\n+Y\n",
+		},
+		{
+			name:   "everything removed",
+			old:    "a\nb\n",
+			edited: "",
+			want:   "-a\n-b\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := planDiff(tt.old, tt.edited); got != tt.want {
+			t.Errorf("%s: planDiff =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
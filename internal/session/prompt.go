@@ -0,0 +1,141 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Truncation limits for issue context, in runes. Comments are taken newest
+// first until commentBudget is spent, since late comments tend to carry the
+// clarifications.
+const (
+	bodyLimit     = 8000
+	commentLimit  = 2000
+	commentBudget = 12000
+)
+
+// Prompts wrapping the user's follow-up messages, which continue the
+// conversation of the plan or the implementation.
+const (
+	revisePrompt = "The user replied to your plan:\n\n%s\n\n" +
+		"Revise the plan accordingly and give it again in full. Do NOT write any code yet. " +
+		"End your response with the exact line: PLAN COMPLETE\n"
+	reviseEditedPrompt = "The user edited your plan. This is the plan now:\n\n%s\n\n" +
+		"They also replied to it:\n\n%s\n\n" +
+		"Revise the edited plan accordingly, keeping the user's edits, and give it again in full. " +
+		"Do NOT write any code yet. End your response with the exact line: PLAN COMPLETE\n"
+	followUpPrompt = "The user has a follow-up to your implementation:\n\n%s\n\n" +
+		"Make the changes, then stage and commit them. Do NOT create a pull request.\n"
+)
+
+// IssueMarkdown renders an issue with its metadata, comments and linked
+// items as markdown, for use in prompts and the issue preview.
+func IssueMarkdown(iss Issue) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s: %s\n\n", iss.Ref(), iss.Title)
+
+	var meta []string
+	if iss.Author.Login != "" {
+		meta = append(meta, "Author: @"+iss.Author.Login)
+	}
+	if len(iss.Labels) > 0 {
+		meta = append(meta, "Labels: "+strings.Join(iss.LabelNames(), ", "))
+	}
+	if iss.Milestone != nil && iss.Milestone.Title != "" {
+		meta = append(meta, "Milestone: "+iss.Milestone.Title)
+	}
+	if iss.URL != "" {
+		meta = append(meta, "URL: "+iss.URL)
+	}
+	for _, m := range meta {
+		b.WriteString("- " + m + "\n")
+	}
+	if len(meta) > 0 {
+		b.WriteString("\n")
+	}
+
+	body := strings.TrimSpace(iss.Body)
+	if body == "" {
+		body = "_No description provided._"
+	}
+	b.WriteString(truncateRunes(body, bodyLimit) + "\n")
+
+	if n := len(iss.Comments); n > 0 {
+		// Walk backwards to keep the newest comments within budget.
+		budget, first := commentBudget, n
+		for first > 0 {
+			c := truncateRunes(strings.TrimSpace(iss.Comments[first-1].Body), commentLimit)
+			if len([]rune(c)) > budget && first < n {
+				break
+			}
+			budget -= len([]rune(c))
+			first--
+		}
+		fmt.Fprintf(&b, "\n## Comments\n\n")
+		if first > 0 {
+			fmt.Fprintf(&b, "_%d older comment(s) omitted._\n\n", first)
+		}
+		for _, c := range iss.Comments[first:] {
+			fmt.Fprintf(&b, "**@%s** (%s):\n\n%s\n\n", c.Author.Login,
+				c.CreatedAt.Format("2006-01-02"),
+				truncateRunes(strings.TrimSpace(c.Body), commentLimit))
+		}
+	}
+
+	if len(iss.Linked) > 0 {
+		b.WriteString("\n## Linked issues and pull requests\n\n")
+		for _, l := range iss.Linked {
+			kind := "Issue"
+			if l.IsPR {
+				kind = "PR"
+			}
+			fmt.Fprintf(&b, "- %s #%d (%s): %s\n", kind, l.Number, l.State, l.Title)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// truncateRunes cuts s to at most max runes, marking the cut.
+func truncateRunes(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[:max]) + "\n\n[… truncated]"
+}
+
+// PRText returns the title and body of a pull request resolving issues.
+// Forge issues are closed by the PR; local tasks are only listed.
+func PRText(issues []Issue) (title, body string) {
+	var b strings.Builder
+	var refs []string
+	for _, iss := range issues {
+		if !iss.IsLocal() {
+			refs = append(refs, iss.Ref())
+			fmt.Fprintf(&b, "Closes %s\n", iss.Ref())
+		}
+	}
+
+	switch {
+	case len(issues) == 1 && len(refs) == 1:
+		title = fmt.Sprintf("Fix %s: %s", refs[0], issues[0].Title)
+	case len(issues) == 1:
+		title = issues[0].Title
+	case len(refs) > 0:
+		title = "Fix " + strings.Join(refs, ", ")
+	default:
+		title = fmt.Sprintf("%s and %d more", issues[0].Title, len(issues)-1)
+	}
+
+	if len(issues) > 1 {
+		b.WriteString("\nResolves:\n\n")
+		for _, iss := range issues {
+			fmt.Fprintf(&b, "- %s: %s\n", iss.Ref(), iss.Title)
+		}
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("Automatically implemented via go-work.")
+	return title, b.String()
+}
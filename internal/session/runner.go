@@ -2,25 +2,40 @@ package session
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	"os/exec"
-	"path/filepath"
 	"strings"
+	"time"
 
-	gh "github.com/tomfevang/go-work/internal/github"
+	"github.com/tomfevang/go-work/internal/forge"
+	"github.com/tomfevang/go-work/internal/permission"
+	"github.com/tomfevang/go-work/internal/policy"
+	"github.com/tomfevang/go-work/internal/secrets"
+	"github.com/tomfevang/go-work/internal/telemetry"
 )
 
 // claudeMsg is the subset of fields we care about from claude's stream-json output.
 type claudeMsg struct {
-	Type    string `json:"type"`
-	Subtype string `json:"subtype"`
+	Type      string `json:"type"`
+	Subtype   string `json:"subtype"`
+	SessionID string `json:"session_id"`
 	// assistant message
 	Message *claudeMessageBlock `json:"message,omitempty"`
 	// result message
-	Result string `json:"result,omitempty"`
-	Error  string `json:"error,omitempty"`
+	Result       string       `json:"result,omitempty"`
+	Error        string       `json:"error,omitempty"`
+	TotalCostUSD float64      `json:"total_cost_usd,omitempty"`
+	Usage        *claudeUsage `json:"usage,omitempty"`
+}
+
+type claudeUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
 }
 
 type claudeMessageBlock struct {
@@ -28,111 +43,389 @@ type claudeMessageBlock struct {
 }
 
 type claudeContent struct {
-	Type string `json:"type"`
-	Text string `json:"text,omitempty"`
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`          // tool_use
+	Name      string          `json:"name,omitempty"`        // tool_use
+	Input     json.RawMessage `json:"input,omitempty"`       // tool_use
+	ToolUseID string          `json:"tool_use_id,omitempty"` // tool_result
+	IsError   bool            `json:"is_error,omitempty"`    // tool_result
+}
+
+// toolInput holds the path arguments of claude's file-editing tools.
+type toolInput struct {
+	FilePath     string `json:"file_path"`
+	NotebookPath string `json:"notebook_path"`
+}
+
+// editTools are the tools whose use modifies the file in their input.
+var editTools = map[string]bool{"Edit": true, "MultiEdit": true, "Write": true, "NotebookEdit": true}
+
+// Env is what a session needs from its surroundings.
+type Env struct {
+	RepoRoot string
+	Forge    forge.Forge
+	// Agent, Worktrees and PRs are the agent, worktrees and pull_requests
+	// config sections.
+	Agent     AgentSettings
+	Worktrees WorktreeSettings
+	PRs       PRTemplates
+	// LocalPRs opens pull requests for issues from local sources. Without
+	// it, those sessions finish once the work is committed on its branch.
+	LocalPRs bool
+	// Stack branches sessions off the branches of the issues they depend
+	// on, so their PRs stack on the prerequisites' PRs.
+	Stack bool
+	// BatchPRs holds back pull requests for all sessions, which then finish
+	// once the work is committed so they can be combined into batch PRs.
+	BatchPRs bool
+	// Secrets and Policy configure the checks before pushing.
+	Secrets secrets.Settings
+	Policy  policy.Settings
 }
 
 // Run drives a full session for one issue: creates a worktree, runs the
 // planning phase, waits for approval via approveCh, then runs the
 // implementation phase, and finally creates a PR.
 //
-// All progress is sent to eventCh. The caller must close approveCh or send
-// false to cancel after WaitingApproval.
-func Run(issue Issue, repoRoot string, eventCh chan<- Event, approveCh <-chan bool) {
-	num := issue.Number
+// The worktree branches off bases[0] with any further bases merged in, or off
+// the repo's HEAD when bases is empty. The PR targets bases[0], if set. An
+// approving Decision may move the worktree onto other bases.
+//
+// All progress is sent to eventCh. Cancelling ctx stops the session at any
+// point; closing approveCh or sending a rejecting Decision also cancels it
+// after WaitingApproval. With permission prompts enabled, the answers to
+// EventPermission are awaited on permitCh.
+//
+// Follow-up messages read from followCh continue claude's conversation:
+// until the plan is approved they revise it, and during implementation
+// they are delivered once the agent finishes, before anything is pushed.
+func Run(ctx context.Context, issue Issue, bases []string, env Env, eventCh chan<- Event, approveCh <-chan Decision, permitCh <-chan permission.Decision, followCh <-chan FollowUp) {
+	key := issue.Key()
+	repoRoot := env.RepoRoot
 	send := func(t EventType, text string) {
-		eventCh <- Event{IssueNumber: num, Type: t, Text: text}
+		eventCh <- Event{Key: key, Type: t, Text: text}
 	}
+	ctx, span := telemetry.StartSpan(ctx, "session", map[string]any{
+		"issue.key": key, "issue.ref": issue.Ref(), "issue.title": issue.Title,
+	})
+	var runErr error
+	defer func() { span.End(runErr) }()
 	fail := func(err error) {
+		runErr = err
 		send(EventError, err.Error())
 	}
+	perms := newPermits(env.Agent, permitCh, send)
 
 	// --- worktree ---
-	worktreeDir := filepath.Join(repoRoot, ".worktrees", fmt.Sprintf("%d", num))
-	branch := fmt.Sprintf("issue-%d", num)
+	worktreeDir := env.Worktrees.Path(repoRoot, key)
+	branch := env.Worktrees.BranchName(issue)
 
 	// Remove stale worktree if it exists.
 	_ = exec.Command("git", "-C", repoRoot, "worktree", "remove", "--force", worktreeDir).Run()
 	_ = exec.Command("git", "-C", repoRoot, "branch", "-D", branch).Run()
 
-	addCmd := exec.Command("git", "-C", repoRoot, "worktree", "add", worktreeDir, "-b", branch)
+	addArgs := []string{"-C", repoRoot, "worktree", "add", worktreeDir, "-b", branch}
+	prBase := ""
+	if len(bases) > 0 {
+		prBase = bases[0]
+		addArgs = append(addArgs, prBase)
+	}
+	addCmd := exec.Command("git", addArgs...)
 	if out, err := addCmd.CombinedOutput(); err != nil {
+		telemetry.CommandErrors.Inc("git")
 		fail(fmt.Errorf("create worktree: %w\n%s", err, out))
 		return
 	}
+	if err := mergeBases(worktreeDir, bases[min(1, len(bases)):]); err != nil {
+		fail(err)
+		return
+	}
+
+	// Issues picked from the list lack comments and links; fetch them so the
+	// agent sees clarifications made after the issue was opened.
+	if !issue.Detailed {
+		if full, err := forge.Details(env.Forge, issue); err == nil {
+			issue = full
+		} else {
+			send(EventOutput, fmt.Sprintf("[could not fetch issue comments: %v]\n", err))
+		}
+	}
+	issueText := IssueMarkdown(issue)
 
 	// --- phase 1: planning ---
-	planPrompt := fmt.Sprintf(
-		"You are working on the following GitHub issue:\n\nTitle: %s\n\n%s\n\n"+
-			"Create a concise implementation plan. List the files you will change, "+
-			"your approach, and any edge cases. Do NOT write any code yet. "+
-			"End your response with the exact line: PLAN COMPLETE",
-		issue.Title, issue.Body,
-	)
+	planPrompt, err := render(env.Agent.PlanPrompt, promptData{Ref: issue.Ref(), Issue: issueText})
+	if err != nil {
+		fail(fmt.Errorf("plan prompt: %w", err))
+		return
+	}
 
 	send(EventOutput, "=== Planning phase ===\n")
-	planText, err := runClaude(worktreeDir, planPrompt, nil, send)
+	planCtx, endPlan := phase(ctx, "plan")
+	planText, planSession, err := runClaude(planCtx, env.Agent, env.Agent.Plan, worktreeDir, planPrompt, "", perms, send)
+	endPlan(err)
 	if err != nil {
 		fail(fmt.Errorf("planning: %w", err))
 		return
 	}
-	send(EventPlanDone, planText)
 
-	// --- wait for approval ---
-	approved, ok := <-approveCh
-	if !ok || !approved {
-		send(EventError, "plan rejected or session cancelled")
+	// --- wait for approval, revising the plan on follow-ups ---
+	revise := func(f FollowUp) error {
+		send(EventFollowUp, f.Text)
+		send(EventOutput, "\n=== Revising the plan ===\n")
+		prompt := fmt.Sprintf(revisePrompt, f.Text)
+		if f.Plan != "" {
+			prompt = fmt.Sprintf(reviseEditedPrompt, f.Plan, f.Text)
+		}
+		reviseCtx, endRevise := phase(ctx, "plan")
+		text, id, err := runClaude(reviseCtx, env.Agent, env.Agent.Plan, worktreeDir, prompt, planSession, perms, send)
+		endRevise(err)
+		if err != nil {
+			return fmt.Errorf("revising the plan: %w", err)
+		}
+		planText, planSession = text, id
+		return nil
+	}
+	var decision Decision
+	ok := false
+	for waiting := true; waiting; {
+		select {
+		case f := <-followCh: // sent while planning
+			if err := revise(f); err != nil {
+				fail(err)
+				return
+			}
+			continue
+		default:
+		}
+		send(EventPlannedFiles, strings.Join(plannedFiles(worktreeDir, planText), "\n"))
+		send(EventPlanDone, planText)
+
+		_, endApproval := phase(ctx, "approval")
+		select {
+		case decision, ok = <-approveCh:
+			waiting = false
+		case f := <-followCh:
+			endApproval(nil)
+			if err := revise(f); err != nil {
+				fail(err)
+				return
+			}
+			continue
+		case <-ctx.Done():
+			waiting = false
+		}
+		endApproval(nil)
+	}
+	if !ok || !decision.Approve {
+		runErr = errors.New("plan rejected or session cancelled")
+		send(EventError, runErr.Error())
 		_ = exec.Command("git", "-C", repoRoot, "worktree", "remove", "--force", worktreeDir).Run()
 		return
 	}
+	if len(decision.Bases) > 0 {
+		send(EventOutput, fmt.Sprintf("\nRebasing onto %s.\n", strings.Join(decision.Bases, ", ")))
+		if err := rebase(worktreeDir, decision.Bases[0]); err != nil {
+			fail(err)
+			return
+		}
+		if err := mergeBases(worktreeDir, decision.Bases[1:]); err != nil {
+			fail(err)
+			return
+		}
+		prBase = decision.Bases[0]
+	}
+	if decision.Plan != "" {
+		planText = decision.Plan
+	}
+	issues := append([]Issue{issue}, decision.Also...)
 
 	// --- phase 2: implementation ---
-	implPrompt := fmt.Sprintf(
-		"Implement the following approved plan for GitHub issue #%d.\n\n"+
-			"ISSUE TITLE: %s\n\nISSUE BODY:\n%s\n\nAPPROVED PLAN:\n%s\n\n"+
-			"Write the code. After implementation, run the project's tests if a "+
-			"test command is available. Then stage and commit all your changes with "+
-			"a descriptive commit message. Do NOT create a pull request.",
-		num, issue.Title, issue.Body, planText,
-	)
-
-	allowedTools := []string{"Edit", "Write", "Bash", "Glob", "Grep", "Read"}
+	var also strings.Builder
+	for _, iss := range decision.Also {
+		if !iss.Detailed {
+			if full, err := forge.Details(env.Forge, iss); err == nil {
+				iss = full
+			}
+		}
+		also.WriteString(IssueMarkdown(iss) + "\n\n")
+	}
+	implPrompt, err := render(env.Agent.ImplementPrompt, promptData{
+		Ref:   issue.Ref(),
+		Issue: issueText,
+		Plan:  planText,
+		Also:  strings.TrimSpace(also.String()),
+	})
+	if err != nil {
+		fail(fmt.Errorf("implementation prompt: %w", err))
+		return
+	}
+
+	startCommit, _ := gitOutput(worktreeDir, "rev-parse", "HEAD")
 	send(EventOutput, "\n=== Implementation phase ===\n")
-	_, err = runClaude(worktreeDir, implPrompt, allowedTools, send)
+	implCtx, endImpl := phase(ctx, "implement")
+	_, implSession, err := runClaude(implCtx, env.Agent, env.Agent.Implement, worktreeDir, implPrompt, "", perms, send)
+	endImpl(err)
 	if err != nil {
 		fail(fmt.Errorf("implementation: %w", err))
 		return
 	}
+	for delivered := true; delivered; {
+		select {
+		case f := <-followCh:
+			send(EventFollowUp, f.Text)
+			send(EventOutput, "\n=== Follow-up ===\n")
+			followCtx, endFollow := phase(ctx, "implement")
+			_, implSession, err = runClaude(followCtx, env.Agent, env.Agent.Implement, worktreeDir, fmt.Sprintf(followUpPrompt, f.Text), implSession, perms, send)
+			endFollow(err)
+			if err != nil {
+				fail(fmt.Errorf("follow-up: %w", err))
+				return
+			}
+		default:
+			delivered = false
+		}
+	}
+	send(EventFilesChanged, strings.Join(changedFiles(worktreeDir, startCommit), "\n"))
 	send(EventImplDone, "")
 
+	if env.BatchPRs || allLocal(issues) && !env.LocalPRs {
+		send(EventFinished, branch)
+		return
+	}
+
 	// --- create PR ---
+	if err := checkPush(ctx, worktreeDir, startCommit, env, send, approveCh); err != nil {
+		fail(err)
+		return
+	}
 	send(EventOutput, "\n=== Creating PR ===\n")
-	prURL, err := gh.CreatePR(worktreeDir, issue.Number, issue.Title)
+	title, body, err := env.PRs.prText(issues)
+	if err != nil {
+		fail(err)
+		return
+	}
+	_, endPR := phase(ctx, "pull_request")
+	prURL, err := env.Forge.CreatePR(worktreeDir, forge.PullRequest{Title: title, Body: body, Base: prBase})
+	endPR(err)
 	if err != nil {
 		fail(fmt.Errorf("create PR: %w", err))
 		return
 	}
+	span.SetAttr("pr.url", prURL)
 	send(EventPRDone, prURL)
 }
 
-// runClaude spawns claude with --output-format stream-json and streams its
-// output to eventCh. It returns the concatenated assistant text.
-func runClaude(cwd, prompt string, allowedTools []string, send func(EventType, string)) (string, error) {
+// checkPush runs the checks on the commits made since base before they are
+// pushed: the secret scan and the repo's policy. If they find anything, it
+// reports it and waits on approveCh for the user to push anyway; a rejecting
+// Decision, or cancelling ctx, blocks the push for good. Policy violations
+// fail the session outright if the policy says so.
+func checkPush(ctx context.Context, dir, base string, env Env, send func(EventType, string), approveCh <-chan Decision) error {
+	var report strings.Builder
+	if env.Secrets.Scan {
+		findings, err := secrets.ScanDiff(dir, base, env.Secrets)
+		if err != nil {
+			return fmt.Errorf("secret scan: %w", err)
+		}
+		if len(findings) > 0 {
+			report.WriteString("Possible secrets:\n\n" + secrets.Report(findings))
+		}
+	}
+	violations, err := policy.Check(dir, base, env.Policy)
+	if err != nil {
+		return fmt.Errorf("policy check: %w", err)
+	}
+	if len(violations) > 0 {
+		list := "- " + strings.Join(violations, "\n- ")
+		if env.Policy.Action == policy.ActionBlock {
+			return fmt.Errorf("policy violations:\n%s", list)
+		}
+		if report.Len() > 0 {
+			report.WriteString("\n")
+		}
+		report.WriteString("Policy violations:\n\n" + list + "\n")
+	}
+	if report.Len() == 0 {
+		send(EventOutput, "\nPre-push checks passed.\n")
+		return nil
+	}
+	send(EventBlocked, report.String())
+
+	var decision Decision
+	ok := false
+	select {
+	case decision, ok = <-approveCh:
+	case <-ctx.Done():
+	}
+	if !ok || !decision.Approve {
+		return errors.New("push blocked by the pre-push checks")
+	}
+	send(EventOutput, "\n⚠ Pushing despite the pre-push check findings, as overridden by the user.\n")
+	return nil
+}
+
+// phase starts a span for one phase of a session under the span in ctx. The
+// returned function ends it and records the phase's duration.
+func phase(ctx context.Context, name string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := telemetry.StartSpan(ctx, name, nil)
+	return ctx, func(err error) {
+		telemetry.PhaseDuration.Observe(time.Since(start).Seconds(), name)
+		span.End(err)
+	}
+}
+
+// runClaude spawns claude, sandboxed if configured, with --output-format
+// stream-json and streams its output to eventCh. It returns the concatenated
+// assistant text. Tool calls are traced as children of the span in ctx, and
+// token use and cost are recorded. With perms set, claude asks the user
+// before using tools the profile does not allow. A non-empty resume
+// continues the conversation with that session ID; the ID of the run's
+// conversation is returned for the next.
+func runClaude(ctx context.Context, agent AgentSettings, profile Profile, cwd, prompt, resume string, perms *permits, send func(EventType, string)) (text, sessionID string, err error) {
+	defer func() {
+		if err != nil {
+			telemetry.CommandErrors.Inc("claude")
+		}
+	}()
+	tools := make(map[string]*telemetry.Span) // open tool spans by tool_use id
+	defer func() {
+		for _, s := range tools {
+			s.End(fmt.Errorf("no tool result"))
+		}
+	}()
+
 	args := []string{"-p", prompt, "--output-format", "stream-json", "--verbose"}
-	if len(allowedTools) > 0 {
-		args = append(args, "--allowedTools", strings.Join(allowedTools, ","))
+	if resume != "" {
+		args = append(args, "--resume", resume)
+	}
+	if perms != nil {
+		askCtx, cancel := context.WithCancel(ctx)
+		bridge, err := perms.listen(askCtx, cwd)
+		if err != nil {
+			cancel()
+			return "", "", fmt.Errorf("permission prompts: %w", err)
+		}
+		defer bridge.Close()
+		defer cancel() // deny what claude asked before exiting, so Close returns
+		args = append(args, "--permission-prompt-tool", permission.Tool)
+		args = append(args, profile.args(bridge.ConfigPath())...)
+	} else {
+		args = append(args, profile.args()...)
 	}
 
-	cmd := exec.Command("claude", args...)
-	cmd.Dir = cwd
-	cmd.Env = os.Environ()
+	cmd, err := agent.Sandbox.command(ctx, cwd, agent.Command, args...)
+	if err != nil {
+		return "", "", err
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("start claude: %w", err)
+		return "", "", fmt.Errorf("start claude: %w", err)
 	}
 
 	var fullText strings.Builder
@@ -154,26 +447,81 @@ func runClaude(cwd, prompt string, allowedTools []string, send func(EventType, s
 		case "assistant":
 			if msg.Message != nil {
 				for _, block := range msg.Message.Content {
-					if block.Type == "text" && block.Text != "" {
+					switch {
+					case block.Type == "text" && block.Text != "":
 						fullText.WriteString(block.Text)
 						send(EventOutput, block.Text)
+					case block.Type == "tool_use":
+						telemetry.ToolCalls.Inc(block.Name)
+						send(EventTool, toolSummary(block.Name, block.Input))
+						_, tools[block.ID] = telemetry.StartSpan(ctx, "tool "+block.Name, map[string]any{"tool.name": block.Name})
+						if editTools[block.Name] {
+							if path := editedPath(cwd, block.Input); path != "" {
+								send(EventFilesChanged, path)
+							}
+						}
+					}
+				}
+			}
+		case "user":
+			if msg.Message != nil {
+				for _, block := range msg.Message.Content {
+					if s, ok := tools[block.ToolUseID]; ok && block.Type == "tool_result" {
+						var toolErr error
+						if block.IsError {
+							toolErr = fmt.Errorf("tool error")
+						}
+						s.End(toolErr)
+						delete(tools, block.ToolUseID)
 					}
 				}
 			}
 		case "result":
+			recordUsage(ctx, msg)
 			if msg.Error != "" {
-				return fullText.String(), fmt.Errorf("claude error: %s", msg.Error)
+				return fullText.String(), sessionID, fmt.Errorf("claude error: %s", msg.Error)
 			}
 			// msg.Result is the same text already streamed via assistant blocks; ignore it.
 		case "system":
 			if msg.Subtype == "init" {
+				sessionID = msg.SessionID
 				send(EventOutput, "[session started]\n")
 			}
 		}
 	}
 
 	if err := cmd.Wait(); err != nil {
-		return fullText.String(), fmt.Errorf("claude exited: %w", err)
+		return fullText.String(), sessionID, fmt.Errorf("claude exited: %w", err)
+	}
+	return fullText.String(), sessionID, nil
+}
+
+// toolSummary describes a tool call on one line of at most 200 characters.
+func toolSummary(name string, input json.RawMessage) string {
+	s := strings.Join(strings.Fields(permission.Request{Tool: name, Input: input}.Summary()), " ")
+	if r := []rune(s); len(r) > 200 {
+		s = string(r[:199]) + "…"
+	}
+	return s
+}
+
+// recordUsage adds the token use and cost of a finished claude run to the
+// metrics and the current span.
+func recordUsage(ctx context.Context, msg claudeMsg) {
+	span := telemetry.SpanFromContext(ctx)
+	if msg.TotalCostUSD > 0 {
+		telemetry.CostUSD.Add(msg.TotalCostUSD)
+		span.SetAttr("claude.cost_usd", msg.TotalCostUSD)
+	}
+	if u := msg.Usage; u != nil {
+		for typ, n := range map[string]int{
+			"input":        u.InputTokens,
+			"output":       u.OutputTokens,
+			"cache_create": u.CacheCreationInputTokens,
+			"cache_read":   u.CacheReadInputTokens,
+		} {
+			telemetry.Tokens.Add(float64(n), typ)
+			span.SetAttr("claude.tokens."+typ, n)
+		}
 	}
-	return fullText.String(), nil
 }
@@ -0,0 +1,220 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Sandbox runtimes.
+const (
+	SandboxNone   = "none"
+	SandboxBwrap  = "bwrap"
+	SandboxPodman = "podman"
+	SandboxDocker = "docker"
+)
+
+// SandboxSettings confine the agent's processes. In a sandbox the agent can
+// read the filesystem but only write to the session's worktree, the parts
+// of the repo's git directory committing needs and the Writable paths.
+type SandboxSettings struct {
+	// Runtime is none, bwrap (bubblewrap, Linux only) or a rootless
+	// container runtime, podman or docker.
+	Runtime string `yaml:"runtime"`
+	// Image is the container image the agent runs in; it must provide
+	// agent.command. Only used by container runtimes.
+	Image string `yaml:"image"`
+	// Network leaves the network reachable. The agent itself needs its API,
+	// so disable it only with an agent that reaches the API some other way.
+	Network bool `yaml:"network"`
+	// Writable lists further files and directories the agent may write,
+	// such as its own state and build caches. ~/ is expanded; missing paths
+	// are skipped.
+	Writable []string `yaml:"writable"`
+	// Env names environment variables passed into containers, such as API
+	// keys. bwrap sandboxes inherit the whole environment.
+	Env []string `yaml:"env"`
+}
+
+// DefaultSandbox returns the built-in sandbox settings: no sandbox, and
+// claude's state writable once one is enabled.
+func DefaultSandbox() SandboxSettings {
+	return SandboxSettings{
+		Runtime:  SandboxNone,
+		Network:  true,
+		Writable: []string{"~/.claude", "~/.claude.json"},
+		Env:      []string{"ANTHROPIC_API_KEY"},
+	}
+}
+
+// Validate reports the first invalid setting, naming its key.
+func (s SandboxSettings) Validate() error {
+	switch s.Runtime {
+	case SandboxNone, SandboxBwrap:
+	case SandboxPodman, SandboxDocker:
+		if s.Image == "" {
+			return fmt.Errorf("image: required with runtime %s", s.Runtime)
+		}
+	default:
+		return fmt.Errorf("runtime: %q is not none, bwrap, podman or docker", s.Runtime)
+	}
+	for i, p := range s.Writable {
+		if p == "" {
+			return fmt.Errorf("writable[%d]: must not be empty", i)
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether agents run in a sandbox.
+func (s SandboxSettings) Enabled() bool { return s.Runtime != SandboxNone && s.Runtime != "" }
+
+// command returns the command running name with args in dir, inside the
+// sandbox if one is configured.
+func (s SandboxSettings) command(ctx context.Context, dir, name string, args ...string) (*exec.Cmd, error) {
+	if !s.Enabled() {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Dir = dir
+		cmd.Env = os.Environ()
+		return cmd, nil
+	}
+
+	mnt, err := s.mounts(dir)
+	if err != nil {
+		return nil, err
+	}
+	var cmd *exec.Cmd
+	if s.Runtime == SandboxBwrap {
+		cmd = exec.CommandContext(ctx, "bwrap", s.bwrapArgs(dir, mnt, name, args)...)
+	} else {
+		cmd = exec.CommandContext(ctx, s.Runtime, s.containerArgs(dir, mnt, name, args)...)
+		// Killing the client would leave the container running; the
+		// runtime forwards a termination signal to it instead.
+		cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+		cmd.WaitDelay = 10 * time.Second
+	}
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	return cmd, nil
+}
+
+// mounts are the paths a sandbox shares with the agent working in a
+// worktree.
+type mounts struct {
+	gitDir   string   // the repo's common git directory, readable
+	writable []string // existing paths the agent may write
+	// readOnly are files within writable paths that must stay read-only:
+	// those telling git where the repository is, which go-work runs git
+	// in outside the sandbox.
+	readOnly []string
+	// self is the go-work executable, which the agent runs as the MCP
+	// server of permission prompts. Containers need it mounted.
+	self string
+}
+
+// mounts returns what the agent working in dir may read and write: it
+// writes dir, what committing needs of the git directory shared by the
+// repo's worktrees (objects, refs, reflogs and the worktree's own state)
+// and the configured paths. The rest of the git directory, including its
+// config and hooks, stays read-only.
+func (s SandboxSettings) mounts(dir string) (mounts, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--git-common-dir", "--absolute-git-dir").Output()
+	if err != nil {
+		return mounts{}, fmt.Errorf("sandbox: find git directory: %w", err)
+	}
+	common, own, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	if !filepath.IsAbs(common) {
+		common = filepath.Join(dir, common)
+	}
+	m := mounts{gitDir: filepath.Clean(common), writable: []string{dir}}
+	for _, sub := range []string{"objects", "refs", "logs"} {
+		m.writable = append(m.writable, filepath.Join(m.gitDir, sub))
+	}
+	if own = filepath.Clean(own); own != m.gitDir { // a linked worktree
+		m.writable = append(m.writable, own)
+		m.readOnly = append(m.readOnly, filepath.Join(own, "commondir"), filepath.Join(own, "gitdir"), filepath.Join(own, "config.worktree"),
+			filepath.Join(dir, ".git"))
+	}
+
+	home, _ := os.UserHomeDir()
+	for _, p := range s.Writable {
+		if rest, ok := strings.CutPrefix(p, "~/"); ok {
+			if home == "" {
+				continue
+			}
+			p = filepath.Join(home, rest)
+		}
+		if !slices.Contains(m.writable, p) {
+			m.writable = append(m.writable, p)
+		}
+	}
+	if exe, err := os.Executable(); err == nil {
+		m.self = exe
+	}
+	m.writable = slices.DeleteFunc(m.writable, missing)
+	m.readOnly = slices.DeleteFunc(m.readOnly, missing)
+	return m, nil
+}
+
+func missing(path string) bool {
+	_, err := os.Stat(path)
+	return err != nil
+}
+
+func (s SandboxSettings) bwrapArgs(dir string, mnt mounts, name string, args []string) []string {
+	out := []string{
+		"--die-with-parent", "--new-session", "--unshare-all",
+		"--ro-bind", "/", "/",
+		"--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp",
+	}
+	if s.Network {
+		out = append(out, "--share-net")
+	}
+	for _, p := range mnt.writable {
+		out = append(out, "--bind", p, p)
+	}
+	for _, p := range mnt.readOnly {
+		out = append(out, "--ro-bind", p, p)
+	}
+	out = append(out, "--chdir", dir, "--", name)
+	return append(out, args...)
+}
+
+func (s SandboxSettings) containerArgs(dir string, mnt mounts, name string, args []string) []string {
+	out := []string{"run", "--rm", "-i", "--init", "--workdir", dir}
+	if s.Runtime == SandboxPodman {
+		out = append(out, "--userns=keep-id")
+	} else {
+		out = append(out, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	if !s.Network {
+		out = append(out, "--network", "none")
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		out = append(out, "--env", "HOME="+home)
+	}
+	for _, name := range s.Env {
+		if _, ok := os.LookupEnv(name); ok {
+			out = append(out, "--env", name) // the value is taken from our environment
+		}
+	}
+	// The runtime mounts parents before the paths within them.
+	out = append(out, "--volume", mnt.gitDir+":"+mnt.gitDir+":ro")
+	for _, p := range mnt.writable {
+		out = append(out, "--volume", p+":"+p)
+	}
+	for _, p := range mnt.readOnly {
+		out = append(out, "--volume", p+":"+p+":ro")
+	}
+	if mnt.self != "" {
+		out = append(out, "--volume", mnt.self+":"+mnt.self+":ro")
+	}
+	out = append(out, s.Image, name)
+	return append(out, args...)
+}
@@ -0,0 +1,105 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSandboxMounts(t *testing.T) {
+	root := gitRepo(t)
+	dir := filepath.Join(filepath.Dir(root), "wt")
+	git(t, root, "worktree", "add", "-q", "-b", "go-work/1", dir)
+	common := filepath.Join(root, ".git")
+	own := filepath.Join(common, "worktrees", "wt")
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Mkdir(filepath.Join(home, ".claude"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	s := SandboxSettings{Runtime: SandboxBwrap, Writable: []string{"~/.claude", "~/missing", dir}}
+	mnt, err := s.mounts(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mnt.gitDir != common {
+		t.Errorf("gitDir = %s, want %s", mnt.gitDir, common)
+	}
+	wantWritable := []string{
+		dir,
+		filepath.Join(common, "objects"), filepath.Join(common, "refs"), filepath.Join(common, "logs"),
+		own,
+		filepath.Join(home, ".claude"),
+	}
+	if !slices.Equal(mnt.writable, wantWritable) {
+		t.Errorf("writable = %q, want %q", mnt.writable, wantWritable)
+	}
+	wantReadOnly := []string{filepath.Join(own, "commondir"), filepath.Join(own, "gitdir"), filepath.Join(dir, ".git")}
+	if !slices.Equal(mnt.readOnly, wantReadOnly) {
+		t.Errorf("readOnly = %q, want %q", mnt.readOnly, wantReadOnly)
+	}
+
+	// Nothing writable lets the agent change how git runs outside the
+	// sandbox.
+	for _, p := range []string{common, filepath.Join(common, "config"), filepath.Join(common, "hooks"), filepath.Join(common, "hooks", "pre-commit")} {
+		for _, w := range mnt.writable {
+			if p == w || strings.HasPrefix(p, w+string(filepath.Separator)) {
+				t.Errorf("%s is writable through %s", p, w)
+			}
+		}
+	}
+
+	// Read-only binds come after the writable ones they lie within, or
+	// those would cover them.
+	args := s.bwrapArgs(dir, mnt, "claude", nil)
+	lastWritable := -1
+	for i := range args {
+		if args[i] == "--bind" {
+			lastWritable = i
+		}
+	}
+	for _, p := range wantReadOnly {
+		i := slices.Index(args, p)
+		if i < 1 || args[i-1] != "--ro-bind" || args[i+1] != p {
+			t.Errorf("bwrap args lack --ro-bind %s: %q", p, args)
+		} else if i < lastWritable {
+			t.Errorf("bwrap binds %s read-only before the writable paths: %q", p, args)
+		}
+	}
+
+	s.Runtime, s.Image = SandboxPodman, "agent:latest"
+	args = s.containerArgs(dir, mnt, "claude", nil)
+	volume := func(spec string) int {
+		i := slices.Index(args, spec)
+		if i < 1 || args[i-1] != "--volume" {
+			t.Errorf("container args lack --volume %s: %q", spec, args)
+		}
+		return i
+	}
+	if volume(common+":"+common+":ro") > volume(dir+":"+dir) {
+		t.Errorf("the git directory is mounted after the worktree: %q", args)
+	}
+	for _, p := range wantReadOnly {
+		if volume(p+":"+p+":ro") < volume(own+":"+own) {
+			t.Errorf("%s is mounted read-only before the writable paths: %q", p, args)
+		}
+	}
+}
+
+func TestSandboxMountsMainWorktree(t *testing.T) {
+	root := gitRepo(t)
+	mnt, err := SandboxSettings{Runtime: SandboxBwrap}.mounts(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The git directory itself stays writable where committing needs it.
+	common := filepath.Join(root, ".git")
+	want := []string{root, filepath.Join(common, "objects"), filepath.Join(common, "refs"), filepath.Join(common, "logs")}
+	if !slices.Equal(mnt.writable, want) || len(mnt.readOnly) != 0 {
+		t.Errorf("writable = %q, readOnly = %q, want %q and nothing read-only", mnt.writable, mnt.readOnly, want)
+	}
+}
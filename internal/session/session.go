@@ -1,9 +1,13 @@
 package session
 
-import gh "github.com/tomfevang/go-work/internal/github"
+import (
+	"fmt"
 
-// Issue is an alias for github.Issue for convenience within this package.
-type Issue = gh.Issue
+	"github.com/tomfevang/go-work/internal/forge"
+)
+
+// Issue is an alias for forge.Issue for convenience within this package.
+type Issue = forge.Issue
 
 // State represents the lifecycle stage of a session.
 type State int
@@ -16,6 +20,9 @@ const (
 	CreatingPR                   // running gh pr create
 	Done                         // PR opened successfully
 	Failed                       // terminal error
+	Queued                       // plan approved, waiting for serialized prerequisites
+	Merged                       // issue handed to another session
+	Blocked                      // push held back by the pre-push checks, awaiting user decision
 )
 
 func (s State) String() string {
@@ -34,37 +41,159 @@ func (s State) String() string {
 		return "Done"
 	case Failed:
 		return "Failed"
+	case Queued:
+		return "Queued"
+	case Merged:
+		return "Merged"
+	case Blocked:
+		return "Push blocked"
 	default:
 		return "Unknown"
 	}
 }
 
+// stateNames identify states in configuration and machine-readable output.
+var stateNames = map[State]string{
+	Pending:         "pending",
+	Planning:        "planning",
+	WaitingApproval: "waiting_approval",
+	Implementing:    "implementing",
+	CreatingPR:      "creating_pr",
+	Done:            "done",
+	Failed:          "failed",
+	Queued:          "queued",
+	Merged:          "merged",
+	Blocked:         "blocked",
+}
+
+// Name returns the state's identifier, e.g. "waiting_approval".
+func (s State) Name() string { return stateNames[s] }
+
+// ParseState returns the state identified by name.
+func ParseState(name string) (State, error) {
+	for st, n := range stateNames {
+		if n == name {
+			return st, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown session state %q", name)
+}
+
+// Active reports whether a session in this state may still change files.
+func (s State) Active() bool {
+	return s != Failed && s != Merged
+}
+
 // EventType classifies messages sent from a runner goroutine to the TUI.
 type EventType int
 
 const (
-	EventOutput   EventType = iota // new text output to append to the log
-	EventPlanDone                  // planning phase complete, plan text ready
-	EventImplDone                  // implementation phase complete
-	EventPRDone                    // PR created, URL in Text
-	EventError                     // unrecoverable error
+	EventOutput       EventType = iota // new text output to append to the log
+	EventPlanDone                      // planning phase complete, plan text ready
+	EventImplDone                      // implementation phase complete
+	EventPRDone                        // PR created, URL in Text
+	EventError                         // unrecoverable error
+	EventFinished                      // work committed without opening a PR, branch in Text
+	EventPlannedFiles                  // files the plan mentions, one per line in Text
+	EventFilesChanged                  // files modified in the worktree, one per line in Text
+	EventBlocked                       // pre-push check findings in Text; the runner awaits a Decision to push anyway
+	EventPermission                    // claude asks to use a tool, summarized in Text; empty Text withdraws the request
+	EventFollowUp                      // the user's follow-up message in Text is being delivered to claude
+	EventTool                          // claude used a tool, summarized in Text
 )
 
+// Decision is the user's verdict on a plan, or on pushing despite pre-push
+// check findings, sent to the waiting runner.
+type Decision struct {
+	Approve bool
+	// Bases, if set, are branches to rebase the worktree onto (the first)
+	// and merge in (the rest) before implementing. The PR then targets
+	// Bases[0].
+	Bases []string
+	// Also are further issues to address in the same branch and PR.
+	Also []Issue
+	// Plan, if set, replaces the agent's plan, as edited by the user.
+	Plan string
+}
+
+// FollowUp is a message from the user for a session's agent, sent to its
+// runner.
+type FollowUp struct {
+	Text string
+	// Plan, if set, is the user's edit of the plan the message replies to,
+	// which the agent has not seen.
+	Plan string
+}
+
 // Event is sent from a runner goroutine to the TUI via a shared channel.
 type Event struct {
-	IssueNumber int
-	Type        EventType
-	Text        string
+	Key  string // Issue.Key of the session
+	Type EventType
+	Text string
 }
 
 // Session tracks one Claude Code session working on a single issue.
 type Session struct {
-	Issue Issue
-	State State
-	Plan  string // accumulated plan text (set after planning phase)
-	Log   string // full streamed output
-	PR    string // PR URL (set after Done)
-	Err   error
+	Issue  Issue
+	State  State
+	Plan   string    // accumulated plan text (set after planning phase)
+	Edited bool      // the user edited Plan since the agent last gave it
+	Log    LogBuffer // streamed output
+	PR     string    // PR URL (set after Done)
+	Branch string    // branch holding the work, set when finished without a PR
+	Deps   []string  // keys of sessions that must be done before this one starts
+	Err    error
+	// Findings reports what the pre-push checks found, if they blocked the
+	// push.
+	Findings string
+	// Prompt summarizes the tool use claude is asking permission for, while
+	// it waits for the user's answer.
+	Prompt string
+	// Tools summarizes the agent's tool calls, in order.
+	Tools []string
+	// FollowUps are the user's messages queued for the agent and not yet
+	// delivered.
+	FollowUps []string
+
+	// Files the plan mentions and files actually modified, as paths
+	// relative to the worktree. Used to spot sessions likely to conflict.
+	PlannedFiles []string
+	ChangedFiles []string
+
+	// Serialized sessions build on their prerequisites' branches even
+	// without stacking, and defer implementation until they are done.
+	Serialized bool
+	MergedInto string  // key of the session that took over this issue
+	Also       []Issue // issues merged into this session
+
+	// Batch holds the keys of the sessions a batch session combines, and
+	// BatchedInto the key of the batch a finished session was added to.
+	Batch       []string
+	BatchedInto string
+}
+
+// Batchable reports whether the session's work is committed on a branch
+// with no PR yet, so it can be combined into a batch PR.
+func (s *Session) Batchable() bool {
+	return s.State == Done && s.Branch != "" && s.PR == "" && s.BatchedInto == "" && s.Batch == nil
+}
+
+// Files returns the union of planned and changed files.
+func (s *Session) Files() []string {
+	return union(s.PlannedFiles, s.ChangedFiles)
+}
+
+// union returns the distinct strings of a and b, in order.
+func union(a, b []string) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var out []string
+	for _, x := range append(append([]string{}, a...), b...) {
+		if !seen[x] {
+			seen[x] = true
+			out = append(out, x)
+		}
+	}
+	return out
 }
 
 // Badge returns a short status indicator for display in the session list.
@@ -84,6 +213,12 @@ func (s *Session) Badge() string {
 		return "✓"
 	case Failed:
 		return "✗"
+	case Queued:
+		return "◷"
+	case Merged:
+		return "⇢"
+	case Blocked:
+		return "⚠"
 	default:
 		return "?"
 	}
@@ -0,0 +1,305 @@
+package session
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/tomfevang/go-work/internal/forge"
+)
+
+// AgentSettings configures the coding agent sessions run.
+type AgentSettings struct {
+	// Command is the claude executable.
+	Command string `yaml:"command"`
+	// Plan, Implement and Resolve configure claude in the planning and
+	// implementation phases and when resolving conflicts in batches.
+	Plan      Profile `yaml:"plan"`
+	Implement Profile `yaml:"implement"`
+	Resolve   Profile `yaml:"resolve"`
+	// PlanPrompt and ImplementPrompt are Go templates. Both see .Ref and
+	// .Issue, the issue as markdown; ImplementPrompt also sees .Plan and
+	// .Also, the markdown of issues merged into the session, if any.
+	PlanPrompt      string `yaml:"plan_prompt"`
+	ImplementPrompt string `yaml:"implement_prompt"`
+	// Sandbox confines the agent's processes.
+	Sandbox SandboxSettings `yaml:"sandbox"`
+	// PermissionPrompts asks the user in the dashboard whenever claude
+	// wants to use a tool its profile does not allow, instead of letting
+	// the use fail.
+	PermissionPrompts bool `yaml:"permission_prompts"`
+}
+
+// Profile configures claude for one phase of a session. Empty settings
+// leave claude's defaults.
+type Profile struct {
+	// AllowedTools and DisallowedTools are passed as --allowedTools and
+	// --disallowedTools.
+	AllowedTools    []string `yaml:"allowed_tools"`
+	DisallowedTools []string `yaml:"disallowed_tools"`
+	// Bash lists the shell commands allowed, as claude's Bash permission
+	// patterns such as "go test:*". It restricts Bash, so AllowedTools must
+	// not also allow all of it.
+	Bash []string `yaml:"bash"`
+	// Model is passed as --model, e.g. opus or sonnet.
+	Model string `yaml:"model"`
+	// MaxTurns limits the agent's turns in the phase.
+	MaxTurns int `yaml:"max_turns"`
+	// MCPConfig is an MCP server config file, relative to the worktree
+	// unless absolute.
+	MCPConfig string `yaml:"mcp_config"`
+	// Args are further command-line arguments.
+	Args []string `yaml:"args"`
+}
+
+// args returns claude's command-line arguments for the profile, loading
+// the MCP servers in mcpConfigs besides the profile's own.
+func (p Profile) args(mcpConfigs ...string) []string {
+	var args []string
+	allowed := slices.Clone(p.AllowedTools)
+	for _, cmd := range p.Bash {
+		allowed = append(allowed, "Bash("+cmd+")")
+	}
+	if len(allowed) > 0 {
+		args = append(args, "--allowedTools", strings.Join(allowed, ","))
+	}
+	if len(p.DisallowedTools) > 0 {
+		args = append(args, "--disallowedTools", strings.Join(p.DisallowedTools, ","))
+	}
+	if p.Model != "" {
+		args = append(args, "--model", p.Model)
+	}
+	if p.MaxTurns > 0 {
+		args = append(args, "--max-turns", strconv.Itoa(p.MaxTurns))
+	}
+	if p.MCPConfig != "" {
+		mcpConfigs = append([]string{p.MCPConfig}, mcpConfigs...)
+	}
+	if len(mcpConfigs) > 0 {
+		args = append(append(args, "--mcp-config"), mcpConfigs...)
+	}
+	return append(args, p.Args...)
+}
+
+// Validate reports the first invalid setting, naming its key.
+func (p Profile) Validate() error {
+	if len(p.Bash) > 0 && slices.Contains(p.AllowedTools, "Bash") {
+		return fmt.Errorf("bash: Bash in allowed_tools would allow any command")
+	}
+	for i, cmd := range p.Bash {
+		if strings.TrimSpace(cmd) == "" || strings.ContainsAny(cmd, "()") {
+			return fmt.Errorf("bash[%d]: %q is not a command pattern", i, cmd)
+		}
+	}
+	if p.MaxTurns < 0 {
+		return fmt.Errorf("max_turns: must not be negative")
+	}
+	return nil
+}
+
+// WorktreeSettings configures where sessions work.
+type WorktreeSettings struct {
+	// Dir holds the worktrees, relative to the repo root unless absolute.
+	Dir string `yaml:"dir"`
+	// Branch is a Go template for branch names. It sees .Key, .Number,
+	// .Source, .Ref, .Slug (the title in lowercase-dashed form) and .Local.
+	Branch string `yaml:"branch"`
+}
+
+// PRTemplates optionally override the title and body of pull requests.
+// They are Go templates seeing .Title and .Body, the defaults, and .Issues.
+type PRTemplates struct {
+	Title string `yaml:"title"`
+	Body  string `yaml:"body"`
+}
+
+// DefaultAgent returns the built-in agent settings.
+func DefaultAgent() AgentSettings {
+	return AgentSettings{
+		Command:   "claude",
+		Implement: Profile{AllowedTools: []string{"Edit", "Write", "Bash", "Glob", "Grep", "Read"}},
+		Resolve:   Profile{AllowedTools: []string{"Edit", "Read", "Grep", "Glob"}},
+		PlanPrompt: "You are working on the following issue:\n\n{{.Issue}}\n\n" +
+			"Create a concise implementation plan. List the files you will change, " +
+			"your approach, and any edge cases. Do NOT write any code yet. " +
+			"End your response with the exact line: PLAN COMPLETE\n",
+		ImplementPrompt: "Implement the following approved plan for issue {{.Ref}}.\n\n" +
+			"ISSUE:\n{{.Issue}}\n\nAPPROVED PLAN:\n{{.Plan}}\n\n" +
+			"{{if .Also}}ALSO RESOLVE THESE ISSUES in the same change; they were merged " +
+			"into this session because they touch the same files:\n\n{{.Also}}\n\n{{end}}" +
+			"Write the code. After implementation, run the project's tests if a " +
+			"test command is available. Then stage and commit all your changes with " +
+			"a descriptive commit message. Do NOT create a pull request.\n",
+		Sandbox:           DefaultSandbox(),
+		PermissionPrompts: true,
+	}
+}
+
+// DefaultWorktrees returns the built-in worktree settings.
+func DefaultWorktrees() WorktreeSettings {
+	return WorktreeSettings{
+		Dir:    ".worktrees",
+		Branch: "{{if .Local}}{{.Key}}{{else}}issue-{{.Number}}{{end}}",
+	}
+}
+
+// Validate reports the first invalid setting, naming its key.
+func (a AgentSettings) Validate() error {
+	if a.Command == "" {
+		return fmt.Errorf("command: must not be empty")
+	}
+	if err := checkTemplate(a.PlanPrompt, promptData{}); err != nil {
+		return fmt.Errorf("plan_prompt: %w", err)
+	}
+	if err := checkTemplate(a.ImplementPrompt, promptData{}); err != nil {
+		return fmt.Errorf("implement_prompt: %w", err)
+	}
+	if err := a.Plan.Validate(); err != nil {
+		return fmt.Errorf("plan.%w", err)
+	}
+	if err := a.Implement.Validate(); err != nil {
+		return fmt.Errorf("implement.%w", err)
+	}
+	if err := a.Resolve.Validate(); err != nil {
+		return fmt.Errorf("resolve.%w", err)
+	}
+	if err := a.Sandbox.Validate(); err != nil {
+		return fmt.Errorf("sandbox.%w", err)
+	}
+	return nil
+}
+
+// Validate reports the first invalid setting, naming its key.
+func (w WorktreeSettings) Validate() error {
+	if w.Dir == "" {
+		return fmt.Errorf("dir: must not be empty")
+	}
+	if err := checkTemplate(w.Branch, branchData{}); err != nil {
+		return fmt.Errorf("branch: %w", err)
+	}
+	// Sessions must not share a branch: each deletes its own when it
+	// fails or is cleaned up.
+	seen := make(map[string]Issue)
+	for _, iss := range []Issue{
+		{Number: 1, Title: "Example"},
+		{Number: 2, Title: "Example"}, // titles repeat
+		{Number: 1, Title: "Example", Source: forge.SourceTasks},
+		{Number: 1, Title: "Example", Source: forge.SourceTODO},
+		{Number: 1, Title: "Example", Source: forge.SourcePrompt},
+	} {
+		name, _ := render(w.Branch, newBranchData(iss))
+		name = strings.TrimSpace(name)
+		if name == "" || strings.ContainsAny(name, " ~^:?*[\\") {
+			return fmt.Errorf("branch: %q is not a valid branch name", name)
+		}
+		if other, ok := seen[name]; ok {
+			return fmt.Errorf("branch: gives %s and %s the same branch %q", other.Ref(), iss.Ref(), name)
+		}
+		seen[name] = iss
+	}
+	return nil
+}
+
+// Validate reports the first invalid setting, naming its key.
+func (p PRTemplates) Validate() error {
+	if err := checkTemplate(p.Title, prData{}); err != nil {
+		return fmt.Errorf("title: %w", err)
+	}
+	if err := checkTemplate(p.Body, prData{}); err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+	return nil
+}
+
+// Path returns the worktree directory for the session with the given key.
+func (w WorktreeSettings) Path(repoRoot, key string) string {
+	if filepath.IsAbs(w.Dir) {
+		return filepath.Join(w.Dir, key)
+	}
+	return filepath.Join(repoRoot, w.Dir, key)
+}
+
+// BranchName returns the branch a session for iss works on.
+func (w WorktreeSettings) BranchName(iss Issue) string {
+	name, err := render(w.Branch, newBranchData(iss))
+	if err != nil || strings.TrimSpace(name) == "" {
+		return newBranchData(iss).Key // validated at load, so only on odd issue data
+	}
+	return strings.TrimSpace(name)
+}
+
+type branchData struct {
+	Key, Source, Ref, Slug string
+	Number                 int
+	Local                  bool
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+func newBranchData(iss Issue) branchData {
+	iss.Repo = "" // branches live in the issue's repo, so need no prefix
+	slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(iss.Title), "-"), "-")
+	if len(slug) > 40 {
+		slug = strings.TrimRight(slug[:40], "-")
+	}
+	return branchData{
+		Key:    iss.Key(),
+		Source: string(iss.Source),
+		Ref:    iss.Ref(),
+		Slug:   slug,
+		Number: iss.Number,
+		Local:  iss.IsLocal(),
+	}
+}
+
+type promptData struct {
+	Ref, Issue, Plan, Also string
+}
+
+type prData struct {
+	Title, Body string
+	Issues      []Issue
+}
+
+// prText returns the title and body of a pull request resolving issues,
+// applying the templates over PRText's defaults.
+func (p PRTemplates) prText(issues []Issue) (title, body string, err error) {
+	title, body = PRText(issues)
+	data := prData{Title: title, Body: body, Issues: issues}
+	if p.Title != "" {
+		if title, err = render(p.Title, data); err != nil {
+			return "", "", fmt.Errorf("pull request title: %w", err)
+		}
+		title = strings.TrimSpace(title)
+	}
+	if p.Body != "" {
+		if body, err = render(p.Body, data); err != nil {
+			return "", "", fmt.Errorf("pull request body: %w", err)
+		}
+	}
+	return title, body, nil
+}
+
+func render(text string, data any) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// checkTemplate parses text and executes it against zero data, catching
+// syntax errors and references to fields that don't exist.
+func checkTemplate(text string, data any) error {
+	_, err := render(text, data)
+	return err
+}
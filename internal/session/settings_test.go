@@ -0,0 +1,33 @@
+package session
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWorktreeSettingsValidate(t *testing.T) {
+	tests := []struct {
+		branch  string
+		wantErr string // substring of the error, or "" for none
+	}{
+		{DefaultWorktrees().Branch, ""},
+		{"go-work/{{.Key}}", ""},
+		{"{{.Key}}-{{.Slug}}", ""},
+		{"go-work", "same branch"},
+		{"issue-{{.Number}}", "same branch"},     // task-1 and #1
+		{"{{.Source}}-{{.Slug}}", "same branch"}, // #1 and #2 with the same title
+		{"{{.Slug}}", "same branch"},
+		{"{{if .Local}}x{{end}}", "not a valid branch name"},
+		{"fix {{.Key}}", "not a valid branch name"},
+		{"{{.Nope}}", "branch:"},
+	}
+	for _, tt := range tests {
+		err := WorktreeSettings{Dir: ".worktrees", Branch: tt.branch}.Validate()
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("branch %q: %v", tt.branch, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("branch %q: error %v, want one containing %q", tt.branch, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tomfevang/go-work/internal/telemetry"
+)
+
+// Store persists sessions in a state directory, so other go-work processes
+// can see them: a JSON snapshot of every session in sessions.json
+// and each session's log in logs/<key>.log. Records from earlier runs are
+// kept until removed.
+type Store struct {
+	dir      string
+	saved    map[string]Record // as last written by this process
+	attached map[string]bool   // sessions whose Log writes to its file
+}
+
+// Record is the persisted form of a session.
+type Record struct {
+	Key      string    `json:"key"`
+	Ref      string    `json:"ref"`
+	Title    string    `json:"title"`
+	URL      string    `json:"url,omitempty"`
+	State    string    `json:"state"` // State.Name()
+	Branch   string    `json:"branch,omitempty"`
+	PR       string    `json:"pr,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Plan     string    `json:"plan,omitempty"`
+	Findings string    `json:"findings,omitempty"` // of the pre-push checks, while blocked
+	Prompt   string    `json:"prompt,omitempty"`   // permission request awaiting an answer
+	Repo     string    `json:"repo,omitempty"`     // in workspaces
+	RepoRoot string    `json:"repo_root"`
+	Worktree string    `json:"worktree"`
+	Owner    int       `json:"owner"` // pid of the process running the session
+	Updated  time.Time `json:"updated"`
+}
+
+// Finished reports whether the session reached a final state.
+func (r Record) Finished() bool {
+	return r.State == Done.Name() || r.State == Failed.Name() || r.State == Merged.Name()
+}
+
+// Orphaned reports whether the session is unfinished but the process that
+// ran it has exited.
+func (r Record) Orphaned() bool {
+	if r.Finished() {
+		return false
+	}
+	p, err := os.FindProcess(r.Owner)
+	return err != nil || p.Signal(syscall.Signal(0)) != nil
+}
+
+// StateDir returns the directory go-work keeps state in for the repo at
+// repoRoot: go-work inside the git directory, shared by all worktrees.
+func StateDir(repoRoot string) (string, error) {
+	out, err := exec.Command("git", "-C", repoRoot, "rev-parse", "--git-common-dir").Output()
+	if err != nil {
+		return "", fmt.Errorf("find git directory: %w", err)
+	}
+	dir := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(repoRoot, dir)
+	}
+	return filepath.Join(dir, "go-work"), nil
+}
+
+// OpenStore returns the store in dir, creating the directory if needed.
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "logs"), 0o700); err != nil {
+		return nil, err
+	}
+	return &Store{dir: dir, saved: make(map[string]Record), attached: make(map[string]bool)}, nil
+}
+
+// SocketPath is where the process running sessions serves the API.
+func (st *Store) SocketPath() string { return filepath.Join(st.dir, "go-work.sock") }
+
+// LogPath returns the log file of the session with the given key.
+func (st *Store) LogPath(key string) string { return filepath.Join(st.dir, "logs", key+".log") }
+
+func (st *Store) snapshotPath() string { return filepath.Join(st.dir, "sessions.json") }
+
+// Load returns the persisted sessions, most recently updated last.
+func (st *Store) Load() ([]Record, error) {
+	data, err := os.ReadFile(st.snapshotPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var recs []Record
+	if err := json.Unmarshal(data, &recs); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", st.snapshotPath(), err)
+	}
+	return recs, nil
+}
+
+// Remove drops the sessions with the given keys and their logs.
+func (st *Store) Remove(keys []string) error {
+	recs, err := st.Load()
+	if err != nil {
+		return err
+	}
+	drop := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		drop[k] = true
+		_ = os.Remove(st.LogPath(k))
+	}
+	kept := recs[:0]
+	for _, r := range recs {
+		if !drop[r.Key] {
+			kept = append(kept, r)
+		}
+	}
+	return st.write(kept)
+}
+
+// save persists the Manager's sessions if any changed since the last save,
+// and has new sessions' logs write to their files. Failures are counted and otherwise ignored;
+// persistence must never hold up sessions.
+func (st *Store) save(m *Manager) {
+	var changed []Record
+	for _, key := range m.order {
+		s := m.sessions[key]
+		if err := st.attachLog(key, &s.Log); err != nil {
+			telemetry.CommandErrors.Inc("state")
+		}
+		r := m.toRecord(s)
+		if prev, ok := st.saved[key]; ok && prev == r {
+			continue
+		}
+		st.saved[key] = r
+		r.Updated = time.Now()
+		changed = append(changed, r)
+	}
+	if len(changed) == 0 {
+		return
+	}
+
+	// Merge into the file rather than overwrite it, keeping sessions of
+	// earlier runs.
+	recs, _ := st.Load()
+	for _, r := range changed {
+		recs = upsert(recs, r)
+	}
+	if err := st.write(recs); err != nil {
+		telemetry.CommandErrors.Inc("state")
+	}
+}
+
+func upsert(recs []Record, r Record) []Record {
+	for i := range recs {
+		if recs[i].Key == r.Key {
+			return append(append(recs[:i], recs[i+1:]...), r)
+		}
+	}
+	return append(recs, r)
+}
+
+// attachLog attaches log to the session's log file, once. It replaces any
+// log left by an earlier run of the same session.
+func (st *Store) attachLog(key string, log *LogBuffer) error {
+	if st.attached[key] {
+		return nil
+	}
+	f, err := os.OpenFile(st.LogPath(key), os.O_CREATE|os.O_TRUNC|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := log.Attach(f); err != nil {
+		return err
+	}
+	st.attached[key] = true
+	return nil
+}
+
+// write replaces the snapshot atomically, so readers never see a partial
+// file.
+func (st *Store) write(recs []Record) error {
+	data, err := json.MarshalIndent(recs, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(st.dir, "sessions-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), st.snapshotPath())
+}
+
+func (m *Manager) toRecord(s *Session) Record {
+	key := s.Issue.Key()
+	env := m.envs[s.Issue.Repo]
+	r := Record{
+		Key:      key,
+		Ref:      s.Issue.Ref(),
+		Title:    s.Issue.Title,
+		URL:      s.Issue.URL,
+		State:    s.State.Name(),
+		Branch:   s.Branch,
+		PR:       s.PR,
+		Plan:     s.Plan,
+		Findings: s.Findings,
+		Prompt:   s.Prompt,
+		Repo:     s.Issue.Repo,
+		RepoRoot: env.RepoRoot,
+		Worktree: env.Worktrees.Path(env.RepoRoot, key),
+		Owner:    os.Getpid(),
+	}
+	if r.Branch == "" && s.State != Pending {
+		r.Branch = env.Worktrees.BranchName(s.Issue)
+	}
+	if s.Err != nil {
+		r.Error = s.Err.Error()
+	}
+	return r
+}
@@ -0,0 +1,167 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tomfevang/go-work/internal/telemetry"
+)
+
+// Diff returns the changes in a session's worktree dir, committed or not,
+// relative to where its branch forked from the repo's HEAD.
+func Diff(repoRoot, dir string) (string, error) {
+	head, err := gitOutput(repoRoot, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	base, err := gitOutput(dir, "merge-base", head, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	diff, err := gitOutput(dir, "diff", base)
+	if err != nil {
+		return "", err
+	}
+	// git diff leaves out new files until they are in the index, which is
+	// the agent's to change, so they are diffed one by one.
+	out, err := exec.Command("git", "-C", dir, "ls-files", "-z", "--others", "--exclude-standard").Output()
+	if err != nil {
+		telemetry.CommandErrors.Inc("git")
+		return "", fmt.Errorf("git ls-files: %w", err)
+	}
+	parts := []string{diff}
+	for _, file := range strings.Split(string(out), "\x00") {
+		if file == "" {
+			continue
+		}
+		// --no-index exits with 1 when the files differ, as they do.
+		out, err := exec.Command("git", "-C", dir, "diff", "--no-index", "--", os.DevNull, file).Output()
+		var exitErr *exec.ExitError
+		if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+			telemetry.CommandErrors.Inc("git")
+			return "", fmt.Errorf("git diff %s: %w", file, err)
+		}
+		parts = append(parts, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n")), nil
+}
+
+// gitOutput runs git in dir and returns its trimmed stdout.
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		telemetry.CommandErrors.Inc("git")
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// rebase moves the worktree's branch onto base.
+func rebase(dir, base string) error {
+	if out, err := exec.Command("git", "-C", dir, "rebase", base).CombinedOutput(); err != nil {
+		telemetry.CommandErrors.Inc("git")
+		_ = exec.Command("git", "-C", dir, "rebase", "--abort").Run()
+		return fmt.Errorf("rebase onto %s: %w\n%s", base, err, out)
+	}
+	return nil
+}
+
+// mergeBases merges each branch into the worktree's branch.
+func mergeBases(dir string, branches []string) error {
+	for _, b := range branches {
+		if out, err := exec.Command("git", "-C", dir, "merge", "--no-edit", b).CombinedOutput(); err != nil {
+			telemetry.CommandErrors.Inc("git")
+			return fmt.Errorf("merge prerequisite branch %s: %w\n%s", b, err, out)
+		}
+	}
+	return nil
+}
+
+// changedFiles lists files changed in dir since commit, committed or not.
+func changedFiles(dir, since string) []string {
+	var files []string
+	if since != "" {
+		if out, err := gitOutput(dir, "diff", "--name-only", since, "HEAD"); err == nil && out != "" {
+			files = strings.Split(out, "\n")
+		}
+	}
+	if out, err := gitOutput(dir, "status", "--porcelain", "--untracked-files=all"); err == nil && out != "" {
+		for _, line := range strings.Split(out, "\n") {
+			if len(line) > 3 {
+				name := line[3:]
+				if _, to, ok := strings.Cut(name, " -> "); ok {
+					name = to // rename
+				}
+				files = append(files, name)
+			}
+		}
+	}
+	return union(files, nil)
+}
+
+// pathPattern matches tokens that look like file paths: a name with an
+// extension, optionally preceded by directories.
+var pathPattern = regexp.MustCompile("[\\w.-]+(?:/[\\w.-]+)*\\.[A-Za-z0-9]+")
+
+// plannedFiles extracts the files a plan mentions. Tokens are kept if the
+// file exists in the worktree or, for files the plan creates, if the token
+// includes a directory that exists.
+func plannedFiles(dir, plan string) []string {
+	var files []string
+	for _, tok := range pathPattern.FindAllString(plan, -1) {
+		tok = strings.TrimPrefix(tok, "./")
+		if strings.HasPrefix(tok, ".") || strings.Contains(tok, "..") {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, tok)); err == nil {
+			files = append(files, tok)
+			continue
+		}
+		if d := filepath.Dir(tok); d != "." {
+			if fi, err := os.Stat(filepath.Join(dir, d)); err == nil && fi.IsDir() {
+				files = append(files, tok)
+			}
+		}
+	}
+	return union(files, nil)
+}
+
+// editedPath returns the worktree-relative path a file-editing tool call
+// touches, or "" if it is outside the worktree.
+func editedPath(dir string, input json.RawMessage) string {
+	var in toolInput
+	if json.Unmarshal(input, &in) != nil {
+		return ""
+	}
+	path := in.FilePath
+	if path == "" {
+		path = in.NotebookPath
+	}
+	if path == "" {
+		return ""
+	}
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return ""
+		}
+		path = rel
+	}
+	return filepath.ToSlash(path)
+}
+
+func allLocal(issues []Issue) bool {
+	for _, iss := range issues {
+		if !iss.IsLocal() {
+			return false
+		}
+	}
+	return true
+}
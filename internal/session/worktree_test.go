@@ -0,0 +1,83 @@
+package session
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// gitRepo returns a repository with one commit, and git set up to ignore
+// the user's and system's config.
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	git(t, root, "init", "-q", "-b", "main")
+	writeFile(t, filepath.Join(root, "README.md"), "hello\n")
+	git(t, root, "add", "README.md")
+	git(t, root, "commit", "-q", "-m", "Initial commit")
+	return root
+}
+
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	root := gitRepo(t)
+	dir := filepath.Join(t.TempDir(), "wt")
+	git(t, root, "worktree", "add", "-q", "-b", "go-work/1", dir)
+
+	writeFile(t, filepath.Join(dir, "README.md"), "hello\nworld\n")
+	git(t, dir, "commit", "-q", "-am", "Committed change")
+	writeFile(t, filepath.Join(dir, "README.md"), "hello\nworld\nagain\n")
+	writeFile(t, filepath.Join(dir, "pkg", "new file.go"), "package pkg\n")
+	writeFile(t, filepath.Join(dir, ".gitignore"), "*.tmp\n")
+	writeFile(t, filepath.Join(dir, "scratch.tmp"), "ignored\n")
+	index := git(t, dir, "ls-files", "--stage")
+
+	diff, err := Diff(root, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"+world", "+again", "+++ b/pkg/new file.go", "+package pkg", "+++ b/.gitignore"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff lacks %q:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "scratch.tmp") {
+		t.Errorf("diff shows an ignored file:\n%s", diff)
+	}
+	if got := git(t, dir, "ls-files", "--stage"); got != index {
+		t.Errorf("Diff changed the index:\n%s\nwas\n%s", got, index)
+	}
+}
@@ -0,0 +1,286 @@
+// Package tasks loads work items that don't live in the forge's issue
+// tracker: a local task file, TODO(go-work) comments, and ad hoc prompts.
+// They are returned as forge.Issue values so the rest of go-work treats them
+// like any other issue.
+package tasks
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/tomfevang/go-work/internal/forge"
+)
+
+// Settings configures the local task sources.
+type Settings struct {
+	// File is the task file, relative to the repo root. When empty, the
+	// first of DefaultFiles that exists is used.
+	File string `yaml:"file"`
+	// TODOs enables scanning the code for TODOMarker comments.
+	TODOs bool `yaml:"todos"`
+	// CreatePR opens pull requests for local tasks too. Otherwise their
+	// sessions finish once the work is committed on its branch.
+	CreatePR bool `yaml:"create_pr"`
+}
+
+// DefaultFiles are the task files looked for when Settings.File is empty.
+var DefaultFiles = []string{"TASKS.md", "TASKS.yaml", "TASKS.yml"}
+
+// TODOMarker introduces a task comment in the code.
+const TODOMarker = "TODO(go-work):"
+
+// Load returns the tasks from the task file and, if enabled, TODO comments.
+func Load(repoRoot string, s Settings) ([]forge.Issue, error) {
+	issues, err := loadFile(repoRoot, s.File)
+	if err != nil {
+		return nil, err
+	}
+	if s.TODOs {
+		todos, err := ScanTODOs(repoRoot)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, todos...)
+	}
+	return issues, nil
+}
+
+func loadFile(repoRoot, name string) ([]forge.Issue, error) {
+	candidates := DefaultFiles
+	if name != "" {
+		candidates = []string{name}
+	}
+	for _, c := range candidates {
+		path := filepath.Join(repoRoot, c)
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) && name == "" {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read task file: %w", err)
+		}
+		switch filepath.Ext(c) {
+		case ".yaml", ".yml":
+			return parseYAML(path, data)
+		default:
+			return ParseMarkdown(data), nil
+		}
+	}
+	return nil, nil
+}
+
+// ParseMarkdown reads unchecked checklist items ("- [ ] title") as tasks.
+// Lines indented under an item form its body. Checked items are skipped.
+func ParseMarkdown(data []byte) []forge.Issue {
+	var issues []forge.Issue
+	var cur *forge.Issue
+	var body []string
+	numbers := taskNumbers{}
+
+	flush := func() {
+		if cur != nil {
+			cur.Body = strings.TrimSpace(strings.Join(body, "\n"))
+			issues = append(issues, *cur)
+		}
+		cur, body = nil, nil
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		indented := strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+
+		switch {
+		case !indented && (strings.HasPrefix(trimmed, "- [ ]") || strings.HasPrefix(trimmed, "* [ ]")):
+			flush()
+			title := strings.TrimSpace(trimmed[5:])
+			cur = newTask(forge.SourceTasks, numbers.next(title), title, "")
+		case !indented && trimmed != "":
+			flush() // checked item, heading or prose ends the current task
+		case cur != nil:
+			body = append(body, strings.TrimPrefix(strings.TrimPrefix(line, "  "), "\t"))
+		}
+	}
+	flush()
+	return issues
+}
+
+// yamlTask is one entry of a YAML task file.
+type yamlTask struct {
+	// ID fixes the task's number. Without it the number is derived from
+	// the title.
+	ID     int      `yaml:"id"`
+	Title  string   `yaml:"title"`
+	Body   string   `yaml:"body"`
+	Labels []string `yaml:"labels"`
+	Done   bool     `yaml:"done"`
+}
+
+func parseYAML(path string, data []byte) ([]forge.Issue, error) {
+	var list []yamlTask
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	numbers := taskNumbers{}
+	for i, t := range list {
+		switch {
+		case t.Title == "":
+			return nil, fmt.Errorf("%s: task %d: title is required", path, i+1)
+		case t.ID < 0 || t.ID > maxTaskNumber:
+			return nil, fmt.Errorf("%s: task %d: id must be between 1 and %d", path, i+1, maxTaskNumber)
+		case t.ID > 0 && numbers[t.ID]:
+			return nil, fmt.Errorf("%s: task %d: id %d is used twice", path, i+1, t.ID)
+		}
+		if t.ID > 0 {
+			numbers[t.ID] = true
+		}
+	}
+	var issues []forge.Issue
+	for _, t := range list {
+		if t.Done {
+			continue
+		}
+		n := t.ID
+		if n == 0 {
+			n = numbers.next(t.Title)
+		}
+		iss := newTask(forge.SourceTasks, n, t.Title, t.Body)
+		for _, l := range t.Labels {
+			iss.Labels = append(iss.Labels, forge.Label{Name: l})
+		}
+		issues = append(issues, *iss)
+	}
+	return issues, nil
+}
+
+// ScanTODOs finds TODOMarker comments in files tracked by git. Each
+// becomes a task whose title is the comment text and whose body points at
+// the location with some surrounding code.
+func ScanTODOs(repoRoot string) ([]forge.Issue, error) {
+	out, err := exec.Command("git", "-C", repoRoot, "grep", "-n", "-I", "-F", TODOMarker).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return nil, nil // no matches
+	}
+	if err != nil {
+		return nil, fmt.Errorf("git grep: %w", err)
+	}
+
+	var issues []forge.Issue
+	numbers := taskNumbers{}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		file, rest, _ := strings.Cut(line, ":")
+		lineNo, text, _ := strings.Cut(rest, ":")
+		_, title, _ := strings.Cut(text, TODOMarker)
+		title = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(title), "*/"))
+		title = strings.TrimSpace(strings.TrimSuffix(title, "-->"))
+		if title == "" || !unicode.IsLetter([]rune(title)[0]) && !unicode.IsDigit([]rune(title)[0]) {
+			continue // mentions of the marker itself, e.g. in docs or strings
+		}
+
+		body := fmt.Sprintf("Found in `%s:%s`:\n\n```\n%s\n```\n\nRemove the %s comment once it is addressed.",
+			file, lineNo, surrounding(filepath.Join(repoRoot, file), lineNo), TODOMarker)
+		issues = append(issues, *newTask(forge.SourceTODO, numbers.next(title), title, body))
+	}
+	return issues, nil
+}
+
+// maxTaskNumber bounds the numbers of tasks from files and TODO comments.
+const maxTaskNumber = 99999
+
+// taskNumbers hands out the numbers of one source's tasks. A number is
+// derived from the task's title, so it stays the same, along with the key of
+// the task's session and branch, as other tasks are added and removed.
+type taskNumbers map[int]bool
+
+// next returns the number of the task titled title. Tasks whose titles
+// collide, or repeat, take the next free number in the order they come.
+func (used taskNumbers) next(title string) int {
+	h := fnv.New32a()
+	h.Write([]byte(title))
+	n := 1 + int(h.Sum32()%maxTaskNumber)
+	for used[n] {
+		n = n%maxTaskNumber + 1
+	}
+	used[n] = true
+	return n
+}
+
+// surrounding returns a few lines of the file around lineNo.
+func surrounding(path, lineNo string) string {
+	n, err := strconv.Atoi(lineNo)
+	if err != nil || n < 1 {
+		return ""
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(string(data), "\n")
+	from, to := max(n-4, 0), min(n+5, len(lines))
+	return strings.Join(lines[from:to], "\n")
+}
+
+// Resolve looks up issues by reference: a forge issue number, with or
+// without the leading '#', or the key of a local task such as "task-2" or
+// "todo-1".
+func Resolve(repoRoot string, s Settings, fg forge.Forge, refs []string) ([]forge.Issue, error) {
+	var local []forge.Issue
+	var loaded bool
+	var issues []forge.Issue
+	for _, ref := range refs {
+		if n, err := strconv.Atoi(strings.TrimPrefix(ref, "#")); err == nil {
+			iss, err := fg.GetIssue(n)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", ref, err)
+			}
+			issues = append(issues, iss)
+			continue
+		}
+		if !loaded {
+			var err error
+			if local, err = Load(repoRoot, s); err != nil {
+				return nil, err
+			}
+			loaded = true
+		}
+		i := slices.IndexFunc(local, func(iss forge.Issue) bool { return iss.Key() == ref })
+		if i < 0 {
+			return nil, fmt.Errorf("%s: no such issue or local task", ref)
+		}
+		issues = append(issues, local[i])
+	}
+	return issues, nil
+}
+
+// Prompt turns free text typed by the user into a task. The first line
+// becomes the title.
+func Prompt(number int, text string) forge.Issue {
+	text = strings.TrimSpace(text)
+	title, _, _ := strings.Cut(text, "\n")
+	return *newTask(forge.SourcePrompt, number, title, text)
+}
+
+func newTask(src forge.Source, number int, title, body string) *forge.Issue {
+	return &forge.Issue{
+		Number:   number,
+		Title:    title,
+		Body:     body,
+		Source:   src,
+		Detailed: true, // nothing more to fetch
+	}
+}
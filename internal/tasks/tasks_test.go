@@ -0,0 +1,207 @@
+package tasks
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tomfevang/go-work/internal/forge"
+)
+
+// keys returns the keys and titles of issues, as "task-1 Title".
+func keys(issues []forge.Issue) []string {
+	var out []string
+	for _, iss := range issues {
+		out = append(out, iss.Key()+" "+iss.Title)
+	}
+	return out
+}
+
+func TestTaskNumbers(t *testing.T) {
+	// Numbers are persisted in session keys and branch names, so the
+	// derivation must not change.
+	for title, want := range map[string]int{"One": 12190, "Two": 53333, "New": 27362, "C": 6878} {
+		if got := (taskNumbers{}).next(title); got != want {
+			t.Errorf("next(%q) = %d, want %d", title, got, want)
+		}
+	}
+
+	used := taskNumbers{}
+	first := used.next("Two")
+	if again := used.next("Two"); again != first+1 {
+		t.Errorf("a repeated title got %d, want the next free number %d", again, first+1)
+	}
+	if third := used.next("Two"); third != first+2 {
+		t.Errorf("a third repeat got %d, want %d", third, first+2)
+	}
+
+	// Taken numbers are skipped, wrapping around after the last.
+	used = taskNumbers{12190: true}
+	if got := used.next("One"); got != 12191 {
+		t.Errorf("next after a collision = %d, want 12191", got)
+	}
+	used = taskNumbers{}
+	for n := 12190; n <= maxTaskNumber; n++ {
+		used[n] = true
+	}
+	if got := used.next("One"); got != 1 {
+		t.Errorf("next with the numbers up to the last taken = %d, want 1", got)
+	}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	data := `# Tasks
+
+Some prose.
+
+- [ ] One
+  First line of the body.
+
+  Second paragraph.
+- [x] Done already
+  Not a task body.
+* [ ] Two
+	Tab-indented body.
+- [ ] Two
+## Later
+- [ ] New
+not indented, ends the task
+    still not a body
+`
+	got := ParseMarkdown([]byte(data))
+	want := []string{"task-12190 One", "task-53333 Two", "task-53334 Two", "task-27362 New"}
+	if !reflect.DeepEqual(keys(got), want) {
+		t.Fatalf("ParseMarkdown = %q, want %q", keys(got), want)
+	}
+	bodies := []string{"First line of the body.\n\nSecond paragraph.", "Tab-indented body.", "", ""}
+	for i, iss := range got {
+		if iss.Body != bodies[i] {
+			t.Errorf("%s: body = %q, want %q", iss.Key(), iss.Body, bodies[i])
+		}
+		if iss.Source != forge.SourceTasks || !iss.Detailed {
+			t.Errorf("%s: source %q, detailed %v", iss.Key(), iss.Source, iss.Detailed)
+		}
+	}
+
+	// Tasks keep their numbers as others are added and removed.
+	got = ParseMarkdown([]byte("- [ ] Zero\n- [ ] New\n- [ ] One\n"))
+	want = []string{"task-" + strconv.Itoa((taskNumbers{}).next("Zero")) + " Zero", "task-27362 New", "task-12190 One"}
+	if !reflect.DeepEqual(keys(got), want) {
+		t.Errorf("after edits, ParseMarkdown = %q, want %q", keys(got), want)
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "derived numbers",
+			data: "- title: One\n  body: Do it.\n  labels: [bug]\n- title: C\n",
+			want: []string{"task-12190 One", "task-6878 C"},
+		},
+		{
+			name: "explicit ids",
+			data: "- title: One\n  id: 3\n- title: Two\n  id: 12190\n- title: One\n",
+			want: []string{"task-3 One", "task-12190 Two", "task-12191 One"},
+		},
+		{
+			name: "done tasks keep their ids",
+			data: "- title: Old\n  id: 12190\n  done: true\n- title: One\n",
+			want: []string{"task-12191 One"},
+		},
+		{name: "missing title", data: "- title: One\n- body: No title\n", wantErr: "task 2: title is required"},
+		{name: "duplicate id", data: "- title: A\n  id: 3\n- title: B\n  id: 3\n", wantErr: "task 2: id 3 is used twice"},
+		{name: "negative id", data: "- title: A\n  id: -1\n", wantErr: "task 1: id must be between 1 and 99999"},
+		{name: "large id", data: "- title: A\n  id: 100000\n", wantErr: "task 1: id must be between"},
+		{name: "not a list", data: "title: A\n", wantErr: "parse TASKS.yaml"},
+	}
+	for _, tt := range tests {
+		got, err := parseYAML("TASKS.yaml", []byte(tt.data))
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: error %v, want one containing %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(keys(got), tt.want) {
+			t.Errorf("%s: parseYAML = %q, want %q", tt.name, keys(got), tt.want)
+		}
+	}
+
+	got, _ := parseYAML("TASKS.yaml", []byte("- title: One\n  body: Do it.\n  labels: [bug, ui]\n"))
+	if got[0].Body != "Do it." || !reflect.DeepEqual(got[0].LabelNames(), []string{"bug", "ui"}) {
+		t.Errorf("task = %+v, want its body and labels", got[0])
+	}
+}
+
+func TestScanTODOs(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	root := t.TempDir()
+	files := map[string]string{
+		"main.go":      "package main\n\n// TODO(go-work): retry failed uploads\nfunc main() {}\n",
+		"web/app.css":  "/* TODO(go-work): dark mode */\n",
+		"docs/x.html":  "<!-- TODO(go-work): link the FAQ -->\n",
+		"README.md":    "Mark tasks with `TODO(go-work):` comments.\n",
+		"untracked.go": "// TODO(go-work): not tracked\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "main.go", "web", "docs", "README.md"}} {
+		if out, err := exec.Command("git", append([]string{"-C", root}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, out)
+		}
+	}
+
+	got, err := ScanTODOs(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, iss := range got {
+		titles = append(titles, iss.Title)
+		if iss.Source != forge.SourceTODO || iss.Number != (taskNumbers{}).next(iss.Title) {
+			t.Errorf("%q: source %q, number %d", iss.Title, iss.Source, iss.Number)
+		}
+	}
+	want := []string{"link the FAQ", "retry failed uploads", "dark mode"} // in git grep's path order
+	if !reflect.DeepEqual(titles, want) {
+		t.Fatalf("ScanTODOs titles = %q, want %q", titles, want)
+	}
+	body := got[1].Body
+	if !strings.Contains(body, "`main.go:3`") || !strings.Contains(body, "func main() {}") {
+		t.Errorf("body = %q, want the location and surrounding code", body)
+	}
+
+	if err := os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := exec.Command("git", "-C", root, "rm", "-q", "--cached", "web/app.css", "docs/x.html").CombinedOutput(); err != nil {
+		t.Fatalf("git rm: %v\n%s", err, out)
+	}
+	if got, err := ScanTODOs(root); err != nil || len(got) != 0 {
+		t.Errorf("without TODOs, ScanTODOs = %q, %v", keys(got), err)
+	}
+}
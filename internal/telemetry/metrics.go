@@ -0,0 +1,184 @@
+// Package telemetry collects go-work's metrics, exposed in the Prometheus
+// text format, and traces, exported as OTLP JSON over HTTP or to a file.
+//
+// Both are process-wide: the metrics below are always recorded, and spans
+// are discarded until Setup configures an exporter.
+package telemetry
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// The metrics recorded by go-work.
+var (
+	Sessions = newMetric("gowork_sessions", "gauge",
+		"Sessions by current state.", "state")
+	SessionsFinished = newMetric("gowork_sessions_finished_total", "counter",
+		"Sessions that reached a final state, by result.", "result")
+	PhaseDuration = newHistogram("gowork_phase_duration_seconds",
+		"Time spent in each session phase.",
+		[]float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200}, "phase")
+	Tokens = newMetric("gowork_tokens_total", "counter",
+		"Tokens used by claude, by type.", "type")
+	CostUSD = newMetric("gowork_cost_usd_total", "counter",
+		"Cost of claude runs in US dollars, as reported by claude.")
+	ToolCalls = newMetric("gowork_tool_calls_total", "counter",
+		"Tool calls made by claude, by tool.", "tool")
+	CommandErrors = newMetric("gowork_command_errors_total", "counter",
+		"Failed external commands and API requests, by command (git, gh, claude, api).", "command")
+)
+
+var registry = []writer{Sessions, SessionsFinished, PhaseDuration, Tokens, CostUSD, ToolCalls, CommandErrors}
+
+// WriteMetrics writes all metrics in the Prometheus text exposition format.
+func WriteMetrics(w io.Writer) error {
+	for _, m := range registry {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type writer interface {
+	write(w io.Writer) error
+}
+
+// Metric is a counter or gauge with a fixed set of labels.
+type Metric struct {
+	name, kind, help string
+	labels           []string
+
+	mu   sync.Mutex
+	vals map[string]float64 // keyed by joined label values
+}
+
+func newMetric(name, kind, help string, labels ...string) *Metric {
+	return &Metric{name: name, kind: kind, help: help, labels: labels, vals: make(map[string]float64)}
+}
+
+// Add adds v to the series with the given label values.
+func (m *Metric) Add(v float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.vals[joinLabels(labelValues)] += v
+}
+
+// Inc adds one to the series with the given label values.
+func (m *Metric) Inc(labelValues ...string) { m.Add(1, labelValues...) }
+
+// Set sets the series with the given label values, for gauges.
+func (m *Metric) Set(v float64, labelValues ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.vals[joinLabels(labelValues)] = v
+}
+
+func (m *Metric) write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind); err != nil {
+		return err
+	}
+	for _, key := range slices.Sorted(maps.Keys(m.vals)) {
+		if _, err := fmt.Fprintf(w, "%s%s %g\n", m.name, formatLabels(m.labels, key, ""), m.vals[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Histogram counts observations in cumulative buckets per label set.
+type Histogram struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histSeries
+}
+
+type histSeries struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return &Histogram{name: name, help: help, labels: labels, buckets: buckets, series: make(map[string]*histSeries)}
+}
+
+// Observe records v in the series with the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := joinLabels(labelValues)
+	s := h.series[key]
+	if s == nil {
+		s = &histSeries{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i, _ := slices.BinarySearch(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *Histogram) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name); err != nil {
+		return err
+	}
+	for _, key := range slices.Sorted(maps.Keys(h.series)) {
+		s := h.series[key]
+		var cum uint64
+		for i, b := range h.buckets {
+			cum += s.counts[i]
+			le := fmt.Sprintf("le=%q", fmt.Sprint(b))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, le), cum)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, `le="+Inf"`), s.count)
+		fmt.Fprintf(w, "%s_sum%s %g\n", h.name, formatLabels(h.labels, key, ""), s.sum)
+		if _, err := fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, ""), s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// labelSep joins label values into a map key; it cannot occur in values
+// that matter here.
+const labelSep = "\x00"
+
+func joinLabels(values []string) string { return strings.Join(values, labelSep) }
+
+// formatLabels renders {name="value",…} for a series key, with extra
+// appended verbatim.
+func formatLabels(names []string, key, extra string) string {
+	var parts []string
+	if len(names) > 0 {
+		for i, v := range strings.Split(key, labelSep) {
+			if i < len(names) {
+				parts = append(parts, fmt.Sprintf("%s=\"%s\"", names[i], escapeLabel(v)))
+			}
+		}
+	}
+	if extra != "" {
+		parts = append(parts, extra)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
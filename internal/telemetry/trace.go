@@ -0,0 +1,277 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span is one timed operation in a trace. A nil *Span is valid and records
+// nothing, so callers need not check whether tracing is enabled.
+type Span struct {
+	traceID, spanID, parentID string
+	name                      string
+	start, end                time.Time
+	attrs                     map[string]any
+	err                       error
+}
+
+// StartSpan starts a span as a child of the span in ctx, or as the root of a
+// new trace if there is none, and returns a context carrying it. It returns
+// a nil span while tracing is disabled.
+func StartSpan(ctx context.Context, name string, attrs map[string]any) (context.Context, *Span) {
+	if exporter() == nil {
+		return ctx, nil
+	}
+	s := &Span{name: name, start: time.Now(), spanID: randomHex(8), attrs: make(map[string]any)}
+	if parent := SpanFromContext(ctx); parent != nil {
+		s.traceID, s.parentID = parent.traceID, parent.spanID
+	} else {
+		s.traceID = randomHex(16)
+	}
+	for k, v := range attrs {
+		s.attrs[k] = v
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the span carried by ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// SetAttr sets an attribute on the span.
+func (s *Span) SetAttr(key string, value any) {
+	if s != nil {
+		s.attrs[key] = value
+	}
+}
+
+// End finishes the span, marking it failed if err is non-nil, and queues it
+// for export.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.end, s.err = time.Now(), err
+	if e := exporter(); e != nil {
+		e.add(s)
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// TraceSettings configures trace export. With neither field set, tracing
+// is disabled.
+type TraceSettings struct {
+	// OTLPEndpoint is an OTLP/HTTP collector, e.g. http://localhost:4318;
+	// spans are posted as JSON to its /v1/traces.
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+	// OTLPHeaders are sent with every export, e.g. for authentication.
+	// Values may reference environment variables.
+	OTLPHeaders map[string]string `yaml:"otlp_headers"`
+	// File appends each export request as a line of OTLP JSON, for offline
+	// use or later replay into a collector.
+	File string `yaml:"file"`
+	// ServiceName is the service.name resource attribute.
+	ServiceName string `yaml:"service_name"`
+}
+
+var (
+	exportMu sync.Mutex
+	current  *batcher
+)
+
+func exporter() *batcher {
+	exportMu.Lock()
+	defer exportMu.Unlock()
+	return current
+}
+
+// Setup starts exporting spans as configured. The returned function flushes
+// pending spans and stops exporting; it must be called before exit.
+func Setup(s TraceSettings) (shutdown func(), err error) {
+	var sinks []func([]byte) error
+	if s.File != "" {
+		f, err := os.OpenFile(s.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open trace file: %w", err)
+		}
+		var mu sync.Mutex
+		sinks = append(sinks, func(data []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			_, err := f.Write(append(data, '\n'))
+			return err
+		})
+	}
+	if s.OTLPEndpoint != "" {
+		url := strings.TrimRight(s.OTLPEndpoint, "/") + "/v1/traces"
+		client := &http.Client{Timeout: 10 * time.Second}
+		sinks = append(sinks, func(data []byte) error {
+			req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			for k, v := range s.OTLPHeaders {
+				req.Header.Set(k, os.ExpandEnv(v))
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				return fmt.Errorf("export traces: %s", resp.Status)
+			}
+			return nil
+		})
+	}
+	if len(sinks) == 0 {
+		return func() {}, nil
+	}
+
+	service := s.ServiceName
+	if service == "" {
+		service = "go-work"
+	}
+	b := &batcher{service: service, sinks: sinks, flush: make(chan chan struct{})}
+	exportMu.Lock()
+	current = b
+	exportMu.Unlock()
+	go b.loop()
+
+	return func() {
+		exportMu.Lock()
+		current = nil
+		exportMu.Unlock()
+		done := make(chan struct{})
+		b.flush <- done
+		<-done
+	}, nil
+}
+
+// batcher collects finished spans and exports them every few seconds.
+type batcher struct {
+	service string
+	sinks   []func([]byte) error
+	flush   chan chan struct{}
+
+	mu    sync.Mutex
+	spans []*Span
+}
+
+func (b *batcher) add(s *Span) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spans = append(b.spans, s)
+}
+
+func (b *batcher) loop() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.export()
+		case done := <-b.flush:
+			b.export()
+			close(done)
+			return
+		}
+	}
+}
+
+// export sends the pending spans to every sink. Failed exports are counted
+// and dropped; tracing must never hold up sessions.
+func (b *batcher) export() {
+	b.mu.Lock()
+	spans := b.spans
+	b.spans = nil
+	b.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	data, err := json.Marshal(otlpRequest(b.service, spans))
+	if err != nil {
+		return
+	}
+	for _, sink := range b.sinks {
+		if sink(data) != nil {
+			CommandErrors.Inc("trace_export")
+		}
+	}
+}
+
+// otlpRequest builds an OTLP ExportTraceServiceRequest in its JSON mapping.
+func otlpRequest(service string, spans []*Span) map[string]any {
+	out := make([]map[string]any, len(spans))
+	for i, s := range spans {
+		span := map[string]any{
+			"traceId":           s.traceID,
+			"spanId":            s.spanID,
+			"name":              s.name,
+			"kind":              1, // internal
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttrs(s.attrs),
+			"status":            map[string]any{"code": 1}, // ok
+		}
+		if s.parentID != "" {
+			span["parentSpanId"] = s.parentID
+		}
+		if s.err != nil {
+			span["status"] = map[string]any{"code": 2, "message": s.err.Error()}
+		}
+		out[i] = span
+	}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": otlpAttrs(map[string]any{"service.name": service})},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/tomfevang/go-work"},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+func otlpAttrs(attrs map[string]any) []any {
+	out := []any{}
+	for k, v := range attrs {
+		var val map[string]any
+		switch v := v.(type) {
+		case string:
+			val = map[string]any{"stringValue": v}
+		case bool:
+			val = map[string]any{"boolValue": v}
+		case int:
+			val = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			val = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			val = map[string]any{"doubleValue": v}
+		default:
+			val = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, map[string]any{"key": k, "value": val})
+	}
+	return out
+}
@@ -1,60 +1,145 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
-	gh "github.com/tomfevang/go-work/internal/github"
+	"github.com/tomfevang/go-work/internal/api"
+	"github.com/tomfevang/go-work/internal/config"
+	"github.com/tomfevang/go-work/internal/forge"
 	"github.com/tomfevang/go-work/internal/session"
+	"github.com/tomfevang/go-work/internal/workspace"
 )
 
 // appModel is the root model that owns screen transitions.
 type appModel struct {
-	current  tea.Model
-	repoRoot string
-	width    int
-	height   int
+	current tea.Model
+	ws      *workspace.Workspace
+	cfg     *config.Config
+	forge   forge.Forge
+	width   int
+	height  int
+
+	// Once the dashboard runs: its sessions, and the issue selector when
+	// reopened over it to add issues.
+	mgr     *session.Manager
+	adder   tea.Model
+	opening bool // issues are loading for the selector
 }
 
-// issuesLoadedMsg carries the result of the initial issue fetch.
+// issuesLoadedMsg carries the result of an issue fetch. seq identifies the
+// request so the issue selector can ignore superseded pages.
 type issuesLoadedMsg struct {
 	issues []session.Issue
+	local  []session.Issue // local tasks; only set by the initial load
 	err    error
+	seq    int
 }
 
-// New creates and returns the root Bubble Tea program.
-func New(repoRoot string) *tea.Program {
-	m := appModel{repoRoot: repoRoot}
+// New creates and returns the root Bubble Tea program for the workspace,
+// whose forges must have been detected.
+func New(ws *workspace.Workspace) *tea.Program {
+	m := appModel{ws: ws, cfg: ws.Config, forge: ws.Forge}
 	return tea.NewProgram(m, tea.WithAltScreen())
 }
 
-func (m appModel) Init() tea.Cmd {
-	return fetchIssuesCmd()
+// Run runs the TUI for the workspace until the user quits, then releases
+// the sessions' resources.
+func Run(ws *workspace.Workspace) error {
+	final, err := New(ws).Run()
+	if m, ok := final.(appModel); ok && m.mgr != nil {
+		m.mgr.Close()
+	}
+	return err
+}
+
+func (m appModel) Init() tea.Cmd { return m.loadIssues() }
+
+// loadIssues returns a Cmd that loads the local tasks and the first page of
+// issues.
+func (m appModel) loadIssues() tea.Cmd {
+	fetch := fetchIssuesCmd(m.forge, forge.Filter{}, m.cfg.Issues.Limit, 0)
+	return func() tea.Msg {
+		local, err := m.ws.Tasks()
+		if err != nil {
+			return issuesLoadedMsg{err: fmt.Errorf("load local tasks: %w", err)}
+		}
+		msg := fetch().(issuesLoadedMsg)
+		msg.local = local
+		return msg
+	}
 }
 
 func (m appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width, m.height = msg.Width, msg.Height
+		var cmds []tea.Cmd
+		if m.adder != nil {
+			var cmd tea.Cmd
+			m.adder, cmd = m.adder.Update(msg)
+			cmds = append(cmds, cmd)
+		}
 		if m.current != nil {
-			updated, cmd := m.current.Update(msg)
-			m.current = updated
-			return m, cmd
+			var cmd tea.Cmd
+			m.current, cmd = m.current.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+		return m, tea.Batch(cmds...)
+
+	case openIssueAddMsg:
+		if m.mgr == nil || m.adder != nil || m.opening {
+			return m, nil
 		}
+		m.opening = true
+		return m, m.loadIssues()
+
+	case closeIssueAddMsg:
+		m.adder = nil
 		return m, nil
 
 	case issuesLoadedMsg:
+		if m.opening {
+			m.opening = false
+			started := make(map[string]bool)
+			for _, key := range m.mgr.Order() {
+				started[key] = true
+			}
+			sel := newIssueAddModel(m.forge, msg.issues, msg.local, started, m.cfg.Filters, m.cfg.Issues.Limit, m.width, m.height)
+			sel.err = msg.err
+			m.adder = sel
+			return m, sel.Init()
+		}
+		if m.adder != nil {
+			var cmd tea.Cmd
+			m.adder, cmd = m.adder.Update(msg)
+			return m, cmd
+		}
+		if m.current != nil {
+			break // a filter or page reload, handled by the issue selector
+		}
 		if msg.err != nil {
 			fmt.Fprintf(os.Stderr, "error loading issues: %v\n", msg.err)
 			return m, tea.Quit
 		}
-		sel := newIssueSelectModel(msg.issues, m.width, m.height)
+		sel := newIssueSelectModel(m.forge, msg.issues, msg.local, forge.Filter{}, m.cfg.Filters, m.cfg.Issues.Limit, m.width, m.height)
 		m.current = sel
 		return m, sel.Init()
 
 	case startSessionsMsg:
-		return m.startSessions(msg.issues)
+		if m.mgr == nil {
+			return m.startSessions(msg.issues)
+		}
+		m.adder = nil // the dashboard adds them
+
+	case tea.KeyMsg, issueDetailMsg:
+		if m.adder != nil {
+			var cmd tea.Cmd
+			m.adder, cmd = m.adder.Update(msg)
+			return m, cmd
+		}
 	}
 
 	if m.current != nil {
@@ -66,45 +151,54 @@ func (m appModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m appModel) View() string {
+	if m.adder != nil {
+		return m.adder.View()
+	}
 	if m.current == nil {
 		return "Loading issues…\n"
 	}
 	return m.current.View()
 }
 
-// startSessions initialises session state, kicks off goroutines, and switches
-// to the dashboard screen.
+// startSessions hands the issues to a session manager, which starts those
+// without unmet dependencies, and switches to the dashboard screen. The
+// sessions are persisted and served on the repo's socket for the command
+// line, and over HTTP if configured.
 func (m appModel) startSessions(issues []session.Issue) (tea.Model, tea.Cmd) {
-	eventCh := make(chan session.Event, 64)
-	sessions := make(map[int]*session.Session, len(issues))
-	approveChs := make(map[int]chan bool, len(issues))
-	order := make([]int, len(issues))
-
-	for i, iss := range issues {
-		sessions[iss.Number] = &session.Session{
-			Issue: iss,
-			State: session.Pending,
-		}
-		approveChs[iss.Number] = make(chan bool, 1)
-		order[i] = iss.Number
+	mgr := session.NewManager(m.ws.Envs())
+	mgr.EnablePrompts()
+	srv := api.New(m.cfg.Server, m.ws.Resolve)
+	var st *session.Store
+	dir, err := m.ws.StateDir()
+	if err == nil {
+		st, err = session.OpenStore(dir)
 	}
-
-	for _, iss := range issues {
-		iss := iss
-		approveCh := approveChs[iss.Number]
-		sessions[iss.Number].State = session.Planning
-		go session.Run(iss, m.repoRoot, eventCh, approveCh)
+	if err == nil {
+		mgr.SetStore(st)
+		err = srv.StartSocket(context.Background(), st.SocketPath())
+	}
+	mgr.Add(issues)
+
+	dash := newDashboard(mgr, m.cfg.Notify, m.width, m.height)
+	dash.srv = srv
+	dash.err = err // the sessions still run, just without the command line
+	var announce tea.Cmd
+	if m.cfg.Server.Addr != "" {
+		if addr, err := srv.Start(context.Background()); err != nil {
+			dash.err = err
+		} else {
+			announce = dash.announce(addr) // where the web dashboard is
+		}
 	}
-
-	dash := newDashboard(sessions, order, approveChs, eventCh, m.width, m.height)
 	m.current = dash
-	return m, dash.Init()
+	m.mgr = mgr
+	return m, tea.Batch(dash.Init(), announce)
 }
 
-// fetchIssuesCmd returns a Cmd that calls gh issue list.
-func fetchIssuesCmd() tea.Cmd {
+// fetchIssuesCmd returns a Cmd that lists issues from the forge.
+func fetchIssuesCmd(fg forge.Forge, filter forge.Filter, limit, seq int) tea.Cmd {
 	return func() tea.Msg {
-		issues, err := gh.ListIssues()
-		return issuesLoadedMsg{issues: issues, err: err}
+		issues, err := fg.ListIssues(filter, limit)
+		return issuesLoadedMsg{issues: issues, err: err, seq: seq}
 	}
 }
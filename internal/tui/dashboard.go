@@ -2,13 +2,22 @@ package tui
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/tomfevang/go-work/internal/api"
+	"github.com/tomfevang/go-work/internal/config"
+	"github.com/tomfevang/go-work/internal/notify"
+	"github.com/tomfevang/go-work/internal/permission"
 	"github.com/tomfevang/go-work/internal/session"
 )
 
@@ -39,24 +48,41 @@ var (
 	badgeImpl     = lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Render
 	badgeDone     = lipgloss.NewStyle().Foreground(lipgloss.Color("42")).Render
 	badgeFailed   = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render
+	badgeOverlap  = lipgloss.NewStyle().Foreground(lipgloss.Color("208")).Bold(true).Render
+
+	overlapBarStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("208"))
 )
 
-// sessionListItem wraps a session number for the left-pane list.
+// sessionListItem wraps a session key for the left-pane list.
 type sessionListItem struct {
-	issueNum int
-	title    string
-	state    session.State
+	key     string
+	ref     string
+	title   string
+	state   session.State
+	overlap bool
+	marked  bool // selected for a batch PR
+	prompt  bool // asking permission to use a tool
 }
 
 func (s sessionListItem) Title() string {
-	badge := renderBadge(s.state)
-	return fmt.Sprintf("%s #%d", badge, s.issueNum)
+	title := fmt.Sprintf("%s %s", renderBadge(s.state), s.ref)
+	if s.marked {
+		title = "◉ " + title
+	}
+	if s.overlap {
+		title += " " + badgeOverlap("⚠")
+	}
+	if s.prompt {
+		title += " " + badgeApprove("?")
+	}
+	return title
 }
 func (s sessionListItem) Description() string {
 	return truncate(s.title, leftPaneWidth-4)
 }
 func (s sessionListItem) FilterValue() string {
-	return fmt.Sprintf("#%d %s", s.issueNum, s.title)
+	return fmt.Sprintf("%s %s", s.ref, s.title)
 }
 
 func renderBadge(state session.State) string {
@@ -75,6 +101,12 @@ func renderBadge(state session.State) string {
 		return badgeDone("✓")
 	case session.Failed:
 		return badgeFailed("✗")
+	case session.Queued:
+		return badgePending("◷")
+	case session.Merged:
+		return badgePending("⇢")
+	case session.Blocked:
+		return badgeFailed("⚠")
 	default:
 		return "?"
 	}
@@ -82,29 +114,69 @@ func renderBadge(state session.State) string {
 
 // dashboardModel is the main screen after sessions are started.
 type dashboardModel struct {
-	sessions    map[int]*session.Session // keyed by issue number
-	order       []int                    // issue numbers in display order
-	approveChs  map[int]chan bool
-	eventCh     chan session.Event
+	mgr         *session.Manager
 	list        list.Model
 	viewport    viewport.Model
 	renderer    *glamour.TermRenderer
 	width       int
 	height      int
-	focusedPane int // 0 = list, 1 = viewport
+	focusedPane int             // 0 = list, 1 = viewport
+	err         error           // from the last serialize, merge or batch
+	marked      map[string]bool // finished sessions selected for a batch PR
+	srv         *api.Server     // API for the command line and, if enabled, HTTP
+	notifier    *notify.Notifier
+	notifyErrs  chan error // failed notification deliveries
+
+	// A follow-up message being typed for the session followKey.
+	followInput textinput.Model
+	followKey   string
+
+	// A search being typed, and the search whose matches are highlighted.
+	searchInput  textinput.Model
+	typingSearch bool
+	searchGlobal bool // of all sessions' logs
+	search       *paneSearch
+
+	// The right pane shows one tab of the selected session; shown is what
+	// the viewport holds. Diffs and PR statuses are loaded in the
+	// background, by session key.
+	tab      paneTab
+	shown    paneKey
+	scrolls  map[paneKey]*paneScroll
+	diffs    map[string]fetched
+	prStatus map[string]fetched
+	logViews map[string]*logView
+
+	// Session events refresh the viewport at most once per renderInterval:
+	// dirty marks a refresh due at the next renderTickMsg, which ticking
+	// tells is on its way.
+	dirty   bool
+	ticking bool
+}
+
+// renderInterval is how often session output is brought into view.
+const renderInterval = 100 * time.Millisecond
+
+// renderTickMsg brings the viewport up to date with session events.
+type renderTickMsg struct{}
+
+// notifyErrMsg reports a failed notification delivery.
+type notifyErrMsg struct{ err error }
+
+// planEditedMsg reports that the editor opened on a plan exited.
+type planEditedMsg struct {
+	key, old, path string
+	err            error
 }
 
-func newDashboard(
-	sessions map[int]*session.Session,
-	order []int,
-	approveChs map[int]chan bool,
-	eventCh chan session.Event,
-	width, height int,
-) dashboardModel {
+// apiCallMsg carries a request from the HTTP API to run on the Manager.
+type apiCallMsg api.Call
+
+func newDashboard(mgr *session.Manager, cfg config.Notify, width, height int) dashboardModel {
+	order := mgr.Order()
 	items := make([]list.Item, len(order))
-	for i, num := range order {
-		s := sessions[num]
-		items[i] = sessionListItem{issueNum: num, title: s.Issue.Title, state: s.State}
+	for i, key := range order {
+		items[i] = newSessionListItem(mgr, key, false)
 	}
 
 	l := list.New(items, list.NewDefaultDelegate(), leftPaneWidth, height-2)
@@ -117,21 +189,48 @@ func newDashboard(
 	if vpWidth < 10 {
 		vpWidth = 10
 	}
-	vp := viewport.New(vpWidth, height-4)
+	vp := viewport.New(vpWidth, height-5)
 	vp.SetContent("")
 
 	renderer := newRenderer(vpWidth)
 
+	notifyErrs := make(chan error, 8)
+	notifier := notify.New(cfg, os.Stderr, func(err error) {
+		select {
+		case notifyErrs <- err:
+		default: // already showing enough errors
+		}
+	})
+	for _, key := range order {
+		notifier.Observe(mgr.Get(key))
+	}
+
+	fi := textinput.New()
+	fi.Prompt = "follow-up> "
+	fi.Placeholder = "tell the agent what to change"
+	fi.CharLimit = 0
+
+	si := textinput.New()
+	si.Placeholder = "regular expression"
+	si.CharLimit = 0
+
 	return dashboardModel{
-		sessions:   sessions,
-		order:      order,
-		approveChs: approveChs,
-		eventCh:    eventCh,
-		list:       l,
-		viewport:   vp,
-		renderer:   renderer,
-		width:      width,
-		height:     height,
+		mgr:         mgr,
+		notifier:    notifier,
+		notifyErrs:  notifyErrs,
+		list:        l,
+		viewport:    vp,
+		renderer:    renderer,
+		width:       width,
+		height:      height,
+		marked:      make(map[string]bool),
+		followInput: fi,
+		searchInput: si,
+		tab:         tabLog,
+		scrolls:     make(map[paneKey]*paneScroll),
+		diffs:       make(map[string]fetched),
+		prStatus:    make(map[string]fetched),
+		logViews:    make(map[string]*logView),
 	}
 }
 
@@ -148,7 +247,16 @@ func newRenderer(width int) *glamour.TermRenderer {
 }
 
 func (m dashboardModel) Init() tea.Cmd {
-	return waitForEvent(m.eventCh)
+	cmds := []tea.Cmd{waitForEvent(m.mgr.Events()), waitForNotifyErr(m.notifyErrs)}
+	if m.srv != nil {
+		cmds = append(cmds, waitForCall(m.srv.Calls()))
+	}
+	return tea.Batch(cmds...)
+}
+
+// announce shows text next to the list's title.
+func (m *dashboardModel) announce(text string) tea.Cmd {
+	return m.list.NewStatusMessage(statusStyle.Render(text))
 }
 
 func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -163,75 +271,268 @@ func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.list.SetSize(leftPaneWidth, msg.Height-2)
 		m.viewport.Width = vpWidth
-		m.viewport.Height = msg.Height - 4
+		m.viewport.Height = msg.Height - 5
 		m.renderer = newRenderer(vpWidth)
 		m.refreshViewport()
 		return m, nil
 
-	case session.Event:
-		s, ok := m.sessions[msg.IssueNumber]
-		if !ok {
-			return m, waitForEvent(m.eventCh)
+	case notifyErrMsg:
+		m.err = msg.err
+		return m, waitForNotifyErr(m.notifyErrs)
+
+	case planEditedMsg:
+		defer os.Remove(msg.path)
+		if msg.err != nil {
+			m.err = fmt.Errorf("editor: %w", msg.err)
+			return m, nil
 		}
+		edited, err := os.ReadFile(msg.path)
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.err = m.mgr.EditPlan(msg.key, msg.old, string(edited))
+		m.syncAll() // planned files, and so overlaps, may have changed
+		m.refreshViewport()
+		return m, nil
 
-		switch msg.Type {
-		case session.EventOutput:
-			s.Log += msg.Text
-		case session.EventPlanDone:
-			s.Plan = msg.Text
-			s.State = session.WaitingApproval
-		case session.EventImplDone:
-			s.State = session.CreatingPR
-		case session.EventPRDone:
-			s.PR = msg.Text
-			s.State = session.Done
-			s.Log += "\n✓ PR: " + msg.Text + "\n"
-		case session.EventError:
-			s.Err = fmt.Errorf("%s", msg.Text)
-			s.State = session.Failed
-			s.Log += "\n✗ Error: " + msg.Text + "\n"
+	case diffMsg:
+		m.diffs[msg.key] = msg.fetched
+		m.refreshViewport()
+		return m, nil
+
+	case prStatusMsg:
+		m.prStatus[msg.key] = msg.fetched
+		m.refreshViewport()
+		return m, nil
+
+	case renderTickMsg:
+		m.ticking = false
+		if m.dirty {
+			m.refreshViewport()
 		}
+		return m, nil
 
-		m.syncListItem(msg.IssueNumber)
+	case startSessionsMsg:
+		// Issues added from the issue selector reopened over the dashboard.
+		n := len(m.mgr.Order())
+		m.mgr.Add(msg.issues)
+		m.syncAll()
+		if len(m.mgr.Order()) > n {
+			m.list.Select(n)
+		}
 		m.refreshViewport()
-		cmds = append(cmds, waitForEvent(m.eventCh))
+		return m, m.fetchTab()
+
+	case apiCallMsg:
+		api.Call(msg).Run(m.mgr)
+		m.syncAll()
+		m.refreshViewport()
+		return m, waitForCall(m.srv.Calls())
+
+	case session.Event:
+		selected, _ := m.selectedKey()
+		var prev session.State
+		if s := m.mgr.Get(msg.Key); s != nil {
+			prev = s.State
+		}
+		changed := m.mgr.Handle(msg)
+		if m.srv != nil {
+			m.srv.Publish(msg)
+		}
+		if msg.Type == session.EventPlannedFiles || msg.Type == session.EventFilesChanged {
+			m.syncAll() // overlaps may have appeared anywhere
+		} else {
+			for _, key := range changed {
+				m.syncListItem(key)
+			}
+		}
+		if msg.Key == selected || slices.Contains(changed, selected) ||
+			msg.Type == session.EventPlannedFiles || msg.Type == session.EventFilesChanged {
+			cmds = append(cmds, m.refreshSoon())
+		}
+		if msg.Key == selected {
+			// Bring up what needs the user's decision.
+			switch state := m.mgr.Get(selected).State; {
+			case state == prev:
+			case state == session.WaitingApproval:
+				m.tab = tabPlan
+			case state == session.Blocked:
+				m.tab = tabPR
+			}
+			switch {
+			case m.tab == tabDiff && (msg.Type == session.EventFilesChanged || msg.Type == session.EventImplDone || msg.Type == session.EventFinished),
+				m.tab == tabPR && (msg.Type == session.EventPRDone || prev != m.mgr.Get(selected).State):
+				cmds = append(cmds, m.fetchTab())
+			}
+		}
+		cmds = append(cmds, waitForEvent(m.mgr.Events()))
 
 	case tea.KeyMsg:
+		if m.followKey != "" {
+			return m.updateFollowInput(msg)
+		}
+		if m.typingSearch {
+			return m.updateSearchInput(msg)
+		}
 		switch msg.String() {
 		case "q", "ctrl+c":
 			return m, tea.Quit
 
+		case "+":
+			// Reopen the issue selector to add sessions.
+			return m, func() tea.Msg { return openIssueAddMsg{} }
+
+		case "/", "F":
+			// Search the shown tab, or all sessions' logs.
+			if _, ok := m.selectedKey(); ok {
+				return m, m.openSearch(msg.String() == "F")
+			}
+			return m, nil
+
+		case "n", "N":
+			// Go to the next or previous match of the search.
+			if m.search != nil {
+				dir := 1
+				if msg.String() == "N" {
+					dir = -1
+				}
+				return m, m.nextMatch(dir)
+			}
+			return m, nil
+
+		case "esc":
+			if m.search != nil {
+				m.search, m.err = nil, nil
+				m.refreshViewport()
+			}
+			return m, nil
+
+		case "up", "down", "k", "j":
+			m.err = nil
+
 		case "tab":
 			m.focusedPane = 1 - m.focusedPane
 			return m, nil
 
+		case "1", "2", "3", "4", "5", "6":
+			// Show a tab of the selected session; again to reload it.
+			return m, m.showTab(paneTab(msg.String()[0] - '1'))
+
+		case "[", "]":
+			step := paneTab(1)
+			if msg.String() == "[" {
+				step = numTabs - 1
+			}
+			return m, m.showTab((m.tab + step) % numTabs)
+
+		case "t":
+			// Toggle following the end of the current tab.
+			if _, ok := m.selectedKey(); ok {
+				st := m.scrollOf(m.shown)
+				st.follow = !st.follow
+				st.offset = m.viewport.YOffset
+				m.refreshViewport()
+			}
+			return m, nil
+
 		case "y":
-			// Approve the plan for the selected session.
-			if num, ok := m.selectedIssueNum(); ok {
-				s := m.sessions[num]
-				if s.State == session.WaitingApproval {
-					s.State = session.Implementing
-					m.syncListItem(num)
-					if ch, ok := m.approveChs[num]; ok {
-						ch <- true
-					}
-				}
+			// Approve the plan, or the blocked push, of the selected session.
+			if key, ok := m.selectedKey(); ok && m.mgr.Approve(key, true) {
+				m.syncAll()
 			}
 			return m, nil
 
 		case "r":
-			// Reject the plan for the selected session.
-			if num, ok := m.selectedIssueNum(); ok {
-				s := m.sessions[num]
-				if s.State == session.WaitingApproval {
-					s.State = session.Failed
-					s.Log += "\n✗ Plan rejected by user.\n"
-					m.syncListItem(num)
-					if ch, ok := m.approveChs[num]; ok {
-						ch <- false
-					}
+			// Reject the plan, or the blocked push, of the selected session.
+			if key, ok := m.selectedKey(); ok && m.mgr.Approve(key, false) {
+				m.syncAll()
+				m.refreshViewport()
+			}
+			return m, nil
+
+		case "a", "A", "d":
+			// Answer the selected session's permission prompt: allow once,
+			// always allow the tool, or deny.
+			d := map[string]permission.Decision{"a": permission.Allow, "A": permission.AlwaysAllow, "d": permission.Deny}[msg.String()]
+			if key, ok := m.selectedKey(); ok && m.mgr.Permit(key, d) {
+				m.syncListItem(key)
+				m.refreshViewport()
+			}
+			return m, nil
+
+		case "e":
+			// Edit the selected session's plan before approving it.
+			if key, ok := m.selectedKey(); ok && m.mgr.Get(key).State == session.WaitingApproval {
+				cmd, err := editPlan(key, m.mgr.Get(key).Plan)
+				m.err = err
+				return m, cmd
+			}
+			return m, nil
+
+		case "f":
+			// Type a follow-up message for the selected session's agent.
+			if key, ok := m.selectedKey(); ok {
+				m.err = nil
+				m.followKey = key
+				m.followInput.SetValue("")
+				m.followInput.Width = max(10, m.width-leftPaneWidth-20)
+				return m, m.followInput.Focus()
+			}
+			return m, nil
+
+		case " ":
+			// Mark a finished session for a batch PR.
+			if key, ok := m.selectedKey(); ok && m.mgr.Get(key).Batchable() {
+				m.marked[key] = !m.marked[key]
+				m.syncListItem(key)
+			}
+			return m, nil
+
+		case "b":
+			// Open one PR for the marked sessions, or the selected one.
+			var keys []string
+			for k, on := range m.marked {
+				if on {
+					keys = append(keys, k)
 				}
 			}
+			if len(keys) == 0 {
+				if key, ok := m.selectedKey(); ok {
+					keys = []string{key}
+				}
+			}
+			if len(keys) == 0 {
+				return m, nil
+			}
+			key, err := m.mgr.Batch(keys)
+			m.err = err
+			if err != nil {
+				return m, nil
+			}
+			clear(m.marked)
+			for _, k := range keys {
+				m.syncListItem(k)
+			}
+			m.list.InsertItem(len(m.mgr.Order())-1, newSessionListItem(m.mgr, key, false))
+			m.list.Select(len(m.mgr.Order()) - 1)
+			m.refreshViewport()
+			return m, nil
+
+		case "s", "m":
+			// Serialize the selected session after, or merge it into, the
+			// session it overlaps with.
+			key, ok := m.selectedKey()
+			if !ok || len(m.mgr.Overlaps(key)) == 0 {
+				return m, nil
+			}
+			act := m.mgr.Serialize
+			if msg.String() == "m" {
+				act = m.mgr.Merge
+			}
+			_, m.err = act(key)
+			m.syncAll()
+			m.refreshViewport()
 			return m, nil
 		}
 
@@ -242,18 +543,79 @@ func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.list, cmd = m.list.Update(msg)
 			if m.list.Index() != prev {
 				m.refreshViewport()
+				cmds = append(cmds, m.fetchTab())
 			}
 			cmds = append(cmds, cmd)
 		} else {
 			var cmd tea.Cmd
 			m.viewport, cmd = m.viewport.Update(msg)
 			cmds = append(cmds, cmd)
+			// Scrolling up stops following the end.
+			st := m.scrollOf(m.shown)
+			st.offset = m.viewport.YOffset
+			if !m.viewport.AtBottom() {
+				st.follow = false
+			}
 		}
 	}
 
 	return m, tea.Batch(cmds...)
 }
 
+// editPlan opens plan in $VISUAL or $EDITOR, falling back to vi, and
+// reports the edit with a planEditedMsg.
+func editPlan(key, plan string) (tea.Cmd, error) {
+	f, err := os.CreateTemp("", "go-work-plan-*.md")
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.WriteString(plan)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	args := strings.Fields(editor) // may carry flags, as in "code -w"
+	if len(args) == 0 {
+		args = []string{"vi"}
+	}
+	cmd := exec.Command(args[0], append(args[1:], f.Name())...)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		return planEditedMsg{key: key, old: plan, path: f.Name(), err: err}
+	}), nil
+}
+
+// updateFollowInput handles keys while a follow-up message is being typed.
+func (m dashboardModel) updateFollowInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		key := m.followKey
+		m.followKey = ""
+		m.followInput.Blur()
+		if text := strings.TrimSpace(m.followInput.Value()); text != "" {
+			m.err = m.mgr.FollowUp(key, text)
+			m.syncListItem(key)
+			m.refreshViewport()
+		}
+		return m, nil
+	case "esc":
+		m.followKey = ""
+		m.followInput.Blur()
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	var cmd tea.Cmd
+	m.followInput, cmd = m.followInput.Update(msg)
+	return m, cmd
+}
+
 func (m dashboardModel) View() string {
 	// Left pane.
 	left := sessionListStyle.
@@ -261,92 +623,218 @@ func (m dashboardModel) View() string {
 		Height(m.height - 2).
 		Render(m.list.View())
 
-	// Right pane: viewport + footer.
+	// Right pane: tabs, viewport and footer.
 	vpContent := m.viewport.View()
 	footer := m.footerView()
 	right := viewportStyle.
 		Width(m.width - leftPaneWidth - 6).
 		Height(m.height - 2).
-		Render(vpContent + "\n" + footer)
+		Render(m.tabBar() + "\n" + vpContent + "\n" + footer)
 
 	return lipgloss.JoinHorizontal(lipgloss.Top, left, right)
 }
 
 func (m dashboardModel) footerView() string {
-	num, ok := m.selectedIssueNum()
+	key, ok := m.selectedKey()
 	if !ok {
 		return ""
 	}
-	s := m.sessions[num]
+	if m.followKey != "" {
+		return m.followInput.View()
+	}
+	if m.typingSearch {
+		return m.searchInput.View()
+	}
+	if m.search != nil {
+		return m.searchLine() + "\n" + m.footerStatus(key)
+	}
+	return m.footerStatus(key)
+}
+
+// footerStatus shows the error of the last action, or what the selected
+// session needs.
+func (m dashboardModel) footerStatus(key string) string {
+	if m.err != nil {
+		return errorStyle.Render(truncate(m.err.Error(), m.width-leftPaneWidth-8))
+	}
+	s := m.mgr.Get(key)
+	status := m.statusLine(s)
+	if s.Prompt != "" {
+		line := approveBarStyle.Render(truncate("? "+s.Prompt, m.width-leftPaneWidth-8))
+		return line + "\n" + approveBarStyle.Render("  [a] Allow    [A] Always allow    [d] Deny  ")
+	}
+	if overlaps := m.mgr.Overlaps(key); len(overlaps) > 0 {
+		o := overlaps[0]
+		line := fmt.Sprintf("⚠ Overlaps %s on %s", m.mgr.Get(o.Key).Issue.Ref(), strings.Join(o.Files, ", "))
+		line = overlapBarStyle.Render(truncate(line, m.width-leftPaneWidth-8))
+		return line + "\n" + status + statusStyle.Render("  [s] Serialize after it  [m] Merge into it")
+	}
+	return status
+}
+
+// statusLine describes the state of a session for the footer.
+func (m dashboardModel) statusLine(s *session.Session) string {
+	key := s.Issue.Key()
 	switch s.State {
+	case session.Pending, session.Queued:
+		if waiting := m.mgr.Waiting(key); len(waiting) > 0 {
+			return statusStyle.Render("Waiting for " + strings.Join(waiting, ", ") + "…")
+		}
+		return statusStyle.Render(s.State.String() + "…")
+	case session.Planning, session.Implementing:
+		if n := len(s.FollowUps); n > 0 {
+			return statusStyle.Render(fmt.Sprintf("%s… %d follow-up(s) queued", s.State, n))
+		}
+		return statusStyle.Render(s.State.String() + "…  [f] Follow up")
 	case session.WaitingApproval:
-		return approveBarStyle.Render("  [y] Approve plan    [r] Reject  ")
+		return approveBarStyle.Render("  [y] Approve plan    [e] Edit    [r] Reject    [f] Follow up  ")
+	case session.Blocked:
+		return approveBarStyle.Render("  Checks failed: [y] Push anyway    [r] Reject  ")
 	case session.Done:
+		if s.Batchable() {
+			return doneBarStyle.Render("✓ Committed on branch "+s.Branch) +
+				statusStyle.Render("  [space] Mark  [b] Batch PR")
+		}
+		if s.PR == "" && s.BatchedInto != "" {
+			return statusStyle.Render("Batching into " + m.mgr.Get(s.BatchedInto).Issue.Ref() + "…")
+		}
+		if s.Branch != "" && s.PR == "" {
+			return doneBarStyle.Render("✓ Committed on branch " + s.Branch)
+		}
 		pr := s.PR
 		if pr == "" {
 			pr = "PR created"
 		}
 		return doneBarStyle.Render("✓ " + pr)
+	case session.Merged:
+		return statusStyle.Render("Merged into " + m.mgr.Get(s.MergedInto).Issue.Ref())
 	default:
 		return statusStyle.Render(s.State.String() + "…")
 	}
 }
 
-func (m *dashboardModel) syncListItem(issueNum int) {
-	for i, num := range m.order {
-		if num == issueNum {
-			s := m.sessions[num]
-			m.list.SetItem(i, sessionListItem{
-				issueNum: num,
-				title:    s.Issue.Title,
-				state:    s.State,
-			})
+func newSessionListItem(mgr *session.Manager, key string, marked bool) sessionListItem {
+	s := mgr.Get(key)
+	return sessionListItem{
+		key:     key,
+		ref:     s.Issue.Ref(),
+		title:   s.Issue.Title,
+		state:   s.State,
+		overlap: len(mgr.Overlaps(key)) > 0,
+		marked:  marked,
+		prompt:  s.Prompt != "",
+	}
+}
+
+// syncListItem updates a session's list item after it changed. Every state
+// change passes through here or syncAll, so it is also where notifications
+// are fired.
+func (m *dashboardModel) syncListItem(key string) {
+	m.notifier.Observe(m.mgr.Get(key))
+	for i, k := range m.mgr.Order() {
+		if k == key {
+			m.list.SetItem(i, newSessionListItem(m.mgr, k, m.marked[k]))
 			return
 		}
 	}
 }
 
+// syncAll refreshes every list item, appending sessions added since, e.g.
+// through the API.
+func (m *dashboardModel) syncAll() {
+	for i, k := range m.mgr.Order() {
+		m.notifier.Observe(m.mgr.Get(k))
+		item := newSessionListItem(m.mgr, k, m.marked[k])
+		if i < len(m.list.Items()) {
+			m.list.SetItem(i, item)
+		} else {
+			m.list.InsertItem(i, item)
+		}
+	}
+}
+
+// refreshSoon refreshes the viewport at the next render tick, so a burst of
+// output is rendered once.
+func (m *dashboardModel) refreshSoon() tea.Cmd {
+	m.dirty = true
+	if m.ticking {
+		return nil
+	}
+	m.ticking = true
+	return tea.Tick(renderInterval, func(time.Time) tea.Msg { return renderTickMsg{} })
+}
+
 func (m *dashboardModel) refreshViewport() {
-	num, ok := m.selectedIssueNum()
+	m.dirty = false
+	key, ok := m.selectedKey()
 	if !ok {
 		m.viewport.SetContent("")
 		return
 	}
-	s := m.sessions[num]
+	s := m.mgr.Get(key)
 
-	var raw string
-	if s.State == session.WaitingApproval && s.Plan != "" {
-		raw = "## Plan\n\n" + s.Plan
-	} else {
-		raw = s.Log
+	// The tab bar takes a line, and the footer an extra one to report
+	// overlapping files or a permission prompt, and one for a search.
+	m.viewport.Height = m.height - 5
+	if s.Prompt != "" || len(m.mgr.Overlaps(key)) > 0 {
+		m.viewport.Height--
+	}
+	if m.search != nil {
+		m.viewport.Height--
 	}
 
-	content := m.renderMarkdown(raw)
+	pk := paneKey{key, m.tab}
+	content := m.tabContent(s)
+	if m.search != nil {
+		if pk != m.shown {
+			m.search.cur = -1
+		}
+		content = m.search.mark(content)
+	}
 	m.viewport.SetContent(content)
-	m.viewport.GotoBottom()
+	if st := m.scrollOf(pk); st.follow {
+		m.viewport.GotoBottom()
+	} else {
+		m.viewport.SetYOffset(st.offset)
+	}
+	m.shown = pk
 }
 
-func (m *dashboardModel) renderMarkdown(src string) string {
-	if m.renderer == nil || src == "" {
+func renderMarkdown(r *glamour.TermRenderer, src string) string {
+	if r == nil || src == "" {
 		return src
 	}
-	out, err := m.renderer.Render(src)
+	out, err := r.Render(src)
 	if err != nil {
 		return src
 	}
 	return strings.TrimRight(out, "\n")
 }
 
-func (m dashboardModel) selectedIssueNum() (int, bool) {
+func (m dashboardModel) selectedKey() (string, bool) {
 	item, ok := m.list.SelectedItem().(sessionListItem)
 	if !ok {
-		return 0, false
+		return "", false
+	}
+	return item.key, true
+}
+
+// waitForCall returns a Cmd that blocks until the next API call arrives.
+func waitForCall(ch <-chan api.Call) tea.Cmd {
+	return func() tea.Msg {
+		return apiCallMsg(<-ch)
+	}
+}
+
+// waitForNotifyErr returns a Cmd that blocks until a notification fails.
+func waitForNotifyErr(ch <-chan error) tea.Cmd {
+	return func() tea.Msg {
+		return notifyErrMsg{<-ch}
 	}
-	return item.issueNum, true
 }
 
 // waitForEvent returns a Cmd that blocks until the next Event arrives.
-func waitForEvent(ch chan session.Event) tea.Cmd {
+func waitForEvent(ch <-chan session.Event) tea.Cmd {
 	return func() tea.Msg {
 		return <-ch
 	}
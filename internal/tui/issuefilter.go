@@ -0,0 +1,94 @@
+package tui
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/tomfevang/go-work/internal/forge"
+)
+
+// parseFilter turns a query such as
+//
+//	label:bug label:"good first issue" assignee:@me crash on start
+//
+// into a server-side filter. Recognised qualifiers are label, assignee,
+// milestone and author; everything else becomes the free-text search.
+func parseFilter(query string) forge.Filter {
+	var f forge.Filter
+	var search []string
+	for _, tok := range splitQuery(query) {
+		key, val, ok := strings.Cut(tok, ":")
+		if !ok || val == "" {
+			search = append(search, tok)
+			continue
+		}
+		switch key {
+		case "label":
+			f.Labels = append(f.Labels, val)
+		case "assignee":
+			f.Assignee = val
+		case "milestone":
+			f.Milestone = val
+		case "author":
+			f.Author = val
+		default:
+			search = append(search, tok)
+		}
+	}
+	f.Search = strings.Join(search, " ")
+	return f
+}
+
+// formatFilter is the inverse of parseFilter.
+func formatFilter(f forge.Filter) string {
+	var parts []string
+	for _, l := range f.Labels {
+		parts = append(parts, "label:"+quoteIfSpaced(l))
+	}
+	if f.Assignee != "" {
+		parts = append(parts, "assignee:"+quoteIfSpaced(f.Assignee))
+	}
+	if f.Milestone != "" {
+		parts = append(parts, "milestone:"+quoteIfSpaced(f.Milestone))
+	}
+	if f.Author != "" {
+		parts = append(parts, "author:"+quoteIfSpaced(f.Author))
+	}
+	if f.Search != "" {
+		parts = append(parts, f.Search)
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitQuery splits on whitespace, keeping double-quoted runs together and
+// dropping the quotes.
+func splitQuery(s string) []string {
+	var toks []string
+	var cur strings.Builder
+	inQuote := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case unicode.IsSpace(r) && !inQuote:
+			if cur.Len() > 0 {
+				toks = append(toks, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		toks = append(toks, cur.String())
+	}
+	return toks
+}
+
+func quoteIfSpaced(s string) string {
+	if strings.ContainsFunc(s, unicode.IsSpace) {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -3,12 +3,19 @@ package tui
 import (
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/tomfevang/go-work/internal/config"
+	"github.com/tomfevang/go-work/internal/forge"
 	"github.com/tomfevang/go-work/internal/session"
+	"github.com/tomfevang/go-work/internal/tasks"
 )
 
 // issueItem wraps session.Issue so it satisfies the list.Item interface.
@@ -19,7 +26,7 @@ type issueItem struct {
 
 func (i issueItem) Title() string       { return i.issue.Title }
 func (i issueItem) Description() string { return truncate(i.issue.Body, 120) }
-func (i issueItem) FilterValue() string { return fmt.Sprintf("#%d %s", i.issue.Number, i.issue.Title) }
+func (i issueItem) FilterValue() string { return i.issue.Ref() + " " + i.issue.Title }
 
 // issueDelegate is a custom list delegate that renders each issue with a
 // visible cursor, issue number, title, body preview and selection mark.
@@ -36,8 +43,8 @@ var (
 	checkEmpty    = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
 )
 
-func (d issueDelegate) Height() int  { return 3 }
-func (d issueDelegate) Spacing() int { return 0 }
+func (d issueDelegate) Height() int                             { return 3 }
+func (d issueDelegate) Spacing() int                            { return 0 }
 func (d issueDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
 
 func (d issueDelegate) Render(w io.Writer, m list.Model, index int, item list.Item) {
@@ -61,8 +68,8 @@ func (d issueDelegate) Render(w io.Writer, m list.Model, index int, item list.It
 		check = checkSelected.Render("✓ ")
 	}
 
-	// Number
-	num := numberStyle.Render(fmt.Sprintf("#%-4d", iss.issue.Number))
+	// Reference: "#12" or a local key such as "task-3"
+	num := numberStyle.Render(fmt.Sprintf("%-5s", iss.issue.Ref()))
 
 	// Title
 	titleText := truncate(iss.issue.Title, width-6)
@@ -73,8 +80,12 @@ func (d issueDelegate) Render(w io.Writer, m list.Model, index int, item list.It
 		title = titleNormal.Render(titleText)
 	}
 
-	// Body preview
-	bodyText := truncate(iss.issue.Body, width-6)
+	// Labels and body preview
+	bodyText := iss.issue.Body
+	if len(iss.issue.Labels) > 0 {
+		bodyText = "[" + strings.Join(iss.issue.LabelNames(), "] [") + "] " + bodyText
+	}
+	bodyText = truncate(bodyText, width-6)
 	var desc string
 	if focused {
 		desc = descFocused.Render(bodyText)
@@ -91,45 +102,124 @@ func (d issueDelegate) Render(w io.Writer, m list.Model, index int, item list.It
 	fmt.Fprint(w, line3)
 }
 
-// issueSelectModel is the first screen: browse and multi-select GitHub issues.
+// issueSelectModel is the first screen: browse and multi-select issues.
 type issueSelectModel struct {
+	forge    forge.Forge
 	list     list.Model
 	issues   []session.Issue
-	selected map[int]bool
+	selected map[string]session.Issue // by Issue.Key; survives filter changes
 	width    int
 	height   int
+
+	// Server-side filtering and paging.
+	filter      forge.Filter
+	presets     []config.FilterPreset
+	presetIdx   int // index into presets, -1 when the filter was typed
+	pageSize    int // issues.limit: added to limit at the end of the list
+	limit       int
+	hasMore     bool
+	loading     bool
+	seq         int // bumped per request so stale pages are dropped
+	err         error
+	filterInput textinput.Model
+	editing     bool
+
+	// Local tasks and ad hoc prompts, listed above the forge issues.
+	local       []session.Issue
+	promptInput textinput.Model
+	prompting   bool
+
+	// Issue preview pane, filled from GetIssue on demand.
+	preview    bool
+	previewVP  viewport.Model
+	previewKey string
+	details    map[string]session.Issue
+	renderer   *glamour.TermRenderer
+
+	// Set when the selector is reopened over the dashboard to add issues:
+	// the keys of issues that already have sessions, which are not listed.
+	started map[string]bool
 }
 
 var (
 	titleStyle  = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205"))
 	statusStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
 	errorStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
-)
 
-func newIssueSelectModel(issues []session.Issue, width, height int) issueSelectModel {
-	items := make([]list.Item, len(issues))
-	for i, iss := range issues {
-		items[i] = issueItem{issue: iss}
-	}
+	previewStyle = lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("62"))
+)
 
-	listHeight := height - 3
-	if listHeight < 1 {
-		listHeight = 1
-	}
+// issueDetailMsg carries the result of fetching one issue's full details.
+type issueDetailMsg struct {
+	issue session.Issue
+	err   error
+}
 
-	l := list.New(items, issueDelegate{}, width, listHeight)
-	l.Title = "Select issues  —  space: toggle  enter: start  /: filter  q: quit"
+func newIssueSelectModel(fg forge.Forge, issues, local []session.Issue, filter forge.Filter, presets []config.FilterPreset, pageSize, width, height int) issueSelectModel {
+	l := list.New(nil, issueDelegate{}, 0, 0) // sized by resize
+	l.Title = "Select issues  —  space: toggle  enter: start  /: search  f: filter  p: preset  a: ad hoc  v: preview  q: quit"
 	l.SetShowStatusBar(true)
 	l.SetFilteringEnabled(true)
 	l.Styles.Title = titleStyle
 
-	return issueSelectModel{
-		list:     l,
-		issues:   issues,
-		selected: make(map[int]bool),
-		width:    width,
-		height:   height,
+	fi := textinput.New()
+	fi.Prompt = "filter> "
+	fi.Placeholder = `label:bug assignee:@me milestone:"v1.0" author:octocat free text`
+
+	pi := textinput.New()
+	pi.Prompt = "prompt> "
+	pi.Placeholder = "describe the change you want"
+	pi.CharLimit = 0
+
+	m := issueSelectModel{
+		forge:       fg,
+		list:        l,
+		selected:    make(map[string]session.Issue),
+		local:       local,
+		promptInput: pi,
+		width:       width,
+		height:      height,
+		filter:      filter,
+		presets:     presets,
+		presetIdx:   -1,
+		pageSize:    pageSize,
+		limit:       pageSize,
+		filterInput: fi,
+		details:     make(map[string]session.Issue),
+		previewVP:   viewport.New(0, 0),
+	}
+	m.setIssues(issues)
+	m.resize()
+	return m
+}
+
+// resize lays out the list and, when open, the preview pane side by side.
+func (m *issueSelectModel) resize() {
+	listHeight := m.height - 3
+	if listHeight < 1 {
+		listHeight = 1
+	}
+	if !m.preview {
+		m.list.SetSize(m.width, listHeight)
+		return
 	}
+	listWidth := m.width / 2
+	m.list.SetSize(listWidth, listHeight)
+	m.previewVP.Width = m.width - listWidth - 2
+	m.previewVP.Height = listHeight - 2
+	m.renderer = newRenderer(m.previewVP.Width)
+}
+
+// newIssueAddModel returns the issue selector reopened over the dashboard,
+// leaving out the issues in started.
+func newIssueAddModel(fg forge.Forge, issues, local []session.Issue, started map[string]bool, presets []config.FilterPreset, pageSize, width, height int) issueSelectModel {
+	m := newIssueSelectModel(fg, nil, local, forge.Filter{}, presets, pageSize, width, height)
+	m.list.Title = "Add issues  —  space: toggle  enter: add  /: search  f: filter  p: preset  a: ad hoc  v: preview  esc: back"
+	m.started = started
+	m.setIssues(issues)
+	return m
 }
 
 func (m issueSelectModel) Init() tea.Cmd { return nil }
@@ -138,10 +228,40 @@ func (m issueSelectModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width, m.height = msg.Width, msg.Height
-		m.list.SetSize(msg.Width, msg.Height-3)
+		m.resize()
+		m.refreshPreview()
 		return m, nil
 
+	case issueDetailMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.details[msg.issue.Key()] = msg.issue
+		m.refreshPreview()
+		return m, nil
+
+	case issuesLoadedMsg:
+		if msg.seq != m.seq {
+			return m, nil // superseded by a newer request
+		}
+		m.loading = false
+		m.err = msg.err
+		if msg.err == nil {
+			m.setIssues(msg.issues)
+		}
+		return m, m.refreshPreview()
+
 	case tea.KeyMsg:
+		if m.editing {
+			return m.updateFilterInput(msg)
+		}
+		if m.prompting {
+			return m.updatePromptInput(msg)
+		}
+		if m.list.SettingFilter() {
+			break
+		}
 		switch msg.String() {
 		case " ":
 			idx := m.list.Index()
@@ -150,7 +270,11 @@ func (m issueSelectModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				break
 			}
 			item.selected = !item.selected
-			m.selected[item.issue.Number] = item.selected
+			if item.selected {
+				m.selected[item.issue.Key()] = item.issue
+			} else {
+				delete(m.selected, item.issue.Key())
+			}
 			m.list.SetItem(idx, item)
 			return m, nil
 
@@ -161,44 +285,277 @@ func (m issueSelectModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					chosen = []session.Issue{item.issue}
 				}
 			}
+			for i, iss := range chosen {
+				if full, ok := m.details[iss.Key()]; ok && !iss.IsLocal() {
+					chosen[i] = full
+				}
+			}
 			if len(chosen) > 0 {
 				return m, startSessionsCmd(chosen)
 			}
 			return m, nil
 
-		case "q", "ctrl+c":
+		case "a":
+			m.prompting = true
+			m.promptInput.SetValue("")
+			return m, m.promptInput.Focus()
+
+		case "f":
+			m.editing = true
+			m.filterInput.SetValue(formatFilter(m.filter))
+			m.filterInput.CursorEnd()
+			return m, m.filterInput.Focus()
+
+		case "v":
+			m.preview = !m.preview
+			m.resize()
+			return m, m.refreshPreview()
+
+		case "J", "K":
+			if m.preview {
+				if msg.String() == "J" {
+					m.previewVP.LineDown(3)
+				} else {
+					m.previewVP.LineUp(3)
+				}
+			}
+			return m, nil
+
+		case "p":
+			if len(m.presets) == 0 {
+				return m, nil
+			}
+			m.presetIdx = (m.presetIdx + 1) % len(m.presets)
+			return m, m.applyFilter(m.presets[m.presetIdx].Filter)
+
+		case "q", "esc":
+			switch {
+			case m.started == nil:
+				if msg.String() == "q" {
+					return m, tea.Quit
+				}
+			case m.list.FilterState() == list.Unfiltered:
+				// Back to the dashboard without adding issues.
+				return m, func() tea.Msg { return closeIssueAddMsg{} }
+			case msg.String() == "q":
+				return m, nil // the list would quit
+			}
+
+		case "ctrl+c":
 			return m, tea.Quit
 		}
 	}
 
 	var cmd tea.Cmd
+	prev := m.list.Index()
 	m.list, cmd = m.list.Update(msg)
+	cmds := []tea.Cmd{cmd, m.maybeLoadMore()}
+	if m.list.Index() != prev {
+		cmds = append(cmds, m.refreshPreview())
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// refreshPreview shows the focused issue in the preview pane, returning a
+// Cmd to fetch its comments and links if they are not cached yet.
+func (m *issueSelectModel) refreshPreview() tea.Cmd {
+	item, ok := m.list.SelectedItem().(issueItem)
+	if !m.preview || !ok {
+		return nil
+	}
+	iss, cached := item.issue, item.issue.Detailed
+	if full, ok := m.details[iss.Key()]; ok && !cached {
+		iss, cached = full, true
+	}
+	content := renderMarkdown(m.renderer, session.IssueMarkdown(iss))
+	if !cached {
+		content += "\n\n" + statusStyle.Render("Loading comments…")
+	}
+	if item.issue.Key() != m.previewKey {
+		m.previewKey = item.issue.Key()
+		m.previewVP.GotoTop()
+	}
+	m.previewVP.SetContent(content)
+	if cached {
+		return nil
+	}
+	return fetchIssueDetailCmd(m.forge, item.issue)
+}
+
+// updateFilterInput handles keys while the server-side filter is being typed.
+func (m issueSelectModel) updateFilterInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.editing = false
+		m.filterInput.Blur()
+		m.presetIdx = -1
+		return m, m.applyFilter(parseFilter(m.filterInput.Value()))
+	case "esc":
+		m.editing = false
+		m.filterInput.Blur()
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	var cmd tea.Cmd
+	m.filterInput, cmd = m.filterInput.Update(msg)
+	return m, cmd
+}
+
+// updatePromptInput handles keys while an ad hoc prompt is being typed. The
+// prompt becomes a selected local task.
+func (m issueSelectModel) updatePromptInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.prompting = false
+		m.promptInput.Blur()
+		text := strings.TrimSpace(m.promptInput.Value())
+		if text == "" {
+			return m, nil
+		}
+		n := 1
+		for _, iss := range m.local {
+			if iss.Source == forge.SourcePrompt {
+				n++
+			}
+		}
+		iss := tasks.Prompt(n, text)
+		for m.started[iss.Key()] {
+			n++
+			iss = tasks.Prompt(n, text)
+		}
+		m.local = append([]session.Issue{iss}, m.local...)
+		m.selected[iss.Key()] = iss
+		m.setIssues(m.issues)
+		m.list.Select(0)
+		return m, m.refreshPreview()
+	case "esc":
+		m.prompting = false
+		m.promptInput.Blur()
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	var cmd tea.Cmd
+	m.promptInput, cmd = m.promptInput.Update(msg)
 	return m, cmd
 }
 
+// applyFilter replaces the server-side filter and reloads from the first page.
+func (m *issueSelectModel) applyFilter(f forge.Filter) tea.Cmd {
+	m.filter = f
+	m.limit = m.pageSize
+	m.list.ResetFilter()
+	m.list.Select(0)
+	return m.fetch()
+}
+
+// maybeLoadMore requests the next page once the cursor reaches the last
+// loaded issue. Paging is paused while the local list filter is active since
+// the visible items are then a subset.
+func (m *issueSelectModel) maybeLoadMore() tea.Cmd {
+	if m.loading || !m.hasMore || m.list.FilterState() != list.Unfiltered {
+		return nil
+	}
+	if m.list.Index() < len(m.list.Items())-1 {
+		return nil
+	}
+	m.limit += m.pageSize
+	return m.fetch()
+}
+
+func (m *issueSelectModel) fetch() tea.Cmd {
+	m.seq++
+	m.loading = true
+	m.err = nil
+	return fetchIssuesCmd(m.forge, m.filter, m.limit, m.seq)
+}
+
+// setIssues replaces the forge issues in the list, keeping local tasks on
+// top, selection marks and the cursor position. Issues that already have
+// sessions are left out.
+func (m *issueSelectModel) setIssues(issues []session.Issue) {
+	m.issues = issues
+	m.hasMore = len(issues) >= m.limit
+
+	items := make([]list.Item, 0, len(m.local)+len(issues))
+	for _, iss := range append(append([]session.Issue{}, m.local...), issues...) {
+		if m.started[iss.Key()] {
+			continue
+		}
+		_, sel := m.selected[iss.Key()]
+		items = append(items, issueItem{issue: iss, selected: sel})
+	}
+	idx := m.list.Index()
+	m.list.SetItems(items)
+	if idx < len(items) {
+		m.list.Select(idx)
+	}
+}
+
 func (m issueSelectModel) View() string {
-	hint := statusStyle.Render(fmt.Sprintf("%d selected", m.countSelected()))
-	return m.list.View() + "\n" + hint
+	body := m.list.View()
+	if m.preview {
+		body = lipgloss.JoinHorizontal(lipgloss.Top, body, previewStyle.Render(m.previewVP.View()))
+	}
+	if m.editing {
+		return body + "\n" + m.filterInput.View()
+	}
+	if m.prompting {
+		return body + "\n" + m.promptInput.View()
+	}
+
+	parts := []string{
+		fmt.Sprintf("%d selected", len(m.selected)),
+		fmt.Sprintf("%d loaded", len(m.issues)),
+	}
+	if len(m.local) > 0 {
+		parts = append(parts, fmt.Sprintf("%d local", len(m.local)))
+	}
+	switch {
+	case m.loading:
+		parts = append(parts, "loading…")
+	case m.hasMore:
+		parts = append(parts, "more below")
+	}
+	if m.presetIdx >= 0 {
+		parts = append(parts, "preset: "+m.presets[m.presetIdx].Name)
+	}
+	if !m.filter.IsZero() {
+		parts = append(parts, "filter: "+formatFilter(m.filter))
+	}
+	hint := statusStyle.Render(strings.Join(parts, "  ·  "))
+	if m.err != nil {
+		hint += "  " + errorStyle.Render(truncate(m.err.Error(), m.width/2))
+	}
+	return body + "\n" + hint
 }
 
+// chosenIssues returns the selected issues: forge issues by number, then
+// local tasks by source and number.
 func (m issueSelectModel) chosenIssues() []session.Issue {
-	var out []session.Issue
-	for _, iss := range m.issues {
-		if m.selected[iss.Number] {
-			out = append(out, iss)
-		}
+	out := make([]session.Issue, 0, len(m.selected))
+	for _, iss := range m.selected {
+		out = append(out, iss)
 	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source // SourceForge is ""
+		}
+		return a.Number < b.Number
+	})
 	return out
 }
 
-func (m issueSelectModel) countSelected() int {
-	n := 0
-	for _, v := range m.selected {
-		if v {
-			n++
-		}
+func fetchIssueDetailCmd(fg forge.Forge, iss session.Issue) tea.Cmd {
+	return func() tea.Msg {
+		iss, err := forge.Details(fg, iss)
+		return issueDetailMsg{issue: iss, err: err}
 	}
-	return n
 }
 
 func startSessionsCmd(issues []session.Issue) tea.Cmd {
@@ -211,6 +568,13 @@ type startSessionsMsg struct {
 	issues []session.Issue
 }
 
+// openIssueAddMsg asks for the issue selector over the dashboard, and
+// closeIssueAddMsg to go back to the dashboard without adding issues.
+type (
+	openIssueAddMsg  struct{}
+	closeIssueAddMsg struct{}
+)
+
 func truncate(s string, max int) string {
 	s = strings.ReplaceAll(s, "\n", " ")
 	s = strings.TrimSpace(s)
@@ -0,0 +1,141 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/tomfevang/go-work/internal/session"
+)
+
+// maxLogBlock bounds the log output rendered as one block, and so the output
+// rendered again while it grows. A block ends at a blank line outside code
+// blocks, or at any line end if there is none within the bound.
+const maxLogBlock = 16 << 10
+
+// logView is a session's log rendered for the Log tab. Output is rendered in
+// blocks once it settles, so new output only re-renders the block still
+// open. Blocks are dropped as the log drops their output from memory.
+type logView struct {
+	width  int
+	blocks []logBlock
+	joined string // the blocks' text, or "" to join them again
+	end    int    // log offset up to which blocks are rendered
+	fence  string // opening line of a code block cut by the last block
+}
+
+type logBlock struct {
+	end  int // log offset just past the block
+	text string
+}
+
+// render returns the log of the session ref rendered at width, rendering
+// only output that is new or still open.
+func (v *logView) render(r *glamour.TermRenderer, width int, log *session.LogBuffer, ref string) string {
+	if v.width != width || log.Len() < v.end {
+		*v = logView{width: width}
+	}
+	if len(v.blocks) > 0 && v.blocks[0].end <= log.Start() {
+		for len(v.blocks) > 0 && v.blocks[0].end <= log.Start() {
+			v.blocks = v.blocks[1:]
+		}
+		v.joined = ""
+	}
+	if v.end < log.Start() {
+		v.end, v.fence = log.Start(), ""
+	}
+
+	open := log.Since(v.end)
+	for {
+		cut, fence := settle(open, v.fence)
+		if cut == 0 {
+			break
+		}
+		src := reopen(v.fence) + open[:cut]
+		if fence != "" {
+			src += fenceMarker(fence) + "\n"
+		}
+		v.end += cut
+		v.blocks = append(v.blocks, logBlock{v.end, renderMarkdown(r, src)})
+		v.fence, open = fence, open[cut:]
+		v.joined = ""
+	}
+	if v.joined == "" {
+		texts := make([]string, 0, len(v.blocks)+1)
+		switch {
+		case log.Lost():
+			texts = append(texts, statusStyle.Render(fmt.Sprintf(
+				"… %d KiB of earlier output dropped.", log.Start()>>10)))
+		case log.Start() > 0:
+			texts = append(texts, statusStyle.Render(fmt.Sprintf(
+				"… %d KiB of earlier output not shown; go-work logs %s prints the whole log.", log.Start()>>10, ref)))
+		}
+		for _, b := range v.blocks {
+			texts = append(texts, b.text)
+		}
+		v.joined = strings.Join(texts, "\n")
+	}
+
+	if strings.TrimSpace(open) == "" {
+		return v.joined
+	}
+	tail := renderMarkdown(r, reopen(v.fence)+open)
+	if v.joined == "" {
+		return tail
+	}
+	return v.joined + "\n" + tail
+}
+
+// settle returns how much of text, log output not yet rendered, can be
+// rendered as a block, and the opening line of a code block the cut falls
+// in. fence is the code block text starts in, if any.
+func settle(text, fence string) (cut int, cutFence string) {
+	pos := 0
+	for {
+		nl := strings.IndexByte(text[pos:], '\n')
+		if nl < 0 {
+			return cut, cutFence // the last line is still being written
+		}
+		line, next := text[pos:pos+nl], pos+nl+1
+		if m := fenceMarker(line); m != "" {
+			switch {
+			case fence == "":
+				fence = line
+			case strings.TrimSpace(line) == m && strings.HasPrefix(m, fenceMarker(fence)):
+				fence = ""
+			}
+		}
+		if fence == "" && strings.TrimSpace(line) == "" {
+			cut, cutFence = next, ""
+		}
+		if next >= maxLogBlock {
+			if cut == 0 {
+				cut, cutFence = next, fence
+			}
+			return cut, cutFence
+		}
+		pos = next
+	}
+}
+
+// fenceMarker returns the backticks or tildes line starts with, if it opens
+// or closes a code block.
+func fenceMarker(line string) string {
+	line = strings.TrimLeft(line, " ")
+	if line == "" || (line[0] != '`' && line[0] != '~') {
+		return ""
+	}
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	if n < 3 {
+		return ""
+	}
+	return line[:n]
+}
+
+// reopen returns the source opening the code block fence again, if any.
+func reopen(fence string) string {
+	if fence == "" {
+		return ""
+	}
+	return fence + "\n"
+}
@@ -0,0 +1,108 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tomfevang/go-work/internal/session"
+)
+
+func TestSettle(t *testing.T) {
+	long := strings.Repeat("x", 100) + "\n"
+	tests := []struct {
+		name      string
+		text      string
+		fence     string
+		wantCut   int
+		wantFence string
+	}{
+		{name: "unfinished line", text: "partial"},
+		{name: "no blank line yet", text: "para\nmore\n"},
+		{name: "paragraphs", text: "one\n\ntwo\n\nthree", wantCut: len("one\n\ntwo\n\n")},
+		{name: "blank line in code", text: "```go\nx := 1\n\ny := 2\n", wantCut: 0},
+		{name: "after code", text: "```\na\n\nb\n```\n\nnext", wantCut: len("```\na\n\nb\n```\n\n")},
+		{name: "longer closing fence", text: "````\n```\n\n````\n\n", wantCut: len("````\n```\n\n````\n\n")},
+		{name: "tilde fence", text: "~~~\n\n~~~\n\n", wantCut: len("~~~\n\n~~~\n\n")},
+		{name: "continues a code block", text: "x\n\n```\n\n", fence: "```", wantCut: len("x\n\n```\n\n")},
+		{
+			name:    "long output without blank lines",
+			text:    strings.Repeat(long, maxLogBlock/len(long)+10),
+			wantCut: (maxLogBlock/len(long) + 1) * len(long),
+		},
+		{
+			name:      "long code block",
+			text:      "```\n" + strings.Repeat(long, maxLogBlock/len(long)+10),
+			wantCut:   len("```\n") + (maxLogBlock/len(long)+1)*len(long),
+			wantFence: "```",
+		},
+	}
+	for _, tt := range tests {
+		cut, fence := settle(tt.text, tt.fence)
+		if cut != tt.wantCut || fence != tt.wantFence {
+			t.Errorf("%s: settle = %d, %q; want %d, %q", tt.name, cut, fence, tt.wantCut, tt.wantFence)
+		}
+	}
+}
+
+func TestLogViewRender(t *testing.T) {
+	var log session.LogBuffer
+	var v logView
+
+	// Without a renderer, blocks are their source, joined by a newline.
+	log.WriteString("first para\n\nsecond")
+	if got := v.render(nil, 80, &log, "1"); got != "first para\n\n\nsecond" {
+		t.Errorf("render = %q", got)
+	}
+	if len(v.blocks) != 1 || v.end != len("first para\n\n") {
+		t.Errorf("blocks = %+v, end = %d; want the first paragraph settled", v.blocks, v.end)
+	}
+
+	// Only new output is rendered; settled blocks are kept.
+	first := v.blocks[0]
+	log.WriteString(" para\n\n```\ncode\n\nmore code\n")
+	got := v.render(nil, 80, &log, "1")
+	if len(v.blocks) != 2 || v.blocks[0] != first {
+		t.Errorf("blocks = %+v, want the first kept and the second settled", v.blocks)
+	}
+	if want := "first para\n\n\nsecond para\n\n\n```\ncode\n\nmore code\n"; got != want {
+		t.Errorf("render = %q, want %q", got, want)
+	}
+
+	// A code block cut by a long block is closed and opened again.
+	log.WriteString(strings.Repeat("code line\n", maxLogBlock/10+5))
+	v.render(nil, 80, &log, "1")
+	last := v.blocks[len(v.blocks)-1].text
+	if v.fence != "```" || !strings.HasSuffix(last, "```\n") {
+		t.Errorf("fence = %q, last block ends %q; want the code block cut and closed", v.fence, last[max(0, len(last)-20):])
+	}
+	log.WriteString("```\n\ndone\n")
+	got = v.render(nil, 80, &log, "1")
+	last = v.blocks[len(v.blocks)-1].text
+	if v.fence != "" || !strings.HasPrefix(last, "```\ncode line\n") || !strings.HasSuffix(last, "code line\n```\n\n") {
+		t.Errorf("fence = %q, last block %q…%q; want the code block reopened and closed", v.fence, last[:20], last[max(0, len(last)-20):])
+	}
+	if !strings.HasSuffix(got, "```\n\n\ndone\n") {
+		t.Errorf("render ends %q", got[max(0, len(got)-20):])
+	}
+
+	// A new width renders everything again.
+	v.render(nil, 60, &log, "1")
+	if v.width != 60 || v.end == 0 {
+		t.Errorf("width = %d, end = %d after resizing", v.width, v.end)
+	}
+
+	// Blocks whose output is dropped from memory are dropped too.
+	for range 40 {
+		log.WriteString(strings.Repeat("output line\n", 3000) + "\n")
+		v.render(nil, 60, &log, "1")
+	}
+	if log.Start() == 0 {
+		t.Fatal("nothing was dropped from memory")
+	}
+	if v.blocks[0].end <= log.Start() {
+		t.Errorf("first block ends at %d, before the log's start %d", v.blocks[0].end, log.Start())
+	}
+	if got := v.render(nil, 60, &log, "1"); !strings.Contains(got[:100], "earlier output dropped") {
+		t.Errorf("render starts %q, want a note of the dropped output", got[:100])
+	}
+}
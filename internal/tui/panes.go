@@ -0,0 +1,269 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tomfevang/go-work/internal/session"
+)
+
+// paneTab is a view of the selected session in the dashboard's right pane.
+type paneTab int
+
+const (
+	tabPlan paneTab = iota
+	tabLog
+	tabDiff
+	tabTools
+	tabIssue
+	tabPR
+	numTabs
+)
+
+var tabNames = [numTabs]string{"Plan", "Log", "Diff", "Tools", "Issue", "PR/CI"}
+
+var (
+	tabStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Padding(0, 1)
+	activeTabStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true).Underline(true).Padding(0, 1)
+
+	diffAddStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("42")).Render
+	diffDelStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Render
+	diffHunkStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("33")).Render
+	diffFileStyle = lipgloss.NewStyle().Bold(true).Render
+)
+
+// paneKey identifies one tab of one session.
+type paneKey struct {
+	key string
+	tab paneTab
+}
+
+// paneScroll is where a tab of a session is scrolled to.
+type paneScroll struct {
+	offset int
+	follow bool // keep the end in view as content arrives
+}
+
+// fetched is content loaded in the background, a diff or a PR status.
+type fetched struct {
+	text string
+	err  error
+}
+
+// diffMsg carries a session's diff, for the Diff tab.
+type diffMsg struct {
+	key string
+	fetched
+}
+
+// prStatusMsg carries the state of a session's PR and its checks, for the
+// PR/CI tab.
+type prStatusMsg struct {
+	key string
+	fetched
+}
+
+// scrollOf returns the scroll state of a tab, following the end by default
+// on the tabs that grow.
+func (m *dashboardModel) scrollOf(pk paneKey) *paneScroll {
+	st := m.scrolls[pk]
+	if st == nil {
+		st = &paneScroll{follow: pk.tab == tabLog || pk.tab == tabTools}
+		m.scrolls[pk] = st
+	}
+	return st
+}
+
+// showTab switches the right pane to tab, loading its content if it comes
+// from git or the forge.
+func (m *dashboardModel) showTab(tab paneTab) tea.Cmd {
+	m.tab = tab
+	m.refreshViewport()
+	return m.fetchTab()
+}
+
+// fetchTab reloads the content of the current tab for the selected
+// session, if it is loaded in the background.
+func (m *dashboardModel) fetchTab() tea.Cmd {
+	key, ok := m.selectedKey()
+	if !ok {
+		return nil
+	}
+	s := m.mgr.Get(key)
+	env := m.mgr.Env(key)
+	switch {
+	case m.tab == tabDiff && s.State != session.Pending:
+		root, dir := env.RepoRoot, env.Worktrees.Path(env.RepoRoot, key)
+		return func() tea.Msg {
+			diff, err := session.Diff(root, dir)
+			return diffMsg{key, fetched{diff, err}}
+		}
+	case m.tab == tabPR && s.PR != "" && env.Forge != nil:
+		fg, url := env.Forge, s.PR
+		return func() tea.Msg {
+			st, err := fg.PRStatus(url)
+			if err != nil {
+				return prStatusMsg{key, fetched{err: err}}
+			}
+			var b strings.Builder
+			fmt.Fprintf(&b, "State: **%s**\n\n", st.State)
+			if len(st.Checks) == 0 {
+				b.WriteString("No CI checks.\n")
+			}
+			for _, c := range st.Checks {
+				fmt.Fprintf(&b, "- %s %s — %s", checkBadge(c.Status), c.Name, c.Status)
+				if c.URL != "" {
+					fmt.Fprintf(&b, " ([details](%s))", c.URL)
+				}
+				b.WriteString("\n")
+			}
+			return prStatusMsg{key, fetched{text: b.String()}}
+		}
+	}
+	return nil
+}
+
+func checkBadge(status string) string {
+	switch status {
+	case "success", "passed", "neutral", "skipped":
+		return "✓"
+	case "failure", "failed", "error", "cancelled", "canceled", "timed_out", "action_required":
+		return "✗"
+	default:
+		return "…"
+	}
+}
+
+// tabBar renders the tab titles, marking the current tab and whether it
+// follows the end of its content.
+func (m dashboardModel) tabBar() string {
+	var tabs []string
+	for t := range numTabs {
+		title := fmt.Sprintf("%d %s", t+1, tabNames[t])
+		if t == m.tab {
+			tabs = append(tabs, activeTabStyle.Render(title))
+		} else {
+			tabs = append(tabs, tabStyle.Render(title))
+		}
+	}
+	bar := strings.Join(tabs, "")
+	if st := m.scrolls[m.shown]; st != nil && st.follow {
+		bar += statusStyle.Render("  ⇣ following")
+	}
+	return bar
+}
+
+// tabContent renders the current tab for session s.
+func (m dashboardModel) tabContent(s *session.Session) string {
+	key := s.Issue.Key()
+	width := m.viewport.Width
+	switch m.tab {
+	case tabPlan:
+		if s.Plan == "" {
+			return statusStyle.Render("No plan yet.")
+		}
+		return renderMarkdown(m.renderer, "## Plan\n\n"+s.Plan)
+
+	case tabDiff:
+		f, ok := m.diffs[key]
+		switch {
+		case s.State == session.Pending:
+			return statusStyle.Render("No worktree yet.")
+		case !ok:
+			return statusStyle.Render("Loading diff…")
+		case f.err != nil:
+			return errorStyle.Render(f.err.Error())
+		case strings.TrimSpace(f.text) == "":
+			return statusStyle.Render("No changes yet.")
+		}
+		return colorDiff(f.text, width)
+
+	case tabTools:
+		if len(s.Tools) == 0 {
+			return statusStyle.Render("No tool calls yet.")
+		}
+		lines := make([]string, len(s.Tools))
+		for i, t := range s.Tools {
+			lines[i] = cutLine(fmt.Sprintf("%3d  %s", i+1, t), width)
+		}
+		return strings.Join(lines, "\n")
+
+	case tabIssue:
+		raw := session.IssueMarkdown(s.Issue)
+		for _, iss := range s.Also {
+			raw += "\n\n---\n\n" + session.IssueMarkdown(iss)
+		}
+		return renderMarkdown(m.renderer, raw)
+
+	case tabPR:
+		return renderMarkdown(m.renderer, m.prMarkdown(s))
+
+	default:
+		v := m.logViews[key]
+		if v == nil {
+			v = &logView{}
+			m.logViews[key] = v
+		}
+		return v.render(m.renderer, width, &s.Log, s.Issue.Ref())
+	}
+}
+
+// prMarkdown describes where a session's work went: its branch, the pre-push
+// check findings holding it back, and its PR with CI status.
+func (m dashboardModel) prMarkdown(s *session.Session) string {
+	var b strings.Builder
+	if s.State == session.Blocked {
+		b.WriteString("## Push blocked\n\n" + s.Findings +
+			"\nFix the branch in the worktree and push anyway, or reject to fail the session.\n\n")
+	}
+	b.WriteString("## Pull request\n\n")
+	if s.Branch != "" {
+		fmt.Fprintf(&b, "Branch: `%s`\n\n", s.Branch)
+	}
+	if s.PR == "" {
+		b.WriteString("No pull request yet.\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%s\n\n## CI\n\n", s.PR)
+	switch f, ok := m.prStatus[s.Issue.Key()]; {
+	case !ok:
+		b.WriteString("Loading…\n")
+	case f.err != nil:
+		b.WriteString("Could not get the status: " + f.err.Error() + "\n")
+	default:
+		b.WriteString(f.text)
+	}
+	return b.String()
+}
+
+// colorDiff colors a unified diff, cutting lines to width.
+func colorDiff(diff string, width int) string {
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	for i, line := range lines {
+		line = cutLine(line, width)
+		switch {
+		case strings.HasPrefix(line, "diff "), strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "):
+			lines[i] = diffFileStyle(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = diffHunkStyle(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = diffAddStyle(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = diffDelStyle(line)
+		default:
+			lines[i] = line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cutLine expands tabs and cuts s to width columns, counting runes.
+func cutLine(s string, width int) string {
+	s = strings.ReplaceAll(s, "\t", "    ")
+	if r := []rune(s); width > 1 && len(r) > width {
+		return string(r[:width-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,207 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	matchStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("0")).Background(lipgloss.Color("220"))
+	curMatchStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("0")).Background(lipgloss.Color("205")).Bold(true)
+)
+
+// paneSearch is a search of the right pane, highlighting the lines that
+// match. A global search moves on to the logs of other sessions once the
+// matches of the shown one run out.
+type paneSearch struct {
+	pattern string
+	re      *regexp.Regexp
+	global  bool
+	lines   []int // lines of the shown content that match
+	cur     int   // line of the current match, or -1
+}
+
+// newSearch compiles pattern as a regular expression, ignoring case unless
+// the pattern has upper case letters.
+func newSearch(pattern string, global bool) (*paneSearch, error) {
+	expr := pattern
+	if !strings.ContainsFunc(pattern, unicode.IsUpper) {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	return &paneSearch{pattern: pattern, re: re, global: global, cur: -1}, nil
+}
+
+// mark highlights the matches in content, which loses its other styling on
+// the lines that match, and records those lines.
+func (ps *paneSearch) mark(content string) string {
+	lines := strings.Split(content, "\n")
+	ps.lines = ps.lines[:0]
+	for i, line := range lines {
+		plain := ansi.Strip(line)
+		var b strings.Builder
+		last := 0
+		for _, loc := range ps.re.FindAllStringIndex(plain, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			style := matchStyle
+			if i == ps.cur {
+				style = curMatchStyle
+			}
+			b.WriteString(plain[last:loc[0]])
+			b.WriteString(style.Render(plain[loc[0]:loc[1]]))
+			last = loc[1]
+		}
+		if last == 0 {
+			continue
+		}
+		b.WriteString(plain[last:])
+		lines[i] = b.String()
+		ps.lines = append(ps.lines, i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// step returns the matching line after the current one, or before it if
+// dir is negative. With wrap it continues from the other end.
+func (ps *paneSearch) step(dir int, wrap bool) (int, bool) {
+	if dir > 0 {
+		for _, l := range ps.lines {
+			if l > ps.cur {
+				return l, true
+			}
+		}
+	} else {
+		for _, l := range slices.Backward(ps.lines) {
+			if ps.cur < 0 || l < ps.cur {
+				return l, true
+			}
+		}
+	}
+	if !wrap || len(ps.lines) == 0 {
+		return 0, false
+	}
+	if dir > 0 {
+		return ps.lines[0], true
+	}
+	return ps.lines[len(ps.lines)-1], true
+}
+
+// openSearch starts typing a search of the shown tab or, with global, of all
+// sessions' logs.
+func (m *dashboardModel) openSearch(global bool) tea.Cmd {
+	m.err = nil
+	m.typingSearch = true
+	m.searchGlobal = global
+	m.searchInput.Prompt = "/"
+	if global {
+		m.searchInput.Prompt = "all logs/"
+	}
+	m.searchInput.SetValue("")
+	m.searchInput.Width = max(10, m.width-leftPaneWidth-20)
+	return m.searchInput.Focus()
+}
+
+// updateSearchInput handles keys while a search is being typed.
+func (m dashboardModel) updateSearchInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.typingSearch = false
+		m.searchInput.Blur()
+		m.search = nil
+		if pattern := m.searchInput.Value(); pattern != "" {
+			m.search, m.err = newSearch(pattern, m.searchGlobal)
+		}
+		m.refreshViewport()
+		if m.search == nil {
+			return m, nil
+		}
+		if m.search.global && m.tab != tabLog {
+			m.tab = tabLog
+			m.refreshViewport()
+		}
+		m.search.cur = m.viewport.YOffset - 1 // from the top of the view
+		return m, m.nextMatch(1)
+	case "esc":
+		m.typingSearch = false
+		m.searchInput.Blur()
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	}
+	var cmd tea.Cmd
+	m.searchInput, cmd = m.searchInput.Update(msg)
+	return m, cmd
+}
+
+// nextMatch scrolls to the next match, or the previous one if dir is
+// negative. A global search goes on to the next session whose log matches.
+func (m *dashboardModel) nextMatch(dir int) tea.Cmd {
+	ps := m.search
+	line, ok := ps.step(dir, !ps.global)
+	var cmd tea.Cmd
+	if !ok && ps.global {
+		line, ok, cmd = m.nextSessionMatch(dir)
+	}
+	if !ok {
+		m.err = fmt.Errorf("no match for %s", ps.pattern)
+		return cmd
+	}
+	m.err = nil
+	ps.cur = line
+	st := m.scrollOf(m.shown)
+	st.follow = false
+	st.offset = max(0, line-m.viewport.Height/3)
+	m.refreshViewport()
+	return cmd
+}
+
+// nextSessionMatch selects the next session, in dir, with a match in its
+// log and returns its first match, or its last going backwards. The search
+// comes back around to the shown session last.
+func (m *dashboardModel) nextSessionMatch(dir int) (int, bool, tea.Cmd) {
+	order := m.mgr.Order()
+	from := m.list.Index()
+	for n := 1; n <= len(order); n++ {
+		i := ((from+dir*n)%len(order) + len(order)) % len(order)
+		s := m.mgr.Get(order[i])
+		if !m.search.re.MatchString(s.Log.Since(s.Log.Start())) {
+			continue
+		}
+		m.list.Select(i)
+		m.tab = tabLog
+		m.search.cur = -1
+		m.refreshViewport()
+		if line, ok := m.search.step(dir, false); ok {
+			return line, true, m.fetchTab()
+		}
+	}
+	return 0, false, nil
+}
+
+// searchLine describes the active search for the footer.
+func (m dashboardModel) searchLine() string {
+	ps := m.search
+	where := ""
+	if ps.global {
+		where = " in all logs"
+	}
+	pos := "no matches"
+	if i := slices.Index(ps.lines, ps.cur); i >= 0 {
+		pos = fmt.Sprintf("%d of %d", i+1, len(ps.lines))
+	} else if len(ps.lines) > 0 {
+		pos = fmt.Sprintf("%d matching lines", len(ps.lines))
+	}
+	return statusStyle.Render(truncate(fmt.Sprintf("/%s%s: %s  [n] Next  [N] Previous  [esc] Clear", ps.pattern, where, pos), m.width-leftPaneWidth-8))
+}
@@ -0,0 +1,224 @@
+// Package workspace works out what a go-work process works on: the git repo
+// containing the directory it runs in, or, outside any repo, the repos
+// listed under workspace.repos. Either way it is a Workspace; a single repo
+// is one unnamed repo.
+package workspace
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/tomfevang/go-work/internal/config"
+	"github.com/tomfevang/go-work/internal/forge"
+	"github.com/tomfevang/go-work/internal/session"
+	"github.com/tomfevang/go-work/internal/tasks"
+)
+
+// Repo is one repo of a workspace.
+type Repo struct {
+	Name   string // tags issues and sessions; "" for a single repo
+	Root   string
+	Config *config.Config
+	Forge  forge.Forge // set by DetectForges
+}
+
+// Workspace is the set of repos one go-work process works on.
+type Workspace struct {
+	// Dir is the repo root, or the directory a multi-repo workspace was
+	// opened in.
+	Dir string
+	// Config holds the settings of the process as a whole: issue listing,
+	// filters, daemon, server, notifications and telemetry. For a single
+	// repo it is the repo's config.
+	Config *config.Config
+	Repos  []*Repo
+	// Forge lists the issues of all repos; set by DetectForges.
+	Forge forge.Forge
+}
+
+// ErrNoRepo is returned by Load outside git repos when no workspace is
+// configured.
+var ErrNoRepo = errors.New("not inside a git repository, and no workspace.repos configured")
+
+// Load opens the repo containing dir or, if there is none, the workspace
+// configured for dir, and loads the configs.
+func Load(dir string) (*Workspace, error) {
+	if root, err := RepoRoot(dir); err == nil {
+		cfg, err := config.Load(root)
+		if err != nil {
+			return nil, err
+		}
+		return &Workspace{Dir: root, Config: cfg, Repos: []*Repo{{Root: root, Config: cfg}}}, nil
+	}
+
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Workspace.Repos) == 0 {
+		return nil, ErrNoRepo
+	}
+	w := &Workspace{Dir: dir, Config: cfg}
+	for _, wr := range cfg.Workspace.Repos {
+		path := wr.Path
+		if rest, ok := strings.CutPrefix(path, "~/"); ok {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, err
+			}
+			path = filepath.Join(home, rest)
+		} else if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		root, err := RepoRoot(path)
+		if err != nil {
+			return nil, fmt.Errorf("workspace repo %s: %w", wr.RepoName(), err)
+		}
+		rcfg, err := config.Load(root)
+		if err != nil {
+			return nil, fmt.Errorf("workspace repo %s: %w", wr.RepoName(), err)
+		}
+		w.Repos = append(w.Repos, &Repo{Name: wr.RepoName(), Root: root, Config: rcfg})
+	}
+	return w, nil
+}
+
+// RepoRoot returns the root of the git repo containing dir. From inside a
+// linked worktree, such as a session's, it returns the main repo's root.
+func RepoRoot(dir string) (string, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel", "--git-dir", "--git-common-dir").Output()
+	if err != nil {
+		return "", fmt.Errorf("%s is not inside a git repository", dir)
+	}
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 3 {
+		return "", fmt.Errorf("git rev-parse: unexpected output %q", out)
+	}
+	abs := func(p string) string {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		return filepath.Clean(p)
+	}
+	top, gitDir, common := lines[0], abs(lines[1]), abs(lines[2])
+	if gitDir != common && filepath.Base(common) == ".git" {
+		return filepath.Dir(common), nil
+	}
+	return top, nil
+}
+
+// Multi reports whether this is a multi-repo workspace.
+func (w *Workspace) Multi() bool { return len(w.Repos) > 1 || w.Repos[0].Name != "" }
+
+// Repo returns the repo with the given name, or nil.
+func (w *Workspace) Repo(name string) *Repo {
+	i := slices.IndexFunc(w.Repos, func(r *Repo) bool { return r.Name == name })
+	if i < 0 {
+		return nil
+	}
+	return w.Repos[i]
+}
+
+// DetectForges detects each repo's forge, and sets Forge to one listing
+// the issues of all repos.
+func (w *Workspace) DetectForges() error {
+	if w.Forge != nil {
+		return nil
+	}
+	var names []string
+	var forges []forge.Forge
+	for _, r := range w.Repos {
+		fg, err := forge.Detect(r.Root, r.Config.Forge)
+		if err != nil {
+			if r.Name != "" {
+				return fmt.Errorf("%s: %w", r.Name, err)
+			}
+			return err
+		}
+		r.Forge = fg
+		names, forges = append(names, r.Name), append(forges, fg)
+	}
+	w.Forge = forges[0]
+	if w.Multi() {
+		w.Forge = forge.NewMulti(names, forges)
+	}
+	return nil
+}
+
+// StateDir is where sessions are persisted: in the git directory for a
+// single repo, and in .go-work in a workspace's directory.
+func (w *Workspace) StateDir() (string, error) {
+	if !w.Multi() {
+		return session.StateDir(w.Dir)
+	}
+	return filepath.Join(w.Dir, ".go-work"), nil
+}
+
+// Envs returns the environment sessions run in for each repo, by name.
+// DetectForges must have been called.
+func (w *Workspace) Envs() map[string]session.Env {
+	envs := make(map[string]session.Env, len(w.Repos))
+	for _, r := range w.Repos {
+		envs[r.Name] = r.Config.Env(r.Root, r.Forge)
+	}
+	return envs
+}
+
+// Tasks returns the local tasks of all repos.
+func (w *Workspace) Tasks() ([]session.Issue, error) {
+	var all []session.Issue
+	for _, r := range w.Repos {
+		issues, err := tasks.Load(r.Root, r.Config.Tasks)
+		if err != nil {
+			return nil, err
+		}
+		for i := range issues {
+			issues[i].Repo = r.Name
+		}
+		all = append(all, issues...)
+	}
+	return all, nil
+}
+
+// Resolve looks up issues by reference, as tasks.Resolve does. In a
+// multi-repo workspace, references name the repo: "api#12" or "api/task-2".
+// DetectForges must have been called.
+func (w *Workspace) Resolve(refs []string) ([]session.Issue, error) {
+	var out []session.Issue
+	for _, ref := range refs {
+		name, rest := "", ref
+		if i := strings.IndexAny(ref, "#/"); i > 0 {
+			name, rest = ref[:i], strings.TrimPrefix(ref[i:], "/")
+		}
+		r := w.Repo(name)
+		if r == nil {
+			if name == "" {
+				return nil, fmt.Errorf("%s: name the repo, as in %s#12", ref, w.Repos[0].Name)
+			}
+			return nil, fmt.Errorf("%s: no repo %q in the workspace", ref, name)
+		}
+		issues, err := tasks.Resolve(r.Root, r.Config.Tasks, r.Forge, []string{rest})
+		if err != nil && r.Name != "" {
+			return nil, fmt.Errorf("%s: %w", r.Name, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		issues[0].Repo = r.Name
+		out = append(out, issues[0])
+	}
+	return out, nil
+}
+
+// KeyOf returns the session key of an issue reference as users write it.
+func KeyOf(ref string) string {
+	if name, rest, ok := strings.Cut(ref, "#"); ok && name != "" {
+		return name + "-" + rest
+	}
+	return strings.Replace(strings.TrimPrefix(ref, "#"), "/", "-", 1)
+}
@@ -1,21 +1,53 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/tomfevang/go-work/internal/cli"
+	"github.com/tomfevang/go-work/internal/telemetry"
 	"github.com/tomfevang/go-work/internal/tui"
+	"github.com/tomfevang/go-work/internal/workspace"
 )
 
 func main() {
-	repoRoot, err := os.Getwd()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error getting working directory: %v\n", err)
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
 		os.Exit(1)
 	}
+}
 
-	if err := tui.New(repoRoot).Start(); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+func run() error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("getting working directory: %w", err)
+	}
+
+	if len(os.Args) > 1 {
+		if !cli.Has(os.Args[1]) {
+			return fmt.Errorf("unknown command %q; run go-work help", os.Args[1])
+		}
+		return cli.Run(wd, os.Args[1:])
+	}
+
+	ws, err := workspace.Load(wd)
+	if errors.Is(err, workspace.ErrNoRepo) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	if err := ws.DetectForges(); err != nil {
+		return fmt.Errorf("detecting forge: %w", err)
 	}
+
+	shutdown, err := telemetry.Setup(ws.Config.Telemetry.Traces)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	return tui.Run(ws)
 }